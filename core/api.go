@@ -49,6 +49,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/dosco/graphjin/core/internal/graph"
 	_log "log"
 	"os"
@@ -81,6 +82,9 @@ const (
 
 	// User role if pre-defined
 	UserRoleKey
+
+	// Claims of the user's JWT token (map[string]interface{})
+	UserClaimsKey
 )
 
 // GraphJin struct is an instance of the GraphJin engine it holds all the required information like
@@ -102,10 +106,13 @@ type graphjin struct {
 	roleStmtMD  psql.Metadata
 	rmap        map[string]resItem
 	abacEnabled bool
+	sessVars    []sessionVar
 	qc          *qcode.Compiler
 	pc          *psql.Compiler
 	ge          *graphql.Engine
 	subs        sync.Map
+	notify      *subNotifier
+	cache       *resultCache
 	scripts     sync.Map
 	prod        bool
 }
@@ -142,6 +149,10 @@ func NewGraphJin(conf *Config, db *sql.DB, options ...Option) (*GraphJin, error)
 		return nil, err
 	}
 
+	if err := g.initSubsNotify(); err != nil {
+		return nil, err
+	}
+
 	return g, nil
 }
 
@@ -182,6 +193,10 @@ func newGraphJin(conf *Config, db *sql.DB, dbinfo *sdata.DBInfo, options ...Opti
 		return nil, err
 	}
 
+	if err := gj.initSessionVars(); err != nil {
+		return nil, err
+	}
+
 	if err := gj.initResolvers(); err != nil {
 		return nil, err
 	}
@@ -210,6 +225,10 @@ func newGraphJin(conf *Config, db *sql.DB, dbinfo *sdata.DBInfo, options ...Opti
 		return nil, err
 	}
 
+	if err := gj.initCache(); err != nil {
+		return nil, err
+	}
+
 	if conf.SecretKey != "" {
 		sk := sha256.Sum256([]byte(conf.SecretKey))
 		conf.SecretKey = ""
@@ -228,10 +247,6 @@ func OptionSetFS(fs afero.Fs) Option {
 	}
 }
 
-type Error struct {
-	Message string `json:"message"`
-}
-
 // Result struct contains the output of the GraphQL function this includes resulting json from the
 // database query and any error information
 type Result struct {
@@ -240,8 +255,10 @@ type Result struct {
 	sql          string
 	role         string
 	cacheControl string
+	tables       []string
 	Errors       []Error         `json:"errors,omitempty"`
 	Data         json.RawMessage `json:"data,omitempty"`
+	Patch        json.RawMessage `json:"patch,omitempty"`
 	Extensions   *extensions     `json:"extensions,omitempty"`
 }
 
@@ -249,6 +266,12 @@ type Result struct {
 type ReqConfig struct {
 	APQKey string
 	Vars   map[string]interface{}
+
+	// Diff when set on a subscription sends the first result in full
+	// and then only a JSON patch (RFC 6902) of the changes in 'Patch'.
+	// This can also be enabled with the @diff directive. Rows in lists are
+	// matched by their 'id' so select it to keep the patches small.
+	Diff bool
 }
 
 // GraphQL function is called on the GraphJin struct to convert the provided GraphQL query into an
@@ -263,13 +286,44 @@ func (g *GraphJin) GraphQL(
 	vars json.RawMessage,
 	rc *ReqConfig) (*Result, error) {
 
-	var err error
+	gj := g.Load().(*graphjin)
+	return gj.graphQL(c, nil, query, vars, rc)
+}
+
+// GraphQLTx works just like the GraphQL function but runs the query or mutation
+// inside the provided database transaction. Committing or rolling back the
+// transaction is left to the caller, this allows a GraphJin mutation to be
+// combined with other SQL statements as a single atomic unit. When result
+// caching is enabled call InvalidateCache with the result of a mutation once
+// the transaction is committed.
+func (g *GraphJin) GraphQLTx(
+	c context.Context,
+	tx *sql.Tx,
+	query string,
+	vars json.RawMessage,
+	rc *ReqConfig) (*Result, error) {
+
+	if tx == nil {
+		return nil, errors.New("graphql: transaction is nil")
+	}
 
 	gj := g.Load().(*graphjin)
+	return gj.graphQL(c, tx, query, vars, rc)
+}
+
+func (gj *graphjin) graphQL(
+	c context.Context,
+	tx *sql.Tx,
+	query string,
+	vars json.RawMessage,
+	rc *ReqConfig) (*Result, error) {
+
+	var err error
 
 	ct := gcontext{
 		Context: c,
 		gj:      gj,
+		tx:      tx,
 		rc:      rc,
 	}
 
@@ -279,7 +333,8 @@ func (g *GraphJin) GraphQL(
 			ct.op = v.op
 			ct.name = v.name
 		} else {
-			err = errors.New("PersistedQueryNotFound")
+			err = withCode(ErrCodePersistedQueryNotFound,
+				errors.New("PersistedQueryNotFound"))
 		}
 	} else {
 		ct.op, ct.name = qcode.GetQType(query)
@@ -291,16 +346,25 @@ func (g *GraphJin) GraphQL(
 	}
 
 	if err != nil {
-		res.Errors = []Error{{Message: err.Error()}}
+		res.Errors = []Error{NewError(err)}
 		return res, err
 	}
 
 	if ct.op == qcode.QTSubscription {
-		return res, errors.New("use 'core.Subscribe' for subscriptions")
+		err := withCode(ErrCodeValidationFailed,
+			errors.New("use 'core.Subscribe' for subscriptions"))
+		res.Errors = []Error{NewError(err)}
+		return res, err
 	}
 
-	if ct.op == qcode.QTMutation && gj.schema.DBType() == "mysql" {
-		return res, errors.New("mysql: mutations not supported")
+	if ct.op == qcode.QTMutation {
+		switch gj.schema.DBType() {
+		case "mssql", "sqlite":
+			err := withCode(ErrCodeValidationFailed,
+				fmt.Errorf("%s: mutations not supported", gj.schema.DBType()))
+			res.Errors = []Error{NewError(err)}
+			return res, err
+		}
 	}
 
 	// use the chirino/graphql library for introspection queries
@@ -310,7 +374,7 @@ func (g *GraphJin) GraphQL(
 		res.Data = r.Data
 
 		if r.Error() != nil {
-			res.Errors = []Error{{Message: r.Error().Error()}}
+			res.Errors = []Error{NewError(withCode(ErrCodeValidationFailed, r.Error()))}
 		}
 		return res, r.Error()
 	}
@@ -334,13 +398,17 @@ func (g *GraphJin) GraphQL(
 	qres, err := ct.execQuery(qreq, role)
 
 	if err != nil {
-		res.Errors = []Error{{Message: err.Error()}}
+		res.Errors = []Error{NewError(err)}
 	}
 
 	if qres.qc != nil {
 		res.sql = qres.qc.st.sql
-		if qres.qc.st.qc != nil {
-			res.cacheControl = qres.qc.st.qc.Cache.Header
+		if qc := qres.qc.st.qc; qc != nil {
+			res.cacheControl = qc.Cache.Header
+
+			if tx != nil && gj.cache != nil && qc.Type == qcode.QTMutation {
+				res.tables = mutateTables(qc)
+			}
 		}
 	}
 
@@ -355,11 +423,32 @@ func (g *GraphJin) Reload() error {
 	gj := g.Load().(*graphjin)
 	gjNew, err := newGraphJin(gj.conf, gj.db, nil)
 	if err == nil {
+		gjNew.notify = gj.notify
+		gjNew.cache = gj.cache
 		g.Store(gjNew)
 	}
 	return err
 }
 
+// InvalidateCache evicts the cached query results for the tables changed by
+// a mutation run with GraphQLTx, call it after the transaction is committed
+// so results read before the commit are not left in the cache.
+func (g *GraphJin) InvalidateCache(res *Result) {
+	gj := g.Load().(*graphjin)
+	if gj.cache != nil && res != nil {
+		gj.cache.invalidate(res.tables)
+	}
+}
+
+// Close stops the background work of GraphJin like listening for
+// change notifications
+func (g *GraphJin) Close() {
+	gj := g.Load().(*graphjin)
+	if gj.notify != nil {
+		gj.notify.close()
+	}
+}
+
 // IsProd return true for production mode or false for development mode
 func (g *GraphJin) IsProd() bool {
 	gj := g.Load().(*graphjin)
@@ -18,11 +18,13 @@ type queryComp struct {
 }
 
 type stmt struct {
-	role *Role
-	qc   *qcode.QCode
-	md   psql.Metadata
-	va   *validator.Validate
-	sql  string
+	role  *Role
+	qc    *qcode.QCode
+	md    psql.Metadata
+	va    *validator.Validate
+	sql   string
+	stmts []psql.Stmt
+	cp    *copyStmt
 }
 
 func (gj *graphjin) compileQuery(qr queryReq, role string) (*queryComp, error) {
@@ -122,5 +124,14 @@ func (gj *graphjin) compileQueryRole(
 	}
 
 	st.sql = w.String()
+	st.stmts = st.md.Stmts(st.sql)
+
+	if gj.conf.BulkInsertThreshold > 0 && gj.dbtype == "postgres" && psql.CanCopy(st.qc) {
+		var cp copyStmt
+		if cp.ci, cp.md, err = gj.pc.CompileCopy(st.qc); err != nil {
+			return st, err
+		}
+		st.cp = &cp
+	}
 	return st, nil
 }
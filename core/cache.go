@@ -0,0 +1,232 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dosco/graphjin/core/internal/qcode"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const defaultCacheSize = 1000
+
+// ResultCache is a store for cached query results. Each entry is tagged
+// with the tables ('schema.table') the query reads from and must be removed
+// when Invalidate is called with any of those tables. Use OptionSetResultCache
+// to plug in an external store.
+type ResultCache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, val []byte, tables []string)
+	Invalidate(tables []string)
+}
+
+type resultCache struct {
+	store ResultCache
+	// incremented on every invalidate, results fetched from the database
+	// while a mutation was running are not cached
+	gen uint64
+}
+
+// OptionSetResultCache sets the store used to cache query results, caching
+// is enabled even if 'CacheResults' is not set in the config.
+func OptionSetResultCache(rc ResultCache) Option {
+	return func(s *graphjin) error {
+		s.cache = &resultCache{store: rc}
+		return nil
+	}
+}
+
+func (gj *graphjin) initCache() error {
+	if gj.cache != nil || !gj.conf.CacheResults {
+		return nil
+	}
+
+	size := gj.conf.CacheSize
+	if size == 0 {
+		size = defaultCacheSize
+	}
+
+	mc, err := newMemCache(size)
+	if err != nil {
+		return err
+	}
+
+	gj.cache = &resultCache{store: mc}
+	return nil
+}
+
+func (rc *resultCache) generation() uint64 {
+	return atomic.LoadUint64(&rc.gen)
+}
+
+func (rc *resultCache) get(key string) ([]byte, bool) {
+	return rc.store.Get(key)
+}
+
+// set caches the result only if no tables were invalidated
+// since the generation 'gen' was read
+func (rc *resultCache) set(gen uint64, key string, val []byte, tables []string) {
+	if len(tables) == 0 || rc.generation() != gen {
+		return
+	}
+	rc.store.Set(key, val, tables)
+}
+
+func (rc *resultCache) invalidate(tables []string) {
+	if len(tables) == 0 {
+		return
+	}
+	atomic.AddUint64(&rc.gen, 1)
+	rc.store.Invalidate(tables)
+}
+
+// cacheKey returns the key for a query result, the arguments passed to the
+// query include all the variables it uses (eg. user_id) so they are enough
+// to tell apart the results of the same query.
+func (c *gcontext) cacheKey(qcomp *queryComp, role string, values, sv []interface{}) (string, error) {
+	h := sha256.New()
+
+	h.Write([]byte(qcomp.qr.name))
+	h.Write([]byte{0})
+	h.Write(qcomp.qr.query)
+	h.Write([]byte{0})
+	h.Write([]byte(role))
+	h.Write([]byte{0})
+
+	v, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	h.Write(v)
+
+	// the session variables can be used by the database
+	// (eg. in views or policies) without being an argument
+	if len(sv) != 0 {
+		h.Write([]byte{0})
+		if v, err := json.Marshal(sv); err != nil {
+			return "", err
+		} else {
+			h.Write(v)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cacheable returns true if the result of the query can be cached, queries
+// within a transaction can see uncommitted changes and remote joins fetch
+// data that cannot be invalidated. Functions can read from any table.
+func (c *gcontext) cacheable(qc *qcode.QCode) bool {
+	return c.gj.cache != nil &&
+		c.tx == nil &&
+		qc.Type == qcode.QTQuery &&
+		qc.Remotes == 0 &&
+		!hasSetFunc(qc)
+}
+
+func hasSetFunc(qc *qcode.QCode) bool {
+	for _, sel := range qc.Selects {
+		if sel.SetFunc != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// mutateTables returns the tables written to by a mutation
+func mutateTables(qc *qcode.QCode) []string {
+	var tables []string
+	seen := make(map[string]struct{})
+
+	// the tables changed by a function are not known
+	// so all of them are invalidated
+	if hasSetFunc(qc) {
+		for _, t := range qc.Schema.GetTables() {
+			tables = append(tables, t.Schema+"."+t.Name)
+		}
+		return tables
+	}
+
+	for _, m := range qc.Mutates {
+		if m.Ti.Name == "" || m.Type == qcode.MTNone || m.Type == qcode.MTKeyword {
+			continue
+		}
+		k := m.Ti.Schema + "." + m.Ti.Name
+		if _, ok := seen[k]; !ok {
+			seen[k] = struct{}{}
+			tables = append(tables, k)
+		}
+	}
+	return tables
+}
+
+// memCache is the default in-memory LRU result cache
+type memCache struct {
+	sync.Mutex
+	lru    *lru.Cache
+	tables map[string]map[string]struct{}
+}
+
+type memCacheEntry struct {
+	val    []byte
+	tables []string
+}
+
+func newMemCache(size int) (*memCache, error) {
+	var err error
+
+	mc := &memCache{tables: make(map[string]map[string]struct{})}
+	mc.lru, err = lru.NewWithEvict(size, mc.onEvict)
+	return mc, err
+}
+
+func (mc *memCache) Get(key string) ([]byte, bool) {
+	if v, ok := mc.lru.Get(key); ok {
+		return v.(memCacheEntry).val, true
+	}
+	return nil, false
+}
+
+func (mc *memCache) Set(key string, val []byte, tables []string) {
+	mc.Lock()
+	defer mc.Unlock()
+
+	mc.lru.Add(key, memCacheEntry{val: val, tables: tables})
+
+	for _, t := range tables {
+		keys, ok := mc.tables[t]
+		if !ok {
+			keys = make(map[string]struct{})
+			mc.tables[t] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (mc *memCache) Invalidate(tables []string) {
+	mc.Lock()
+	defer mc.Unlock()
+
+	for _, t := range tables {
+		for k := range mc.tables[t] {
+			mc.lru.Remove(k)
+		}
+		delete(mc.tables, t)
+	}
+}
+
+// onEvict removes the evicted key from the table index, it's only called
+// from within Set and Invalidate so the lock is already held.
+func (mc *memCache) onEvict(key, value interface{}) {
+	for _, t := range value.(memCacheEntry).tables {
+		if keys, ok := mc.tables[t]; ok {
+			delete(keys, key.(string))
+			if len(keys) == 0 {
+				delete(mc.tables, t)
+			}
+		}
+	}
+}
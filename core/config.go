@@ -34,9 +34,17 @@ type Config struct {
 	ScriptPath string `mapstructure:"script_path"`
 
 	// SetUserID forces the database session variable `user.id` to
-	// be set to the user id
+	// be set to the user id within the transaction of the request,
+	// on mysql (`@user_id`) and mssql (session context `user_id`)
+	// it's set for the session
 	SetUserID bool `mapstructure:"set_user_id"`
 
+	// SessionVars sets database settings that row-level security policies
+	// can use from JWT claims (claims.<name>), header variables (header.<name>),
+	// the role (role) or the user id (user_id) of the request. Each is set as
+	// 'jwt.claims.<key>' within the transaction of the request. (Postgres only)
+	SessionVars map[string]string `mapstructure:"session_variables"`
+
 	// DefaultBlock ensures that in anonymous mode (role 'anon') all tables
 	// are blocked from queries and mutations. To open access to tables in
 	// anonymous mode they have to be added to the 'anon' role config
@@ -89,7 +97,7 @@ type Config struct {
 	// By default is set to "ByID"
 	SingularSuffix string `mapstructure:"singular_suffix"`
 
-	// Database type name Defaults to 'postgres' (options: mysql, postgres)
+	// Database type name Defaults to 'postgres' (options: mysql, mssql, sqlite, postgres)
 	DBType string `mapstructure:"db_type"`
 
 	// Log warnings and other debug information
@@ -100,6 +108,21 @@ type Config struct {
 	// Default set to 5 seconds
 	SubsPollDuration time.Duration `mapstructure:"subs_poll_every_seconds"`
 
+	// SubsNotify enables event driven subscriptions (postgres only). Only the
+	// subscriptions that query a changed table are re-checked on a notification
+	// that has the table as the payload (eg. 'public.users'). Polling is used
+	// as the fallback
+	SubsNotify bool `mapstructure:"subs_notify"`
+
+	// SubsNotifyChannel is the channel to listen on for notifications.
+	// Default set to 'graphjin_changes'
+	SubsNotifyChannel string `mapstructure:"subs_notify_channel"`
+
+	// SubsNotifyTriggers installs triggers that send the notifications on the
+	// tables used by subscriptions when they are first used. Without this
+	// the triggers must be created ahead (eg. in a migration)
+	SubsNotifyTriggers bool `mapstructure:"subs_notify_triggers"`
+
 	// DefaultLimit sets the default max limit (number of rows) when a
 	// limit is not defined in the query or the table role config
 	// Default set to 20
@@ -125,6 +148,50 @@ type Config struct {
 
 	DisableDBWatcher bool `mapstructure:"disable_db_watcher"`
 
+	// MaxDepth sets the max nesting depth of selectors in a query
+	MaxDepth int `mapstructure:"max_depth"`
+
+	// MaxRootFields sets the max number of root selectors in a query
+	MaxRootFields int `mapstructure:"max_root_fields"`
+
+	// MaxSelectors sets the max number of selectors in a query.
+	// Default set to 100
+	MaxSelectors int `mapstructure:"max_selectors"`
+
+	// MaxCost sets the max cost of a query for all roles, the cost is
+	// the number of rows a query can return (limits multiplied across
+	// nested selectors) weighted by the table cost. Can be overridden
+	// per role
+	MaxCost int `mapstructure:"max_cost"`
+
+	// CacheResults enables caching of query results, entries are evicted
+	// when a mutation run through GraphJin writes to a table the query
+	// reads from. Changes made to the database outside of GraphJin are
+	// not seen by the cache
+	CacheResults bool `mapstructure:"cache_results"`
+
+	// CacheSize sets the max number of results held by the default
+	// in-memory cache. Default set to 1000
+	CacheSize int `mapstructure:"cache_size"`
+
+	// BulkInsertThreshold enables a fast path for plain (not nested) bulk
+	// inserts with at least this many rows. The rows are streamed using
+	// COPY into a temporary table and inserted from there so presets and
+	// insert filters still apply. Only for postgres using the pgx driver and
+	// not within a transaction passed to GraphQLTx. Disabled when not set
+	BulkInsertThreshold int `mapstructure:"bulk_insert_threshold"`
+
+	// AuditTable enables the audit log. Mutations write a row to this table
+	// for every row they change as part of the same statement, postgres only.
+	// The table must have the columns operation, action, role, user_id,
+	// table_name, row_id, old_row and new_row
+	AuditTable string `mapstructure:"audit_table"`
+
+	// Functions are database functions that can be called as mutations,
+	// only roles that list a function in their 'functions' can call it.
+	// Postgres only
+	Functions []Function
+
 	rtmap map[string]refunc
 	tmap  map[string]qcode.TConfig
 }
@@ -138,6 +205,38 @@ type Table struct {
 	Blocklist []string
 	Columns   []Column
 	OrderBy   map[string][]string `mapstructure:"order_by"`
+
+	// Cost is the weight of a row from this table when computing the
+	// cost of a query. Default set to 1
+	Cost int
+
+	// SoftDelete makes delete mutations set a column instead of deleting
+	// the rows, rows with the column set are left out of all queries
+	SoftDelete *SoftDelete `mapstructure:"soft_delete"`
+
+	// VersionColumn is an integer or timestamp column used for optimistic
+	// concurrency. Updates and upserts must include the version that was
+	// read, it's bumped on every change and a mismatch fails the mutation
+	// with a CONFLICT error. An update matching several rows fails unless
+	// all of them have the version sent
+	VersionColumn string `mapstructure:"version_column"`
+}
+
+// Function struct defines a database function called as a mutation
+type Function struct {
+	Name   string
+	Schema string
+
+	// ReturnType is the table the function returns rows from, it must
+	// return a row or a set of rows of it. The rows returned can be
+	// selected from like a query
+	ReturnType string `mapstructure:"return_type"`
+}
+
+// SoftDelete struct defines the column used to soft delete rows
+type SoftDelete struct {
+	// Column is set to the current time when a row is deleted
+	Column string
 }
 
 // Column struct defines a database column
@@ -152,10 +251,19 @@ type Column struct {
 
 // Role struct contains role specific access control values for for all database tables
 type Role struct {
-	Name   string
-	Match  string
-	Tables []RoleTable
-	tm     map[string]*RoleTable
+	Name    string
+	Match   string
+	MaxCost int `mapstructure:"max_cost"`
+	Tables  []RoleTable
+
+	// Inherits is the list of roles whose tables and functions this role
+	// also gets. Its own table entries override the inherited ones for
+	// each operation they set, a table inherited as read_only stays so
+	Inherits []string
+
+	// Functions is the list of functions the role is allowed to call
+	Functions []string
+	tm        map[string]*RoleTable
 }
 
 // RoleTable struct contains role specific access control values for a database table
@@ -178,6 +286,16 @@ type Query struct {
 	Columns          []string
 	DisableFunctions bool `mapstructure:"disable_functions"`
 	Block            bool
+
+	// WithDeleted allows the with_deleted argument to include
+	// the soft deleted rows of the table
+	WithDeleted bool `mapstructure:"with_deleted"`
+
+	// Masks redacts the values returned for a column, the column name maps
+	// to one of 'partial_email', 'last4', 'null' or a 'sql:' expression.
+	// Masked columns cannot be used in where, search, order_by, distinct_on,
+	// group_by or having
+	Masks map[string]string
 }
 
 // Insert struct contains access control values for insert operations
@@ -196,6 +314,8 @@ type Update struct {
 	Block   bool
 }
 
+// Upsert struct contains access control values for upsert operations, the
+// filters, columns and presets not set are taken from the update config
 type Upsert struct {
 	Filters []string
 	Columns []string
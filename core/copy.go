@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/dosco/graphjin/core/internal/psql"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+)
+
+// copyStmt is the version of a plain bulk insert that
+// reads its rows from a table filled using COPY
+type copyStmt struct {
+	ci psql.CopyInsert
+	md psql.Metadata
+}
+
+// copyRows returns the rows of a bulk insert as text values in the order
+// of the copy columns, nil is returned if the insert must not use COPY.
+func (c *gcontext) copyRows(conn dbConn, qcomp *queryComp) [][]interface{} {
+	cp := qcomp.st.cp
+
+	if cp == nil {
+		return nil
+	}
+
+	// a transaction passed in by the caller does not give
+	// access to the connection it's running on
+	if sc, _ := copyConn(conn); sc == nil {
+		return nil
+	}
+
+	if _, ok := c.gj.db.Driver().(*stdlib.Driver); !ok {
+		return nil
+	}
+
+	var vars map[string]json.RawMessage
+	var list []map[string]json.RawMessage
+
+	// errors are left to be reported by the regular insert
+	if err := json.Unmarshal(qcomp.qr.vars, &vars); err != nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(vars[cp.ci.Var], &list); err != nil {
+		return nil
+	}
+
+	if len(list) < c.gj.conf.BulkInsertThreshold {
+		return nil
+	}
+
+	rows := make([][]interface{}, len(list))
+
+	for i, row := range list {
+		vals := make([]interface{}, len(cp.ci.Cols))
+
+		for j, key := range cp.ci.Keys {
+			v, ok := row[key]
+			if !ok || string(v) == "null" {
+				continue
+			}
+			if v[0] == '"' {
+				var s string
+				if err := json.Unmarshal(v, &s); err != nil {
+					return nil
+				}
+				vals[j] = s
+			} else {
+				vals[j] = string(v)
+			}
+		}
+		rows[i] = vals
+	}
+
+	return rows
+}
+
+// copyConn returns the connection to run the copy on and true if
+// a transaction with the session variables set is open on it
+func copyConn(conn dbConn) (*sql.Conn, bool) {
+	switch v := conn.(type) {
+	case *sql.Conn:
+		return v, false
+	case txConn:
+		return v.conn, true
+	}
+	return nil, false
+}
+
+// copier is implemented by both *pgx.Conn and pgx.Tx
+type copier interface {
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// execCopy copies the rows into a temporary table and runs the insert
+// from it, all within a single transaction. The transaction already
+// open on the connection is used if there is one.
+func (c *gcontext) execCopy(conn dbConn, qcomp *queryComp, rows [][]interface{}, data *[]byte) error {
+	cp := qcomp.st.cp
+
+	ar, err := c.gj.argList(c, cp.md, qcomp.qr.vars, c.rc)
+	if err != nil {
+		return err
+	}
+
+	sc, inTx := copyConn(conn)
+
+	if inTx {
+		// the copy table is dropped when the transaction commits
+		if _, err := conn.ExecContext(c, cp.ci.Create); err != nil {
+			return err
+		}
+		err = sc.Raw(func(dc interface{}) error {
+			return c.copyInsert(dc.(*stdlib.Conn).Conn(), cp, rows, ar.values, data)
+		})
+	} else {
+		err = sc.Raw(func(dc interface{}) error {
+			tx, err := dc.(*stdlib.Conn).Conn().Begin(c)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback(c) //nolint:errcheck
+
+			if _, err := tx.Exec(c, cp.ci.Create); err != nil {
+				return err
+			}
+			if err := c.copyInsert(tx, cp, rows, ar.values, data); err != nil {
+				return err
+			}
+			return tx.Commit(c)
+		})
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+func (c *gcontext) copyInsert(cr copier, cp *copyStmt, rows [][]interface{}, values []interface{}, data *[]byte) error {
+	_, err := cr.CopyFrom(c,
+		pgx.Identifier{psql.CopyTable},
+		cp.ci.Cols,
+		pgx.CopyFromRows(rows))
+	if err != nil {
+		return err
+	}
+	return cr.QueryRow(c, cp.ci.SQL, values...).Scan(data)
+}
@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
 	"time"
 
 	"github.com/avast/retry-go"
@@ -55,12 +54,26 @@ type gcontext struct {
 	context.Context
 
 	gj   *graphjin
+	tx   *sql.Tx
 	op   qcode.QType
 	rc   *ReqConfig
 	sc   *script
 	name string
 }
 
+// dbConn is implemented by both *sql.Conn and *sql.Tx this allows
+// queries to run either on a pooled connection or inside a transaction
+type dbConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txConn is a transaction along with the connection it was started on
+type txConn struct {
+	*sql.Tx
+	conn *sql.Conn
+}
+
 type queryResp struct {
 	qc   *queryComp
 	data []byte
@@ -71,8 +84,6 @@ func (gj *graphjin) initDiscover() error {
 	switch gj.conf.DBType {
 	case "":
 		gj.dbtype = "postgres"
-	case "mssql":
-		gj.dbtype = "mysql"
 	default:
 		gj.dbtype = gj.conf.DBType
 	}
@@ -135,6 +146,10 @@ func (gj *graphjin) _initSchema() error {
 		return err
 	}
 
+	if err := addFunctions(gj.conf, gj.dbinfo); err != nil {
+		return err
+	}
+
 	gj.schema, err = sdata.NewDBSchema(
 		gj.dbinfo,
 		getDBTableAliases(gj.conf))
@@ -160,6 +175,20 @@ func (gj *graphjin) initCompilers() error {
 		EnableCamelcase:  gj.conf.EnableCamelcase,
 		EnableInflection: gj.conf.EnableInflection,
 		DBSchema:         gj.schema.DBSchema(),
+		MaxDepth:         gj.conf.MaxDepth,
+		MaxRootFields:    gj.conf.MaxRootFields,
+		MaxSelectors:     gj.conf.MaxSelectors,
+		MaxCost:          gj.conf.MaxCost,
+	}
+
+	for _, r := range gj.conf.Roles {
+		if r.MaxCost == 0 {
+			continue
+		}
+		if qcc.RoleMaxCost == nil {
+			qcc.RoleMaxCost = make(map[string]int)
+		}
+		qcc.RoleMaxCost[r.Name] = r.MaxCost
 	}
 
 	if gj.allowList != nil && gj.prod {
@@ -175,15 +204,42 @@ func (gj *graphjin) initCompilers() error {
 		return err
 	}
 
+	if err := gj.initAudit(); err != nil {
+		return err
+	}
+
 	gj.pc = psql.NewCompiler(psql.Config{
-		Vars:      gj.conf.Vars,
-		DBType:    gj.schema.DBType(),
-		DBVersion: gj.schema.DBVersion(),
+		Vars:       gj.conf.Vars,
+		DBType:     gj.schema.DBType(),
+		DBVersion:  gj.schema.DBVersion(),
+		AuditTable: gj.conf.AuditTable,
 	})
 	return nil
 }
 
-func (gj *graphjin) executeRoleQuery(c context.Context, conn *sql.Conn, vars []byte, rc *ReqConfig) (string, error) {
+func (gj *graphjin) initAudit() error {
+	if gj.conf.AuditTable == "" {
+		return nil
+	}
+
+	if gj.dbtype != "postgres" {
+		return errors.New("audit: only supported with postgres")
+	}
+
+	ti, err := gj.schema.Find("", gj.conf.AuditTable)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	for _, col := range psql.AuditCols {
+		if _, err := ti.GetColumn(col); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+	}
+	return nil
+}
+
+func (gj *graphjin) executeRoleQuery(c context.Context, conn dbConn, vars []byte, rc *ReqConfig) (string, error) {
 	var role string
 	var ar args
 	var err error
@@ -191,10 +247,12 @@ func (gj *graphjin) executeRoleQuery(c context.Context, conn *sql.Conn, vars []b
 	md := gj.roleStmtMD
 
 	if conn == nil {
-		if conn, err = gj.db.Conn(c); err != nil {
+		c1, err := gj.db.Conn(c)
+		if err != nil {
 			return role, err
 		}
-		defer conn.Close()
+		defer c1.Close()
+		conn = c1
 	}
 
 	if c.Value(UserIDKey) == nil {
@@ -219,7 +277,10 @@ func (c *gcontext) execQuery(qr queryReq, role string) (queryResp, error) {
 			return err
 		},
 		retry.Context(c),
-		retry.RetryIf(retryIfDBError),
+		retry.RetryIf(func(err error) bool {
+			// a broken connection cannot be retried within a transaction
+			return c.tx == nil && retryIfDBError(err)
+		}),
 		retry.Attempts(3),
 		retry.LastErrorOnly(true),
 	)
@@ -257,14 +318,32 @@ func (c *gcontext) resolveSQL(qr queryReq, role string) (queryResp, error) {
 
 	res.role = role
 
-	conn, err := c.gj.db.Conn(c)
-	if err != nil {
-		return res, err
+	var conn dbConn
+	var tx *sql.Tx
+
+	if c.tx != nil {
+		conn = c.tx
+	} else {
+		c1, err := c.gj.db.Conn(c)
+		if err != nil {
+			return res, err
+		}
+		defer c1.Close()
+		conn = c1
+
+		// session variables are set local to a transaction so
+		// they don't leak to the next user of the connection
+		if len(c.gj.sessVars) != 0 {
+			if tx, err = c1.BeginTx(c, nil); err != nil {
+				return res, err
+			}
+			defer tx.Rollback() //nolint:errcheck
+			conn = txConn{tx, c1}
+		}
 	}
-	defer conn.Close()
 
-	if c.gj.conf.SetUserID {
-		if err := c.setLocalUserID(conn); err != nil {
+	if c.gj.conf.SetUserID && c.gj.dbtype != "postgres" {
+		if err := c.gj.setSessionUserID(c, conn); err != nil {
 			return res, err
 		}
 	}
@@ -273,6 +352,11 @@ func (c *gcontext) resolveSQL(qr queryReq, role string) (queryResp, error) {
 		res.role = v.(string)
 
 	} else if c.gj.abacEnabled {
+		// the role query can use the session variables too
+		sv := c.gj.sessionValues(c, res.role, c.rc)
+		if err := c.gj.setSessionVars(c, conn, sv); err != nil {
+			return res, err
+		}
 		res.role, err = c.gj.executeRoleQuery(c, conn, qr.vars, c.rc)
 	}
 
@@ -280,9 +364,14 @@ func (c *gcontext) resolveSQL(qr queryReq, role string) (queryResp, error) {
 		return res, err
 	}
 
+	sv := c.gj.sessionValues(c, res.role, c.rc)
+	if err := c.gj.setSessionVars(c, conn, sv); err != nil {
+		return res, err
+	}
+
 	var qcomp *queryComp
 	if qcomp, err = c.gj.compileQuery(qr, res.role); err != nil {
-		return res, err
+		return res, withCode(compileErrorCode(err), err)
 	}
 	res.qc = qcomp
 
@@ -292,35 +381,49 @@ func (c *gcontext) resolveSQL(qr queryReq, role string) (queryResp, error) {
 
 	args, err := c.gj.argList(c, qcomp.st.md, qcomp.qr.vars, c.rc)
 	if err != nil {
-		return res, err
+		return res, withCode(ErrCodeBadUserInput, err)
 	}
 
-	var stime time.Time
+	qc := qcomp.st.qc
 
-	if c.gj.conf.EnableTracing {
-		stime = time.Now()
+	var ckey string
+	var cgen uint64
+	var hit bool
+
+	if c.cacheable(qc) {
+		if ckey, err = c.cacheKey(qcomp, res.role, args.values, sv); err != nil {
+			return res, err
+		}
+		res.data, hit = c.gj.cache.get(ckey)
+		cgen = c.gj.cache.generation()
 	}
 
-	row := conn.QueryRowContext(c, qcomp.st.sql, args.values...)
+	if !hit {
+		res.data, err = c.execSQL(conn, qcomp, args.values)
 
-	if c.gj.conf.EnableTracing {
-		c.gj.log.Printf("core.resolveSql - QueryRowContext time: %f", time.Since(stime).Seconds())
-	}
+		if err == nil && tx != nil {
+			err = tx.Commit()
+		}
 
-	if err := row.Scan(&res.data); err == sql.ErrNoRows {
-		return res, nil
-	} else if err != nil {
-		return res, err
-	}
+		// evict cached results for the tables written to once the changes
+		// are committed, changes made within a transaction passed in by the
+		// caller are evicted again by it after its commit (InvalidateCache)
+		if c.gj.cache != nil && qc.Type == qcode.QTMutation {
+			c.gj.cache.invalidate(mutateTables(qc))
+		}
 
-	qc := qcomp.st.qc
+		if err != nil {
+			return res, err
+		}
 
-	cur, err := c.gj.encryptCursor(qc, res.data)
-	if err != nil {
-		return res, err
-	}
+		if res.data == nil {
+			return res, nil
+		}
 
-	res.data = cur.data
+		if ckey != "" {
+			c.gj.cache.set(cgen, ckey, res.data, queryTables(qc))
+		}
+	}
 
 	if !c.gj.prod && c.gj.allowList != nil {
 		if err := c.saveToAllowList(qc, string(qcomp.qr.query)); err != nil {
@@ -344,6 +447,123 @@ func (c *gcontext) resolveSQL(qr queryReq, role string) (queryResp, error) {
 	return res, nil
 }
 
+// execSQL runs the compiled query and returns its result with the cursors
+// encrypted, nil is returned if the query returned no rows.
+func (c *gcontext) execSQL(conn dbConn, qcomp *queryComp, values []interface{}) ([]byte, error) {
+	var data []byte
+	var err error
+
+	qc := qcomp.st.qc
+
+	var stime time.Time
+
+	if c.gj.conf.EnableTracing {
+		stime = time.Now()
+	}
+
+	if rows := c.copyRows(conn, qcomp); rows != nil {
+		err = c.execCopy(conn, qcomp, rows, &data)
+	} else if len(qcomp.st.stmts) != 0 {
+		err = c.execStmts(conn, qcomp, values, &data)
+	} else {
+		err = conn.QueryRowContext(c, qcomp.st.sql, values...).Scan(&data)
+	}
+
+	if c.gj.conf.EnableTracing {
+		c.gj.log.Printf("core.resolveSql - QueryRowContext time: %f", time.Since(stime).Seconds())
+	}
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, withCode(dbErrorCode(err), err)
+	}
+
+	cur, err := c.gj.encryptCursor(qc, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return cur.data, nil
+}
+
+// execStmts runs the statements of a query that compiled to more than one
+// (eg. mysql mutations) in order within a transaction and scans the result
+// of the last one. The session variables set by them are cleared afterwards.
+func (c *gcontext) execStmts(conn dbConn, qcomp *queryComp, values []interface{}, data *[]byte) (err error) {
+	var tx *sql.Tx
+
+	stmts := qcomp.st.stmts
+
+	if r := qcomp.st.md.Reset(); r != "" {
+		rconn := conn
+		defer func() {
+			if _, err1 := rconn.ExecContext(c, r); err == nil {
+				err = err1
+			}
+		}()
+	}
+
+	if c1, ok := conn.(*sql.Conn); ok {
+		if tx, err = c1.BeginTx(c, nil); err != nil {
+			return err
+		}
+		defer tx.Rollback() //nolint:errcheck
+		conn = tx
+	}
+
+	n := len(stmts) - 1
+
+	for i := 0; i < n; i++ {
+		if !stmts[i].Each {
+			st := stmts[i]
+			if _, err := conn.ExecContext(c, st.SQL, values[st.PStart:st.PEnd]...); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// the statements run for every row are run together
+		j := i
+		for j < n && stmts[j].Each {
+			j++
+		}
+
+		var vars map[string]json.RawMessage
+		var rows []json.RawMessage
+
+		if err := json.Unmarshal(qcomp.qr.vars, &vars); err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(vars[qcomp.st.qc.ActionVar], &rows); err != nil {
+			return err
+		}
+
+		for range rows {
+			for _, st := range stmts[i:j] {
+				if _, err := conn.ExecContext(c, st.SQL, values[st.PStart:st.PEnd]...); err != nil {
+					return err
+				}
+			}
+		}
+		i = j - 1
+	}
+
+	st := stmts[n]
+	err = conn.QueryRowContext(c, st.SQL, values[st.PStart:st.PEnd]...).Scan(data)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
 func (c *gcontext) handleVars(qcomp *queryComp, res *queryResp) error {
 	var vars map[string]interface{}
 	qc := qcomp.st.qc
@@ -355,7 +575,7 @@ func (c *gcontext) handleVars(qcomp *queryComp, res *queryResp) error {
 
 	if len(qr.vars) != 0 {
 		if err := json.Unmarshal(qr.vars, &vars); err != nil {
-			return err
+			return withCode(ErrCodeBadUserInput, err)
 		}
 	}
 
@@ -370,7 +590,7 @@ func (c *gcontext) handleVars(qcomp *queryComp, res *queryResp) error {
 		}
 
 		if len(errs) != 0 {
-			return errors.New("validation failed")
+			return withCode(ErrCodeBadUserInput, errors.New("validation failed"))
 		}
 	}
 
@@ -405,24 +625,6 @@ func (c *gcontext) saveToAllowList(qc *qcode.QCode, query string) error {
 	return c.gj.allowList.Set(av, query, qc.Metadata)
 }
 
-func (c *gcontext) setLocalUserID(conn *sql.Conn) error {
-	var err error
-
-	if v := c.Value(UserIDKey); v == nil {
-		return nil
-	} else {
-		switch v1 := v.(type) {
-		case string:
-			_, err = conn.ExecContext(c, `SET SESSION "user.id" = '`+v1+`'`)
-
-		case int:
-			_, err = conn.ExecContext(c, `SET SESSION "user.id" = `+strconv.Itoa(v1))
-		}
-	}
-
-	return err
-}
-
 func (r *Result) Operation() OpType {
 	switch r.op {
 	case qcode.QTQuery:
@@ -508,5 +710,5 @@ func (c *gcontext) debugLog(st *stmt) {
 }
 
 func retryIfDBError(err error) bool {
-	return (err == driver.ErrBadConn)
+	return errors.Is(err, driver.ErrBadConn)
 }
@@ -26,6 +26,7 @@ type dbinfo struct {
 var (
 	dbParam string
 	dbType  string
+	dbURL   string
 	db      *sql.DB
 )
 
@@ -113,7 +114,9 @@ func TestMain(m *testing.M) {
 			}
 		}()
 
-		db, err = sql.Open(v.driver, fmt.Sprintf(v.connstr, con.DefaultAddress()))
+		dbURL = fmt.Sprintf(v.connstr, con.DefaultAddress())
+
+		db, err = sql.Open(v.driver, dbURL)
 		if err != nil {
 			panic(err)
 		}
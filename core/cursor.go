@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 
 	"github.com/dosco/graphjin/core/internal/crypto"
+	"github.com/dosco/graphjin/core/internal/psql"
 	"github.com/dosco/graphjin/core/internal/qcode"
 	"github.com/dosco/graphjin/internal/jsn"
 )
@@ -18,8 +19,15 @@ func (gj *graphjin) encryptCursor(qc *qcode.QCode, data []byte) (cursors, error)
 	var keys [][]byte
 	cur := cursors{data: data}
 
+	// the end cursors of connections are used as the
+	// cursor value for subscriptions
+	ends := make(map[string]struct{})
+
 	for _, sel := range qc.Selects {
-		if sel.Paging.Cursor {
+		switch {
+		case sel.Connection != nil:
+			keys = connCursorKeys(keys, ends, sel.Connection.Fields)
+		case sel.Paging.Cursor:
 			keys = append(keys, []byte((sel.FieldName + "_cursor")))
 		}
 	}
@@ -34,6 +42,12 @@ func (gj *graphjin) encryptCursor(qc *qcode.QCode, data []byte) (cursors, error)
 	for i, f := range from {
 		to[i].Key = f.Key
 
+		// connection cursors are renamed to the field name
+		conn := bytes.HasPrefix(f.Key, []byte(psql.CursorPrefix))
+		if conn {
+			to[i].Key = f.Key[len(psql.CursorPrefix):]
+		}
+
 		if f.Value[0] != '"' || f.Value[len(f.Value)-1] != '"' {
 			continue
 		}
@@ -42,7 +56,7 @@ func (gj *graphjin) encryptCursor(qc *qcode.QCode, data []byte) (cursors, error)
 			val := f.Value[1 : len(f.Value)-1]
 			// save a copy of the first cursor value to use
 			// with subscriptions when fetching the next set
-			if cur.value == "" {
+			if _, ok := ends[string(f.Key)]; cur.value == "" && (!conn || ok) {
 				cur.value = string(val)
 			}
 
@@ -71,6 +85,22 @@ func (gj *graphjin) encryptCursor(qc *qcode.QCode, data []byte) (cursors, error)
 	return cur, nil
 }
 
+func connCursorKeys(keys [][]byte, ends map[string]struct{}, fields []qcode.ConnField) [][]byte {
+	for _, f := range fields {
+		switch f.Name {
+		case "cursor", "startCursor", "endCursor":
+			k := psql.CursorPrefix + f.FieldName
+			if f.Name == "endCursor" {
+				ends[k] = struct{}{}
+			}
+			keys = append(keys, []byte(k))
+		default:
+			keys = connCursorKeys(keys, ends, f.Fields)
+		}
+	}
+	return keys
+}
+
 func (gj *graphjin) decrypt(data string) ([]byte, error) {
 	v, err := base64.StdEncoding.DecodeString(data)
 	if err != nil {
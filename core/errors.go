@@ -0,0 +1,178 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/dosco/graphjin/core/internal/graph"
+	"github.com/dosco/graphjin/core/internal/psql"
+	"github.com/dosco/graphjin/core/internal/qcode"
+)
+
+// Error codes set in the extensions of an error, these help clients
+// tell apart the different kinds of failures.
+const (
+	ErrCodeParseFailed            = "GRAPHQL_PARSE_FAILED"
+	ErrCodeValidationFailed       = "GRAPHQL_VALIDATION_FAILED"
+	ErrCodePersistedQueryNotFound = "PERSISTED_QUERY_NOT_FOUND"
+	ErrCodeBadUserInput           = "BAD_USER_INPUT"
+	ErrCodeUnauthenticated        = "UNAUTHENTICATED"
+	ErrCodePermissionDenied       = "PERMISSION_DENIED"
+	ErrCodeConstraintViolation    = "CONSTRAINT_VIOLATION"
+	ErrCodeConflict               = "CONFLICT"
+	ErrCodeInternal               = "INTERNAL_SERVER_ERROR"
+)
+
+// Error is a GraphQL error as described by the spec, locations point to
+// where in the query the error was found and path to the failing selector.
+type Error struct {
+	Message    string           `json:"message"`
+	Locations  []ErrorLocation  `json:"locations,omitempty"`
+	Path       []string         `json:"path,omitempty"`
+	Extensions *ErrorExtensions `json:"extensions,omitempty"`
+}
+
+type ErrorLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type ErrorExtensions struct {
+	Code string `json:"code"`
+}
+
+// codeError attaches an error code to an error
+type codeError struct {
+	code string
+	err  error
+}
+
+func (e *codeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codeError) Unwrap() error {
+	return e.err
+}
+
+func withCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var ce *codeError
+	if errors.As(err, &ce) {
+		return err
+	}
+	return &codeError{code: code, err: err}
+}
+
+// NewError converts an error returned by GraphJin into a GraphQL error
+// with the location, path and error code filled in where known.
+func NewError(err error) Error {
+	e := Error{Message: err.Error()}
+	code := ErrCodeInternal
+
+	var ce *codeError
+	if errors.As(err, &ce) {
+		code = ce.code
+	}
+
+	var pe *graph.ParseError
+	if errors.As(err, &pe) {
+		e.Locations = []ErrorLocation{{Line: pe.Line, Column: pe.Column}}
+		code = ErrCodeParseFailed
+	}
+
+	var qe *qcode.Error
+	if errors.As(err, &qe) {
+		e.Path = qe.Path
+	}
+
+	e.Extensions = &ErrorExtensions{Code: code}
+	return e
+}
+
+// compileErrorCode returns the code of an error from compiling a query,
+// queries the role is not allowed to run are denied permission.
+func compileErrorCode(err error) string {
+	var pe *qcode.PermissionError
+	if errors.As(err, &pe) {
+		return ErrCodePermissionDenied
+	}
+	return ErrCodeValidationFailed
+}
+
+// dbErrorCode maps database errors to an error code, the database
+// drivers are not imported here so errors are matched by the methods
+// they implement or by their message.
+func dbErrorCode(err error) string {
+	// postgres (pgx, lib/pq)
+	var se interface{ SQLState() string }
+	if errors.As(err, &se) {
+		return sqlStateCode(se.SQLState(), err.Error())
+	}
+
+	// sql server
+	var ne interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &ne) {
+		switch ne.SQLErrorNumber() {
+		case 515, 547, 2601, 2627:
+			return ErrCodeConstraintViolation
+		case 229, 230, 262, 300:
+			return ErrCodePermissionDenied
+		}
+		return ErrCodeInternal
+	}
+
+	msg := err.Error()
+
+	// mysql errors are formatted as "Error 1062: ..."
+	if s := strings.TrimPrefix(msg, "Error "); len(s) != len(msg) {
+		if i := strings.IndexAny(s, ": ("); i != -1 {
+			if n, err := strconv.Atoi(s[:i]); err == nil {
+				return mysqlErrorCode(n)
+			}
+		}
+	}
+
+	// sqlite errors such as "UNIQUE constraint failed: users.email"
+	if strings.Contains(msg, "constraint failed") {
+		return ErrCodeConstraintViolation
+	}
+
+	return ErrCodeInternal
+}
+
+// sqlStateCode maps a postgres SQLSTATE to an error code, the errors raised
+// by the checks added to mutations reuse existing codes so they are told
+// apart by their message.
+func sqlStateCode(state, msg string) string {
+	switch {
+	case strings.HasPrefix(state, "23"):
+		return ErrCodeConstraintViolation
+	case state == "42501":
+		return ErrCodePermissionDenied
+	case state == psql.InsertCheckFailed && strings.Contains(msg, psql.InsertCheckMsg):
+		return ErrCodePermissionDenied
+	case state == psql.VersionConflict && strings.Contains(msg, psql.VersionConflictMsg):
+		return ErrCodeConflict
+	case strings.HasPrefix(state, "28"):
+		return ErrCodeUnauthenticated
+	case strings.HasPrefix(state, "22"):
+		return ErrCodeBadUserInput
+	}
+	return ErrCodeInternal
+}
+
+func mysqlErrorCode(n int) string {
+	switch n {
+	case 1048, 1062, 1169, 1216, 1217, 1451, 1452, 1557, 3819:
+		return ErrCodeConstraintViolation
+	case 1044, 1142, 1143, 1227:
+		return ErrCodePermissionDenied
+	case 1045:
+		return ErrCodeUnauthenticated
+	}
+	return ErrCodeInternal
+}
@@ -0,0 +1,36 @@
+package core
+
+import "testing"
+
+type sqlStateError struct {
+	state, msg string
+}
+
+func (e sqlStateError) Error() string    { return e.msg }
+func (e sqlStateError) SQLState() string { return e.state }
+
+func TestDBErrorCode(t *testing.T) {
+	tests := []struct {
+		err  sqlStateError
+		code string
+	}{
+		{sqlStateError{"23505", `duplicate key value violates unique constraint "users_email_key"`},
+			ErrCodeConstraintViolation},
+		{sqlStateError{"42501", `permission denied for table users`},
+			ErrCodePermissionDenied},
+		{sqlStateError{"42704", `role "insert check failed on products: 1 row(s)" does not exist`},
+			ErrCodePermissionDenied},
+		{sqlStateError{"42704", `type "geometry" does not exist`},
+			ErrCodeInternal},
+		{sqlStateError{"3F000", `schema "version conflict on products: 1 row(s)" does not exist`},
+			ErrCodeConflict},
+		{sqlStateError{"3F000", `no schema has been selected to create in`},
+			ErrCodeInternal},
+	}
+
+	for _, v := range tests {
+		if code := dbErrorCode(v.err); code != v.code {
+			t.Errorf("%s: expected '%s' got '%s'", v.err.msg, v.code, code)
+		}
+	}
+}
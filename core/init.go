@@ -136,7 +136,11 @@ func addTableInfo(c *Config, t Table) error {
 	if c.tmap == nil {
 		c.tmap = make(map[string]qcode.TConfig)
 	}
-	c.tmap[(t.Schema + t.Name)] = qcode.TConfig{OrderBy: obm}
+	tc := qcode.TConfig{OrderBy: obm, Cost: t.Cost, VersionColumn: t.VersionColumn}
+	if t.SoftDelete != nil {
+		tc.SoftDelete = t.SoftDelete.Column
+	}
+	c.tmap[(t.Schema + t.Name)] = tc
 	return nil
 }
 
@@ -186,6 +190,18 @@ func updateTable(conf *Config, di *sdata.DBInfo, t Table) error {
 		return fmt.Errorf("table: %w", err)
 	}
 
+	if t.SoftDelete != nil {
+		if _, err := di.GetColumn(t.Schema, t.Name, t.SoftDelete.Column); err != nil {
+			return fmt.Errorf("soft_delete: %w", err)
+		}
+	}
+
+	if t.VersionColumn != "" {
+		if _, err := di.GetColumn(t.Schema, t.Name, t.VersionColumn); err != nil {
+			return fmt.Errorf("version_column: %w", err)
+		}
+	}
+
 	for _, c := range t.Columns {
 		c1, err := di.GetColumn(t.Schema, t.Name, c.Name)
 		if err != nil {
@@ -346,18 +362,182 @@ func addForeignKey(conf *Config, di *sdata.DBInfo, c Column, t Table) error {
 	return nil
 }
 
+func addFunctions(conf *Config, di *sdata.DBInfo) error {
+	if len(conf.Functions) != 0 && di.Type != "postgres" {
+		return fmt.Errorf("functions: only supported with postgres")
+	}
+
+	for _, f := range conf.Functions {
+		if err := addFunction(di, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFunction adds a function that changes data as a function returning
+// the rows of its return type table
+func addFunction(di *sdata.DBInfo, f Function) error {
+	if f.ReturnType == "" {
+		return fmt.Errorf("function: return_type required for '%s'", f.Name)
+	}
+
+	schema := f.Schema
+	if schema == "" {
+		schema = di.Schema
+	}
+
+	if _, err := di.GetTable(schema, f.ReturnType); err != nil {
+		return fmt.Errorf("function: %w", err)
+	}
+
+	// functions returning a set of rows are already discovered
+	for i, sf := range di.SetFunctions {
+		if sf.Schema != schema || sf.Name != f.Name {
+			continue
+		}
+		if sf.Table != f.ReturnType {
+			return fmt.Errorf("function: '%s' does not return rows from '%s'",
+				f.Name, f.ReturnType)
+		}
+		di.SetFunctions[i].Mutation = true
+		return nil
+	}
+
+	// the others must return a single row of the table
+	var rt string
+	for _, fn := range di.Functions {
+		if fn.Schema != schema || fn.Name != f.Name {
+			continue
+		}
+		if fn.Type != f.ReturnType {
+			rt = fn.Type
+			continue
+		}
+		di.SetFunctions = append(di.SetFunctions, sdata.DBSetFunction{
+			Schema:   schema,
+			Name:     f.Name,
+			Table:    f.ReturnType,
+			Params:   fn.Params,
+			Mutation: true,
+		})
+		return nil
+	}
+
+	if rt != "" {
+		return fmt.Errorf("function: '%s' returns '%s' not rows from '%s'",
+			f.Name, rt, f.ReturnType)
+	}
+	return fmt.Errorf("function: '%s.%s' not found", schema, f.Name)
+}
+
 func addRoles(c *Config, qc *qcode.Compiler) error {
 	for _, r := range c.Roles {
+		r, err := inheritRole(c, r, nil)
+		if err != nil {
+			return err
+		}
 		for _, t := range r.Tables {
 			if err := addRole(qc, r, t, c.DefaultBlock); err != nil {
 				return err
 			}
 		}
+		for _, f := range r.Functions {
+			if err := qc.AddRoleFunction(r.Name, f); err != nil {
+				return fmt.Errorf("role '%s': %w", r.Name, err)
+			}
+		}
 	}
 
 	return nil
 }
 
+// inheritRole returns the role with the tables and functions of the roles
+// it inherits merged in. The role's own table entries replace the inherited
+// config of each operation they set
+func inheritRole(c *Config, r Role, seen map[string]struct{}) (Role, error) {
+	if len(r.Inherits) == 0 {
+		return r, nil
+	}
+
+	k := strings.ToLower(r.Name)
+	if _, ok := seen[k]; ok {
+		return r, fmt.Errorf("role '%s': inheritance cycle", r.Name)
+	}
+	if seen == nil {
+		seen = make(map[string]struct{})
+	}
+	seen[k] = struct{}{}
+	defer delete(seen, k)
+
+	var tables []RoleTable
+	var funcs []string
+	tm := make(map[string]int)
+
+	addTable := func(t RoleTable) {
+		tk := strings.ToLower(t.Schema + ":" + t.Name)
+		i, ok := tm[tk]
+		if !ok {
+			tm[tk] = len(tables)
+			tables = append(tables, t)
+			return
+		}
+		tables[i] = mergeRoleTable(tables[i], t)
+	}
+
+	for _, name := range r.Inherits {
+		var pr *Role
+		for i := range c.Roles {
+			if strings.EqualFold(c.Roles[i].Name, name) {
+				pr = &c.Roles[i]
+				break
+			}
+		}
+		if pr == nil {
+			return r, fmt.Errorf("role '%s': inherits unknown role '%s'", r.Name, name)
+		}
+
+		p, err := inheritRole(c, *pr, seen)
+		if err != nil {
+			return r, err
+		}
+		for _, t := range p.Tables {
+			addTable(t)
+		}
+		funcs = append(funcs, p.Functions...)
+	}
+
+	for _, t := range r.Tables {
+		addTable(t)
+	}
+
+	r.Tables = tables
+	r.Functions = append(funcs, r.Functions...)
+	return r, nil
+}
+
+func mergeRoleTable(t, t1 RoleTable) RoleTable {
+	if t1.ReadOnly {
+		t.ReadOnly = true
+	}
+	if t1.Query != nil {
+		t.Query = t1.Query
+	}
+	if t1.Insert != nil {
+		t.Insert = t1.Insert
+	}
+	if t1.Update != nil {
+		t.Update = t1.Update
+	}
+	if t1.Upsert != nil {
+		t.Upsert = t1.Upsert
+	}
+	if t1.Delete != nil {
+		t.Delete = t1.Delete
+	}
+	return t
+}
+
 func addRole(qc *qcode.Compiler, r Role, t RoleTable, defaultBlock bool) error {
 	ro := false // read-only
 
@@ -382,11 +562,14 @@ func addRole(qc *qcode.Compiler, r Role, t RoleTable, defaultBlock bool) error {
 			Columns:          t.Query.Columns,
 			DisableFunctions: t.Query.DisableFunctions,
 			Block:            t.Query.Block,
+			WithDeleted:      t.Query.WithDeleted,
+			Masks:            t.Query.Masks,
 		}
 	}
 
 	if t.Insert != nil {
 		insert = qcode.InsertConfig{
+			Filters: t.Insert.Filters,
 			Columns: t.Insert.Columns,
 			Presets: t.Insert.Presets,
 			Block:   t.Insert.Block,
@@ -404,10 +587,25 @@ func addRole(qc *qcode.Compiler, r Role, t RoleTable, defaultBlock bool) error {
 
 	if t.Upsert != nil {
 		upsert = qcode.UpsertConfig{
-			Filters: t.Update.Filters,
-			Columns: t.Update.Columns,
-			Presets: t.Update.Presets,
-			Block:   t.Update.Block,
+			Filters: t.Upsert.Filters,
+			Columns: t.Upsert.Columns,
+			Presets: t.Upsert.Presets,
+			Block:   t.Upsert.Block,
+		}
+
+		// upserts used the update config before they had their own
+		// so it's still used for what the upsert config does not set
+		if t.Update != nil {
+			if upsert.Filters == nil {
+				upsert.Filters = t.Update.Filters
+			}
+			if upsert.Columns == nil {
+				upsert.Columns = t.Update.Columns
+			}
+			if upsert.Presets == nil {
+				upsert.Presets = t.Update.Presets
+			}
+			upsert.Block = upsert.Block || t.Update.Block
 		}
 	}
 
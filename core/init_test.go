@@ -0,0 +1,219 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/internal/psql"
+	"github.com/dosco/graphjin/core/internal/qcode"
+	"github.com/dosco/graphjin/core/internal/sdata"
+)
+
+func TestInheritRole(t *testing.T) {
+	c := &Config{Roles: []Role{
+		{
+			Name:      "user",
+			Functions: []string{"approve_order"},
+			Tables: []RoleTable{
+				{Name: "products", Query: &Query{Limit: 10}, Update: &Update{Filters: []string{"{ user_id: { eq: $user_id } }"}}},
+				{Name: "users", ReadOnly: true},
+			},
+		},
+		{
+			Name:     "editor",
+			Inherits: []string{"user"},
+			Tables: []RoleTable{
+				{Name: "products", Update: &Update{Filters: []string{}}},
+			},
+		},
+		{
+			Name:      "chief",
+			Inherits:  []string{"editor"},
+			Functions: []string{"close_store"},
+			Tables: []RoleTable{
+				{Name: "Products", Delete: &Delete{Filters: []string{}}},
+				{Name: "orders"},
+			},
+		},
+	}}
+
+	r, err := inheritRole(c, c.Roles[2], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.Tables) != 3 {
+		t.Fatalf("expected 3 tables got: %+v", r.Tables)
+	}
+
+	p := r.Tables[0]
+
+	// query is inherited from user, update from editor
+	// and delete is the role's own
+	if p.Query == nil || p.Query.Limit != 10 {
+		t.Errorf("expected the query config of 'user': %+v", p.Query)
+	}
+	if p.Update == nil || len(p.Update.Filters) != 0 {
+		t.Errorf("expected the update config of 'editor': %+v", p.Update)
+	}
+	if p.Delete == nil {
+		t.Error("expected the delete config of 'chief'")
+	}
+	if p.Insert != nil {
+		t.Errorf("unexpected insert config: %+v", p.Insert)
+	}
+
+	if !r.Tables[1].ReadOnly {
+		t.Error("expected 'users' to stay read only")
+	}
+
+	if strings.Join(r.Functions, ",") != "approve_order,close_store" {
+		t.Errorf("unexpected functions: %v", r.Functions)
+	}
+
+	// the parent is left unchanged
+	if c.Roles[0].Tables[0].Update == nil || len(c.Roles[0].Tables[0].Update.Filters) != 1 {
+		t.Errorf("parent role changed: %+v", c.Roles[0].Tables[0].Update)
+	}
+}
+
+func TestInheritRoleReadOnly(t *testing.T) {
+	c := &Config{Roles: []Role{
+		{Name: "user", Tables: []RoleTable{{Name: "products", ReadOnly: true}}},
+		{Name: "editor", Inherits: []string{"user"}, Tables: []RoleTable{
+			{Name: "products", Insert: &Insert{}},
+		}},
+	}}
+
+	r, err := inheritRole(c, c.Roles[1], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// read only cannot be cleared by the role inheriting it
+	if !r.Tables[0].ReadOnly || r.Tables[0].Insert == nil {
+		t.Errorf("unexpected table: %+v", r.Tables[0])
+	}
+}
+
+func TestInheritRoleErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles []Role
+		err   string
+	}{
+		{
+			name:  "self",
+			roles: []Role{{Name: "user", Inherits: []string{"user"}}},
+			err:   "inheritance cycle",
+		},
+		{
+			name: "cycle",
+			roles: []Role{
+				{Name: "user", Inherits: []string{"editor"}},
+				{Name: "editor", Inherits: []string{"admin"}},
+				{Name: "admin", Inherits: []string{"User"}},
+			},
+			err: "inheritance cycle",
+		},
+		{
+			name:  "unknown",
+			roles: []Role{{Name: "user", Inherits: []string{"editor"}}},
+			err:   "inherits unknown role 'editor'",
+		},
+		{
+			name: "unknown parent",
+			roles: []Role{
+				{Name: "user", Inherits: []string{"editor"}},
+				{Name: "editor", Inherits: []string{"admin"}},
+			},
+			err: "inherits unknown role 'admin'",
+		},
+	}
+
+	for _, v := range tests {
+		c := &Config{Roles: v.roles}
+
+		_, err := inheritRole(c, c.Roles[0], nil)
+		if err == nil || !strings.Contains(err.Error(), v.err) {
+			t.Errorf("%s: expected '%s' got: %v", v.name, v.err, err)
+		}
+	}
+
+	// the same role inherited twice is not a cycle
+	c := &Config{Roles: []Role{
+		{Name: "anon", Tables: []RoleTable{{Name: "products"}}},
+		{Name: "user", Inherits: []string{"anon"}},
+		{Name: "editor", Inherits: []string{"user", "anon"}},
+	}}
+
+	r, err := inheritRole(c, c.Roles[2], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Tables) != 1 {
+		t.Errorf("expected one table got: %+v", r.Tables)
+	}
+}
+
+func TestAddRoleUpsert(t *testing.T) {
+	dbs, err := sdata.NewDBSchema(sdata.GetTestDBInfo(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := &Update{
+		Filters: []string{"{ user_id: { eq: 42 } }"},
+		Presets: map[string]string{"updated_at": "now"},
+	}
+
+	tests := []struct {
+		name   string
+		upsert *Upsert
+		exp    []string
+	}{
+		{
+			name:   "fallback to update",
+			upsert: &Upsert{},
+			exp:    []string{`("products".user_id) = '42'`, `SET updated_at = EXCLUDED.updated_at`},
+		},
+		{
+			name:   "own filters",
+			upsert: &Upsert{Filters: []string{"{ user_id: { eq: 7 } }"}},
+			exp:    []string{`("products".user_id) = '7'`, `SET updated_at = EXCLUDED.updated_at`},
+		},
+	}
+
+	gql := []byte(`mutation { products(upsert: $data) { id } }`)
+	vars := map[string]json.RawMessage{"data": json.RawMessage(`{"id": 1, "name": "Apple"}`)}
+
+	for _, v := range tests {
+		qcc, err := qcode.NewCompiler(dbs, qcode.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rt := RoleTable{Name: "products", Update: update, Upsert: v.upsert}
+		if err := addRole(qcc, Role{Name: "user"}, rt, false); err != nil {
+			t.Fatal(err)
+		}
+
+		qc, err := qcc.Compile(gql, vars, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var w bytes.Buffer
+		if _, err := psql.NewCompiler(psql.Config{}).Compile(&w, qc); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, e := range v.exp {
+			if !strings.Contains(w.String(), e) {
+				t.Errorf("%s: expected '%s' in: %s", v.name, e, w.String())
+			}
+		}
+	}
+}
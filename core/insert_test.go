@@ -42,6 +42,109 @@ func Example_insert() {
 	// Output: {"users": [{"id": 1001, "email": "user1001@test.com"}]}
 }
 
+func Example_insertInTransaction() {
+	gql := `mutation {
+		users(insert: $data) {
+			id
+			email
+		}
+	}`
+
+	vars := json.RawMessage(`{
+		"data": {
+			"id": 1012,
+			"email": "user1012@test.com",
+			"full_name": "User 1012",
+			"stripe_id": "payment_id_1012",
+			"category_counts": [{"category_id": 1, "count": 400},{"category_id": 2, "count": 600}]
+		}
+	}`)
+
+	conf := newConfig(&core.Config{DBType: dbType, DisableAllowList: true})
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 3)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	res, err := gj.GraphQLTx(ctx, tx, gql, vars, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+	fmt.Println(string(res.Data))
+	// Output: {"users": [{"id": 1012, "email": "user1012@test.com"}]}
+}
+
+func Example_insertInTransactionRollback() {
+	gql := `mutation {
+		users(insert: $data) {
+			id
+			email
+		}
+	}`
+
+	vars := json.RawMessage(`{
+		"data": {
+			"id": 1013,
+			"email": "user1013@test.com",
+			"full_name": "User 1013",
+			"stripe_id": "payment_id_1013",
+			"category_counts": [{"category_id": 1, "count": 400},{"category_id": 2, "count": 600}]
+		}
+	}`)
+
+	conf := newConfig(&core.Config{DBType: dbType, DisableAllowList: true})
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 3)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := gj.GraphQLTx(ctx, tx, gql, vars, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(string(res.Data))
+
+	// the caller's own write fails so both are rolled back
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET full_name = NULL WHERE id = 1013`); err == nil {
+		fmt.Println("expected the caller's update to fail")
+	}
+
+	if err := tx.Rollback(); err != nil {
+		panic(err)
+	}
+
+	var count int
+	err = db.QueryRowContext(ctx, `SELECT count(*) FROM users WHERE id = 1013`).Scan(&count)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(count)
+	// Output:
+	// {"users": [{"id": 1013, "email": "user1013@test.com"}]}
+	// 0
+}
+
 func Example_inlineInsert() {
 	gql := `mutation {
 		users(insert: { id: $id, email: $email, full_name: $full_name }) {
@@ -18,6 +18,38 @@ const (
 	maxArgs   = 25
 )
 
+// ParseError is returned when the query cannot be parsed. Line and
+// Column (both starting at 1) point to where in the query the error was found.
+type ParseError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func newParseError(input []byte, pos Pos, err error) error {
+	if int(pos) > len(input) {
+		pos = Pos(len(input))
+	}
+	line, col := 1, 1
+	for _, r := range string(input[:pos]) {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return &ParseError{Line: line, Column: col, Err: err}
+}
+
 type ParserType int8
 
 const (
@@ -124,7 +156,7 @@ func Parse(gql []byte, fetchFrag func(name string) (string, error)) (Operation,
 	}
 
 	if l, err = lex(gql); err != nil {
-		return op, err
+		return op, newParseError(l.input, l.start, err)
 	}
 
 	p := Parser{
@@ -147,7 +179,7 @@ func Parse(gql []byte, fetchFrag func(name string) (string, error)) (Operation,
 		if p.peekVal(fragmentToken) && p.fetchFrag == nil {
 			p.ignore()
 			if _, err := p.parseFragment(); err != nil {
-				return op, err
+				return op, p.error(err)
 			}
 
 		} else {
@@ -163,7 +195,7 @@ func Parse(gql []byte, fetchFrag func(name string) (string, error)) (Operation,
 
 	p.reset(s)
 	if op, err = p.parseOp(); err != nil {
-		return op, err
+		return op, p.error(err)
 	}
 
 	for i, f := range op.Fields {
@@ -174,6 +206,16 @@ func Parse(gql []byte, fetchFrag func(name string) (string, error)) (Operation,
 	return op, nil
 }
 
+// error returns a ParseError pointing to the next token
+// which is the one the parser failed on
+func (p *Parser) error(err error) error {
+	pos := Pos(len(p.input))
+	if n := p.pos + 1; n < len(p.items) {
+		pos = p.items[n].pos
+	}
+	return newParseError(p.input, pos, err)
+}
+
 func (p *Parser) parseFragment() (Fragment, error) {
 	var err error
 	var frag Fragment
@@ -199,7 +241,7 @@ func (p *Parser) parseFragment() (Fragment, error) {
 	if p.peek(itemObjOpen) {
 		p.ignore()
 	} else {
-		return frag, fmt.Errorf("fragment: expecting a '{', got: %s", p.next())
+		return frag, fmt.Errorf("fragment: expecting a '{', got: %s", p.peekNext())
 	}
 
 	frag.Fields, err = p.parseFields(frag.Fields)
@@ -246,7 +288,7 @@ func (p *Parser) parseOp() (Operation, error) {
 			}
 		}
 	} else {
-		return op, fmt.Errorf("expecting a query, mutation or subscription, got: %s", p.next())
+		return op, fmt.Errorf("expecting a query, mutation or subscription, got: %s", p.peekNext())
 	}
 	return op, nil
 }
@@ -385,7 +427,7 @@ func (p *Parser) parseFields(fields []Field) ([]Field, error) {
 
 func (p *Parser) parseNormalFields(st *Stack, fields []Field) ([]Field, error) {
 	if !p.peek(itemName) {
-		return nil, fmt.Errorf("expecting an alias or field name, got: %s", p.next())
+		return nil, fmt.Errorf("expecting an alias or field name, got: %s", p.peekNext())
 	}
 
 	fields = append(fields, Field{ID: int32(len(fields))})
@@ -444,7 +486,7 @@ func (p *Parser) parseFragmentFields(st *Stack, fields []Field) ([]Field, error)
 
 	} else {
 		if !p.peek(itemName) {
-			return nil, fmt.Errorf("expecting a fragment name, got: %s", p.next())
+			return nil, fmt.Errorf("expecting a fragment name, got: %s", p.peekNext())
 		}
 
 		name := p.val(p.next())
@@ -730,7 +772,7 @@ func (p *Parser) parseValue() (*Node, error) {
 	case itemVariable:
 		node.Type = NodeVar
 	default:
-		return nil, fmt.Errorf("expecting a number, string, object, list or variable as an argument value (not %s)", p.val(p.next()))
+		return nil, fmt.Errorf("expecting a number, string, object, list or variable as an argument value (not %s)", p.peekNext())
 	}
 	node.Val = p.val(item)
 
@@ -813,8 +855,11 @@ func (p *Parser) ignore() {
 }
 
 func (p *Parser) peekNext() string {
-	item := p.items[p.pos+1]
-	return b2s(item.val)
+	n := p.pos + 1
+	if n >= len(p.items) {
+		return ""
+	}
+	return b2s(p.items[n].val)
 }
 
 func (p *Parser) peekNextType() MType {
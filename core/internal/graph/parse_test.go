@@ -1,6 +1,7 @@
 package graph
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/chirino/graphql/schema"
@@ -50,6 +51,29 @@ fragment userFields2 on user {
 	__typename
 }`)
 
+func TestParseErrorLocation(t *testing.T) {
+	gql := []byte(`query {
+	products {
+		id
+		name(
+	}
+}`)
+
+	_, err := Parse(gql, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a ParseError got: %T", err)
+	}
+
+	if perr.Line != 5 || perr.Column != 2 {
+		t.Fatalf("expected line 5, column 2 got: %d, %d (%s)", perr.Line, perr.Column, err)
+	}
+}
+
 func BenchmarkParse(b *testing.B) {
 	b.ResetTimer()
 	b.ReportAllocs()
@@ -0,0 +1,126 @@
+//nolint:errcheck
+
+package psql
+
+import (
+	"github.com/dosco/graphjin/core/internal/qcode"
+)
+
+// AuditCols are the columns the audit table is expected to have
+var AuditCols = []string{
+	"operation",
+	"action",
+	"role",
+	"user_id",
+	"table_name",
+	"row_id",
+	"old_row",
+	"new_row",
+}
+
+func auditAction(m qcode.Mutate) string {
+	switch m.Type {
+	case qcode.MTInsert:
+		return "insert"
+	case qcode.MTUpdate:
+		return "update"
+	case qcode.MTUpsert:
+		return "upsert"
+	case qcode.MTDelete:
+		return "delete"
+	}
+	return ""
+}
+
+// renderAudit adds a statement to the mutation that writes a row to the
+// audit table for every row changed by it. All the statements share the
+// same snapshot so reading the table returns the rows as they were
+// before the change.
+func (c *compilerContext) renderAudit() {
+	if c.audit == "" {
+		return
+	}
+
+	i := 0
+	for _, m := range c.qc.Mutates {
+		action := auditAction(m)
+		if action == "" {
+			continue
+		}
+		if i == 0 {
+			c.w.WriteString(`, "_gj_audit" AS (INSERT INTO `)
+			c.quoted(c.audit)
+			c.w.WriteString(` (`)
+			for n, col := range AuditCols {
+				if n != 0 {
+					c.w.WriteString(`, `)
+				}
+				c.quoted(col)
+			}
+			c.w.WriteString(`)`)
+		} else {
+			c.w.WriteString(` UNION ALL`)
+		}
+		c.renderAuditRows(m, action)
+		i++
+	}
+
+	if i != 0 {
+		c.w.WriteString(`) `)
+	}
+}
+
+func (c *compilerContext) renderAuditRows(m qcode.Mutate, action string) {
+	pk := m.Ti.PrimaryCol.Name
+
+	c.w.WriteString(` SELECT `)
+	if c.qc.Name != "" {
+		c.squoted(c.qc.Name)
+	} else {
+		c.w.WriteString(`NULL`)
+	}
+	c.w.WriteString(`, `)
+	c.squoted(action)
+	c.w.WriteString(`, `)
+	c.squoted(c.qc.Role)
+	c.w.WriteString(`, `)
+
+	// anon requests have no user id
+	if c.qc.Role == "anon" {
+		c.w.WriteString(`NULL`)
+	} else {
+		c.renderParam(Param{Name: "user_id", Type: "text"})
+		c.w.WriteString(` :: text`)
+	}
+	c.w.WriteString(`, `)
+	c.squoted(m.Ti.Name)
+	c.w.WriteString(`, `)
+
+	if pk != "" {
+		c.colWithTable("n", pk)
+		c.w.WriteString(` :: text, (SELECT to_jsonb(o) FROM `)
+		// the mutation statement is named after the table so
+		// the schema is needed to read from the table itself
+		c.quoted(m.Ti.Schema)
+		c.w.WriteString(`.`)
+		c.quoted(m.Ti.Name)
+		c.w.WriteString(` o WHERE `)
+		c.colWithTable("o", pk)
+		c.w.WriteString(` = `)
+		c.colWithTable("n", pk)
+		c.w.WriteString(`)`)
+	} else {
+		c.w.WriteString(`NULL, NULL`)
+	}
+	c.w.WriteString(`, `)
+
+	if m.Type == qcode.MTDelete && m.SoftDelete.Name == "" {
+		c.w.WriteString(`NULL`)
+	} else {
+		c.w.WriteString(`to_jsonb(n)`)
+	}
+
+	c.w.WriteString(` FROM `)
+	c.renderCteName(m)
+	c.w.WriteString(` n`)
+}
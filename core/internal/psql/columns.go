@@ -5,13 +5,17 @@ import (
 	"github.com/dosco/graphjin/core/internal/sdata"
 )
 
-func (c *compilerContext) renderColumns(sel *qcode.Select) {
+func (c *compilerContext) renderColumns(sel *qcode.Select) int {
 	i := 0
 	for _, col := range sel.Cols {
 		if i != 0 {
 			c.w.WriteString(", ")
 		}
-		colWithTableID(c.w, sel.Table, sel.ID, col.Col.Name)
+		if col.Mask != "" {
+			c.renderMask(sel, col)
+		} else {
+			colWithTableID(c.w, sel.Table, sel.ID, col.Col.Name)
+		}
 		c.alias(col.FieldName)
 		i++
 	}
@@ -35,10 +39,10 @@ func (c *compilerContext) renderColumns(sel *qcode.Select) {
 		i++
 	}
 
-	c.renderJoinColumns(sel, i)
+	return c.renderJoinColumns(sel, i)
 }
 
-func (c *compilerContext) renderJoinColumns(sel *qcode.Select, n int) {
+func (c *compilerContext) renderJoinColumns(sel *qcode.Select, n int) int {
 	i := n
 	for _, cid := range sel.Children {
 		csel := &c.qc.Selects[cid]
@@ -67,14 +71,12 @@ func (c *compilerContext) renderJoinColumns(sel *qcode.Select, n int) {
 				c.renderUnionColumn(sel, csel)
 
 			default:
-				c.w.WriteString(`__sj_`)
-				int32String(c.w, csel.ID)
-				c.w.WriteString(`.json`)
+				c.renderChildJSON(csel)
 				c.alias(csel.FieldName)
 			}
 
 			// return the cursor for the this child selector as part of the parents json
-			if csel.Paging.Cursor {
+			if csel.Paging.Cursor && csel.Connection == nil {
 				c.w.WriteString(`, __sj_`)
 				int32String(c.w, csel.ID)
 				c.w.WriteString(`.__cursor AS `)
@@ -84,6 +86,7 @@ func (c *compilerContext) renderJoinColumns(sel *qcode.Select, n int) {
 		}
 		i++
 	}
+	return i
 }
 
 func (c *compilerContext) renderUnionColumn(sel, csel *qcode.Select) {
@@ -100,15 +103,26 @@ func (c *compilerContext) renderUnionColumn(sel, csel *qcode.Select) {
 		if usel.SkipRender == qcode.SkipTypeUserNeeded {
 			c.w.WriteString(`NULL `)
 		} else {
-			c.w.WriteString(`__sj_`)
-			int32String(c.w, usel.ID)
-			c.w.WriteString(`.json `)
+			c.renderChildJSON(usel)
+			c.w.WriteString(` `)
 		}
 	}
 	c.w.WriteString(`END)`)
 	c.alias(csel.FieldName)
 }
 
+func (c *compilerContext) renderChildJSON(csel *qcode.Select) {
+	// sqlite has no lateral joins so the child is
+	// rendered as a correlated subquery instead
+	if c.ct == "sqlite" {
+		c.renderSQLiteSelect(csel)
+		return
+	}
+	c.w.WriteString(`__sj_`)
+	int32String(c.w, csel.ID)
+	c.w.WriteString(`.json`)
+}
+
 func (c *compilerContext) renderFunction(sel *qcode.Select, fn qcode.Function) {
 	switch fn.Name {
 	case "search_rank":
@@ -122,7 +136,7 @@ func (c *compilerContext) renderFunction(sel *qcode.Select, fn qcode.Function) {
 }
 
 func (c *compilerContext) renderFunctionSearchRank(sel *qcode.Select, fn qcode.Function) {
-	if c.ct == "mysql" {
+	if c.ct == "mysql" || c.ct == "mssql" {
 		c.w.WriteString(`0`)
 		return
 	}
@@ -145,7 +159,7 @@ func (c *compilerContext) renderFunctionSearchRank(sel *qcode.Select, fn qcode.F
 }
 
 func (c *compilerContext) renderFunctionSearchHeadline(sel *qcode.Select, fn qcode.Function) {
-	if c.ct == "mysql" {
+	if c.ct == "mysql" || c.ct == "mssql" {
 		c.w.WriteString(`''`)
 		return
 	}
@@ -163,12 +177,111 @@ func (c *compilerContext) renderFunctionSearchHeadline(sel *qcode.Select, fn qco
 }
 
 func (c *compilerContext) renderOtherFunction(sel *qcode.Select, fn qcode.Function) {
+	c.renderFuncCall(sel.Table, fn)
+}
+
+// renderFuncCall renders a function of a column without an alias so
+// it can also be used in the group by and having clauses
+func (c *compilerContext) renderFuncCall(table string, fn qcode.Function) {
+	if fn.Name == "date_trunc" {
+		c.renderDateTrunc(table, fn)
+		return
+	}
 	c.w.WriteString(fn.Name)
 	c.w.WriteString(`(`)
-	c.colWithTable(sel.Table, fn.Col.Name)
+	c.colWithTable(table, fn.Col.Name)
 	_, _ = c.w.WriteString(`)`)
 }
 
+// renderDateTrunc truncates a timestamp to the start of the unit (month, day, etc)
+// weeks start on monday as they do with the postgres date_trunc function
+func (c *compilerContext) renderDateTrunc(table string, fn qcode.Function) {
+	col := func() { c.colWithTable(table, fn.Col.Name) }
+
+	switch c.ct {
+	case "mysql":
+		switch fn.Arg {
+		case "quarter":
+			c.w.WriteString(`(MAKEDATE(YEAR(`)
+			col()
+			c.w.WriteString(`), 1) + INTERVAL (QUARTER(`)
+			col()
+			c.w.WriteString(`) - 1) QUARTER)`)
+		case "week":
+			c.w.WriteString(`DATE_SUB(DATE(`)
+			col()
+			c.w.WriteString(`), INTERVAL WEEKDAY(`)
+			col()
+			c.w.WriteString(`) DAY)`)
+		default:
+			c.w.WriteString(`CAST(DATE_FORMAT(`)
+			col()
+			c.w.WriteString(`, '`)
+			c.w.WriteString(mysqlDateTruncFormat[fn.Arg])
+			c.w.WriteString(`') AS DATETIME)`)
+		}
+
+	case "mssql":
+		switch fn.Arg {
+		case "week":
+			c.w.WriteString(`DATEADD(day, (DATEDIFF(day, 0, `)
+			col()
+			c.w.WriteString(`) / 7) * 7, 0)`)
+		default:
+			c.w.WriteString(`DATEADD(`)
+			c.w.WriteString(fn.Arg)
+			c.w.WriteString(`, DATEDIFF(`)
+			c.w.WriteString(fn.Arg)
+			c.w.WriteString(`, 0, `)
+			col()
+			c.w.WriteString(`), 0)`)
+		}
+
+	case "sqlite":
+		switch fn.Arg {
+		case "quarter":
+			c.w.WriteString(`printf('%s-%02d-01 00:00:00', strftime('%Y', `)
+			col()
+			c.w.WriteString(`), ((CAST(strftime('%m', `)
+			col()
+			c.w.WriteString(`) AS INTEGER) - 1) / 3) * 3 + 1)`)
+		case "week":
+			c.w.WriteString(`datetime(`)
+			col()
+			c.w.WriteString(`, 'start of day', '-6 days', 'weekday 1')`)
+		default:
+			c.w.WriteString(`strftime('`)
+			c.w.WriteString(sqliteDateTruncFormat[fn.Arg])
+			c.w.WriteString(`', `)
+			col()
+			c.w.WriteString(`)`)
+		}
+
+	default:
+		c.w.WriteString(`date_trunc('`)
+		c.w.WriteString(fn.Arg)
+		c.w.WriteString(`', `)
+		col()
+		c.w.WriteString(`)`)
+	}
+}
+
+var mysqlDateTruncFormat = map[string]string{
+	"year":   `%Y-01-01`,
+	"month":  `%Y-%m-01`,
+	"day":    `%Y-%m-%d`,
+	"hour":   `%Y-%m-%d %H:00:00`,
+	"minute": `%Y-%m-%d %H:%i:00`,
+}
+
+var sqliteDateTruncFormat = map[string]string{
+	"year":   `%Y-01-01 00:00:00`,
+	"month":  `%Y-%m-01 00:00:00`,
+	"day":    `%Y-%m-%d 00:00:00`,
+	"hour":   `%Y-%m-%d %H:00:00`,
+	"minute": `%Y-%m-%d %H:%M:00`,
+}
+
 func (c *compilerContext) renderBaseColumns(sel *qcode.Select) int {
 	i := 0
 
@@ -219,9 +332,19 @@ func (c *compilerContext) renderRecursiveBaseColumns(sel *qcode.Select) {
 }
 
 func (c *compilerContext) renderTypename(sel *qcode.Select) {
-	c.w.WriteString(`(`)
-	c.squoted(sel.Table)
-	c.w.WriteString(` :: text) AS "__typename"`)
+	switch c.ct {
+	case "mssql":
+		c.w.WriteString(`CAST(`)
+		c.squoted(sel.Table)
+		c.w.WriteString(` AS nvarchar(max)) AS [__typename]`)
+	case "sqlite":
+		c.squoted(sel.Table)
+		c.w.WriteString(` AS "__typename"`)
+	default:
+		c.w.WriteString(`(`)
+		c.squoted(sel.Table)
+		c.w.WriteString(` :: text) AS "__typename"`)
+	}
 }
 
 func (c *compilerContext) renderJSONFields(sel *qcode.Select) {
@@ -230,7 +353,7 @@ func (c *compilerContext) renderJSONFields(sel *qcode.Select) {
 		if i != 0 {
 			c.w.WriteString(", ")
 		}
-		c.renderJSONField(col.FieldName, sel.ID)
+		c.renderJSONField(col.FieldName, sel.ID, false)
 		i++
 	}
 	for _, fn := range sel.Funcs {
@@ -238,9 +361,9 @@ func (c *compilerContext) renderJSONFields(sel *qcode.Select) {
 			c.w.WriteString(", ")
 		}
 		if fn.Alias != "" {
-			c.renderJSONField(fn.Alias, sel.ID)
+			c.renderJSONField(fn.Alias, sel.ID, false)
 		} else {
-			c.renderJSONField(fn.FieldName, sel.ID)
+			c.renderJSONField(fn.FieldName, sel.ID, false)
 		}
 		i++
 	}
@@ -249,7 +372,7 @@ func (c *compilerContext) renderJSONFields(sel *qcode.Select) {
 		if i != 0 {
 			c.w.WriteString(`, `)
 		}
-		c.renderJSONField("__typename", sel.ID)
+		c.renderJSONField("__typename", sel.ID, false)
 		i++
 	}
 
@@ -274,27 +397,64 @@ func (c *compilerContext) renderJSONFields(sel *qcode.Select) {
 			}
 
 		} else {
-			c.renderJSONField(csel.FieldName, sel.ID)
+			c.renderJSONField(csel.FieldName, sel.ID, true)
 
 			// return the cursor for the this child selector as part of the parents json
-			if csel.Paging.Cursor {
+			if csel.Paging.Cursor && csel.Connection == nil {
 				c.w.WriteString(", ")
-				c.renderJSONField(csel.FieldName+`_cursor`, sel.ID)
+				c.renderJSONField(csel.FieldName+`_cursor`, sel.ID, false)
 			}
 		}
 		i++
 	}
 }
 
-func (c *compilerContext) renderJSONField(name string, selID int32) {
-	c.squoted(name)
-	c.w.WriteString(`, __sr_`)
-	int32String(c.w, selID)
-	c.w.WriteString(`.`)
-	c.w.WriteString(name)
+func (c *compilerContext) renderJSONField(name string, selID int32, isJSON bool) {
+	switch c.ct {
+	case "mssql":
+		if isJSON {
+			c.w.WriteString(`JSON_QUERY(`)
+		}
+		c.w.WriteString(`__sr_`)
+		int32String(c.w, selID)
+		c.w.WriteString(`.`)
+		c.quoted(name)
+		if isJSON {
+			c.w.WriteString(`)`)
+		}
+		c.alias(name)
+
+	case "sqlite":
+		// nested values are json text that must not be quoted again
+		c.squoted(name)
+		c.w.WriteString(`, `)
+		if isJSON {
+			c.w.WriteString(`json(`)
+		}
+		c.w.WriteString(`__sr_`)
+		int32String(c.w, selID)
+		c.w.WriteString(`.`)
+		c.quoted(name)
+		if isJSON {
+			c.w.WriteString(`)`)
+		}
+
+	default:
+		c.squoted(name)
+		c.w.WriteString(`, __sr_`)
+		int32String(c.w, selID)
+		c.w.WriteString(`.`)
+		c.w.WriteString(name)
+	}
 }
 
 func (c *compilerContext) renderJSONNullField(name string) {
-	c.squoted(name)
-	c.w.WriteString(`, NULL`)
+	switch c.ct {
+	case "mssql":
+		c.w.WriteString(`NULL`)
+		c.alias(name)
+	default:
+		c.squoted(name)
+		c.w.WriteString(`, NULL`)
+	}
 }
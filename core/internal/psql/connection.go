@@ -0,0 +1,159 @@
+//nolint:errcheck
+package psql
+
+import (
+	"github.com/dosco/graphjin/core/internal/qcode"
+)
+
+// CursorPrefix is added to the json keys of the cursor values in a
+// connection so they can be found and encrypted, the prefix is removed
+// when they are encrypted.
+const CursorPrefix = "__gj_cur_"
+
+// renderConnectionSelect builds the connection json object from the rows
+// of the selector. The rows are in the same order as the edges so the
+// first and last cursors are the start and end cursors. The __rest
+// column is the number of rows left (from the cursor on) before the
+// limit was applied and is used to check if there is a next page.
+func (c *compilerContext) renderConnectionSelect(sel *qcode.Select) {
+	c.w.WriteString(`SELECT `)
+	c.renderConnectionObject(sel, sel.Connection.Fields, sel.Table+"Connection")
+	c.w.WriteString(` AS json FROM (`)
+}
+
+func (c *compilerContext) renderConnectionObject(
+	sel *qcode.Select, fields []qcode.ConnField, typeName string) {
+	switch c.ct {
+	case "mysql":
+		c.w.WriteString(`json_object(`)
+	default:
+		c.w.WriteString(`jsonb_build_object(`)
+	}
+
+	for i, f := range fields {
+		if i != 0 {
+			c.w.WriteString(`, `)
+		}
+		if f.Name == "cursor" || f.Name == "startCursor" || f.Name == "endCursor" {
+			c.squoted(CursorPrefix + f.FieldName)
+		} else {
+			c.squoted(f.FieldName)
+		}
+		c.w.WriteString(`, `)
+
+		if f.Name == "__typename" {
+			c.squoted(typeName)
+			if c.ct != "mysql" {
+				c.w.WriteString(` :: text`)
+			}
+			continue
+		}
+		c.renderConnectionField(sel, f)
+	}
+	c.w.WriteString(`)`)
+}
+
+func (c *compilerContext) renderConnectionField(sel *qcode.Select, f qcode.ConnField) {
+	switch f.Name {
+	case "totalCount":
+		c.w.WriteString(`(SELECT count(*)`)
+		c.renderFrom(sel)
+		c.renderJoinTables(sel)
+		if sel.Connection.Where.Exp != nil {
+			c.w.WriteString(` WHERE `)
+			c.renderExp(sel.Ti, sel.Connection.Where.Exp, false)
+		}
+		c.w.WriteString(`)`)
+
+	case "pageInfo":
+		c.renderConnectionObject(sel, f.Fields, "PageInfo")
+
+	case "edges":
+		switch c.ct {
+		case "mysql":
+			c.w.WriteString(`CAST(COALESCE(json_arrayagg(`)
+			c.renderConnectionObject(sel, f.Fields, sel.Table+"Edge")
+			c.w.WriteString(`), '[]') AS JSON)`)
+		default:
+			c.w.WriteString(`COALESCE(jsonb_agg(`)
+			c.renderConnectionObject(sel, f.Fields, sel.Table+"Edge")
+			c.w.WriteString(`), '[]')`)
+		}
+
+	case "node":
+		c.w.WriteString(`__sj_`)
+		int32String(c.w, sel.ID)
+		c.w.WriteString(`.json`)
+
+	case "cursor":
+		c.w.WriteString(`__sj_`)
+		int32String(c.w, sel.ID)
+		c.w.WriteString(`.__cursor`)
+
+	case "startCursor", "endCursor":
+		if c.ct == "mysql" {
+			c.w.WriteString(`JSON_EXTRACT(json_arrayagg(__sj_`)
+		} else {
+			c.w.WriteString(`(jsonb_agg(__sj_`)
+		}
+		int32String(c.w, sel.ID)
+		c.w.WriteString(`.__cursor)`)
+
+		switch {
+		case c.ct == "mysql" && f.Name == "startCursor":
+			c.w.WriteString(`, '$[0]')`)
+		case c.ct == "mysql":
+			c.w.WriteString(`, '$[last]')`)
+		case f.Name == "startCursor":
+			c.w.WriteString(` -> 0)`)
+		default:
+			c.w.WriteString(` -> -1)`)
+		}
+
+	case "hasNextPage":
+		c.renderConnectionBool(func() {
+			c.w.WriteString(`max(__sj_`)
+			int32String(c.w, sel.ID)
+			c.w.WriteString(`.__rest) > count(*)`)
+		})
+
+	case "hasPreviousPage":
+		// a previous page only exists when paging with a cursor
+		if sel.Paging.Type == qcode.PTOffset {
+			c.renderConnectionBool(nil)
+			break
+		}
+		c.renderConnectionBool(func() {
+			c.renderParam(Param{Name: "cursor", Type: "text"})
+			if c.ct != "mysql" {
+				c.w.WriteString(` :: text`)
+			}
+			c.w.WriteString(` IS NOT NULL`)
+		})
+	}
+}
+
+// renderConnectionBool renders the condition as a json boolean
+// or false when the condition is nil
+func (c *compilerContext) renderConnectionBool(cond func()) {
+	switch {
+	case c.ct == "mysql" && cond == nil:
+		c.w.WriteString(`JSON_EXTRACT('false', '$')`)
+	case c.ct == "mysql":
+		c.w.WriteString(`JSON_EXTRACT(IF(`)
+		cond()
+		c.w.WriteString(`, 'true', 'false'), '$')`)
+	case cond == nil:
+		c.w.WriteString(`false`)
+	default:
+		c.w.WriteString(`COALESCE(`)
+		cond()
+		c.w.WriteString(`, false)`)
+	}
+}
+
+// hasRest returns true if the __rest column is needed
+// to find if the connection has a next page
+func (c *compilerContext) hasRest(sel *qcode.Select) bool {
+	return sel.Connection != nil && sel.Connection.Has("hasNextPage")
+}
@@ -0,0 +1,108 @@
+//nolint:errcheck
+package psql
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dosco/graphjin/core/internal/qcode"
+)
+
+// CopyTable is the temporary table the rows of a bulk insert are
+// copied into when it's run using COPY
+const CopyTable = "_gj_copy"
+
+// CopyInsert is a bulk insert that reads its rows from CopyTable
+// instead of the json variable. The columns of CopyTable are all text
+// and are cast to the column types by the insert.
+type CopyInsert struct {
+	// SQL is the insert that reads from CopyTable
+	SQL string
+	// Create is the statement that creates CopyTable
+	Create string
+	// Cols are the columns copied into CopyTable
+	Cols []string
+	// Keys are the keys of the json rows copied into Cols, like
+	// json_populate_recordset they match the table's column names
+	Keys []string
+	// Var is the variable with the list of rows
+	Var string
+}
+
+// CanCopy returns true if the query is a plain (not nested)
+// bulk insert of a json list
+func CanCopy(qc *qcode.QCode) bool {
+	if qc.SType != qcode.QTInsert || len(qc.Mutates) != 1 {
+		return false
+	}
+
+	m := qc.Mutates[0]
+	if m.Type != qcode.MTInsert || !m.IsJSON || !m.IsArray {
+		return false
+	}
+
+	// array columns cannot be cast from their json text
+	for _, col := range m.Cols {
+		if col.Value == "" && col.Col.Array {
+			return false
+		}
+	}
+	return true
+}
+
+// CompileCopy compiles a plain bulk insert to read its rows from
+// CopyTable, presets and insert filters are applied as usual.
+func (co *Compiler) CompileCopy(qc *qcode.QCode) (CopyInsert, Metadata, error) {
+	var ci CopyInsert
+	var md Metadata
+
+	if co.ct != "" && co.ct != "postgres" {
+		return ci, md, fmt.Errorf("%s: bulk insert using copy is not supported", co.ct)
+	}
+
+	if !CanCopy(qc) {
+		return ci, md, fmt.Errorf("copy: only plain bulk inserts are supported")
+	}
+
+	var w bytes.Buffer
+
+	c := compilerContext{
+		md:       &md,
+		w:        &w,
+		qc:       qc,
+		isJSON:   true,
+		copy:     true,
+		Compiler: co,
+	}
+
+	w.WriteString(`/* action='` + qc.Name + `',controller='graphql',framework='graphjin' */ `)
+
+	if err := c.renderMutation(); err != nil {
+		return ci, md, err
+	}
+	ci.SQL = w.String()
+	ci.Var = qc.ActionVar
+
+	w.Reset()
+	w.WriteString(`CREATE TEMPORARY TABLE `)
+	c.quoted(CopyTable)
+	w.WriteString(` (`)
+
+	for _, col := range qc.Mutates[0].Cols {
+		if col.Value != "" {
+			continue
+		}
+		if len(ci.Cols) != 0 {
+			w.WriteString(`, `)
+		}
+		// the insert reads the values by field name
+		c.quoted(col.FieldName)
+		w.WriteString(` text`)
+		ci.Cols = append(ci.Cols, col.FieldName)
+		ci.Keys = append(ci.Keys, col.Col.Name)
+	}
+	w.WriteString(`) ON COMMIT DROP`)
+	ci.Create = w.String()
+
+	return ci, md, nil
+}
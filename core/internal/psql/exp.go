@@ -134,7 +134,9 @@ func (c *expContext) renderOp(ex *qcode.Exp) {
 		}
 
 		c.w.WriteString(`((`)
-		if ex.Left.ID == -1 {
+		if ex.Left.Func != nil {
+			c.renderFuncCall(table, *ex.Left.Func)
+		} else if ex.Left.ID == -1 {
 			c.colWithTable(table, ex.Left.Col.Name)
 		} else {
 			colWithTableID(c.w, table, ex.Left.ID, ex.Left.Col.Name)
@@ -148,9 +150,17 @@ func (c *expContext) renderOp(ex *qcode.Exp) {
 	case qcode.OpNotEquals:
 		c.w.WriteString(`!=`)
 	case qcode.OpNotDistinct:
-		c.w.WriteString(`IS NOT DISTINCT FROM`)
+		if c.ct == "sqlite" {
+			c.w.WriteString(`IS`)
+		} else {
+			c.w.WriteString(`IS NOT DISTINCT FROM`)
+		}
 	case qcode.OpDistinct:
-		c.w.WriteString(`IS DISTINCT FROM`)
+		if c.ct == "sqlite" {
+			c.w.WriteString(`IS NOT`)
+		} else {
+			c.w.WriteString(`IS DISTINCT FROM`)
+		}
 	case qcode.OpGreaterOrEquals:
 		c.w.WriteString(`>=`)
 	case qcode.OpLesserOrEquals:
@@ -162,15 +172,29 @@ func (c *expContext) renderOp(ex *qcode.Exp) {
 	case qcode.OpIn:
 		c.w.WriteString(`IN`)
 	case qcode.OpNotIn:
-		c.w.WriteString(`!= ALL`)
+		if c.ct == "mssql" || c.ct == "sqlite" {
+			c.w.WriteString(`NOT IN`)
+		} else {
+			c.w.WriteString(`!= ALL`)
+		}
 	case qcode.OpLike:
 		c.w.WriteString(`LIKE`)
 	case qcode.OpNotLike:
 		c.w.WriteString(`NOT LIKE`)
 	case qcode.OpILike:
-		c.w.WriteString(`ILIKE`)
+		// sql server string comparisons follow the collation
+		// which is case-insensitive by default as is like in sqlite
+		if c.ct == "mssql" || c.ct == "sqlite" {
+			c.w.WriteString(`LIKE`)
+		} else {
+			c.w.WriteString(`ILIKE`)
+		}
 	case qcode.OpNotILike:
-		c.w.WriteString(`NOT ILIKE`)
+		if c.ct == "mssql" || c.ct == "sqlite" {
+			c.w.WriteString(`NOT LIKE`)
+		} else {
+			c.w.WriteString(`NOT ILIKE`)
+		}
 	case qcode.OpSimilar:
 		c.w.WriteString(`SIMILAR TO`)
 	case qcode.OpNotSimilar:
@@ -196,12 +220,24 @@ func (c *expContext) renderOp(ex *qcode.Exp) {
 
 	case qcode.OpEqualsTrue:
 		c.w.WriteString(`(`)
+		if c.ct == "mssql" {
+			c.w.WriteString(`COALESCE(`)
+			c.renderParam(Param{Name: ex.Right.Val, Type: "bit"})
+			c.w.WriteString(`, 0) = 1)`)
+			return
+		}
 		c.renderParam(Param{Name: ex.Right.Val, Type: "boolean"})
 		c.w.WriteString(` IS TRUE)`)
 		return
 
 	case qcode.OpNotEqualsTrue:
 		c.w.WriteString(`(`)
+		if c.ct == "mssql" {
+			c.w.WriteString(`COALESCE(`)
+			c.renderParam(Param{Name: ex.Right.Val, Type: "bit"})
+			c.w.WriteString(`, 0) = 0)`)
+			return
+		}
 		c.renderParam(Param{Name: ex.Right.Val, Type: "boolean"})
 		c.w.WriteString(` IS NOT TRUE)`)
 		return
@@ -237,6 +273,18 @@ func (c *expContext) renderOp(ex *qcode.Exp) {
 			c.renderParam(Param{Name: ex.Right.Val, Type: "text"})
 			c.w.WriteString(` IN NATURAL LANGUAGE MODE))`)
 
+		case "mssql":
+			c.w.WriteString(`CONTAINS((`)
+			for i, col := range c.ti.FullText {
+				if i != 0 {
+					c.w.WriteString(`, `)
+				}
+				c.colWithTable(c.ti.Name, col.Name)
+			}
+			c.w.WriteString(`), `)
+			c.renderParam(Param{Name: ex.Right.Val, Type: "text"})
+			c.w.WriteString(`))`)
+
 		default:
 			//fmt.Fprintf(w, `(("%s") @@ websearch_to_tsquery('%s'))`, c.ti.TSVCol, val.Val)
 			c.w.WriteString(`((`)
@@ -285,6 +333,32 @@ func (c *expContext) renderValVarPrefix(ex *qcode.Exp) bool {
 			c.w.WriteString(` AS JSON), '$')`)
 			return true
 		}
+
+		if c.ct == "mssql" {
+			c.w.WriteString(`((`)
+			c.colWithTable(c.ti.Name, ex.Left.Col.Name)
+			if ex.Op == qcode.OpIn {
+				c.w.WriteString(`) IN (SELECT value FROM OPENJSON(`)
+			} else {
+				c.w.WriteString(`) NOT IN (SELECT value FROM OPENJSON(`)
+			}
+			c.renderParam(Param{Name: ex.Right.Val, Type: ex.Left.Col.Type, IsArray: true})
+			c.w.WriteString(`)))`)
+			return true
+		}
+
+		if c.ct == "sqlite" {
+			c.w.WriteString(`((`)
+			c.colWithTable(c.ti.Name, ex.Left.Col.Name)
+			if ex.Op == qcode.OpIn {
+				c.w.WriteString(`) IN (SELECT value FROM json_each(`)
+			} else {
+				c.w.WriteString(`) NOT IN (SELECT value FROM json_each(`)
+			}
+			c.renderParam(Param{Name: ex.Right.Val, Type: ex.Left.Col.Type, IsArray: true})
+			c.w.WriteString(`)))`)
+			return true
+		}
 	}
 	return false
 }
@@ -327,6 +401,13 @@ func (c *expContext) renderVal(ex *qcode.Exp) {
 			return
 		}
 
+		// function results have no type affinity in sqlite
+		// so numbers compared to them must not be quoted
+		if ex.Left.Func != nil && ex.Right.ValType == qcode.ValNum {
+			c.w.WriteString(ex.Right.Val)
+			return
+		}
+
 		if len(ex.Right.Path) == 0 {
 			c.squoted(ex.Right.Val)
 			return
@@ -335,6 +416,13 @@ func (c *expContext) renderVal(ex *qcode.Exp) {
 		path := append(c.prefixPath, ex.Right.Path...)
 		j := (len(path) - 1)
 
+		if c.ct == "mysql" {
+			c.w.WriteString(`JSON_UNQUOTE(`)
+			c.renderMySQLJSONExtract(path)
+			c.w.WriteString(`)`)
+			return
+		}
+
 		c.w.WriteString(`CAST(i.j`)
 		for i := 0; i < j; i++ {
 			c.w.WriteString(`->`)
@@ -2,10 +2,31 @@
 package psql
 
 import (
+	"strings"
+
 	"github.com/dosco/graphjin/core/internal/qcode"
 	"github.com/dosco/graphjin/core/internal/sdata"
 )
 
+// SQLSTATE codes of the errors raised by the checks added to mutations
+const (
+	// InsertCheckFailed (undefined_object) is raised when a row written by
+	// an insert does not pass the role's insert filters, the error message
+	// contains InsertCheckMsg
+	InsertCheckFailed = "42704"
+	InsertCheckMsg    = `"insert check failed on `
+
+	// VersionConflict (invalid_schema_name) is raised when a mutation does
+	// not change all the rows it matched since their version did not match,
+	// the error message contains VersionConflictMsg
+	VersionConflict    = "3F000"
+	VersionConflictMsg = `"version conflict on `
+)
+
+// nameQuoter escapes a table name written into a quoted identifier
+// within a string literal
+var nameQuoter = strings.NewReplacer(`"`, `""`, `'`, `''`)
+
 func (c *compilerContext) renderInsert() {
 	i := 0
 	for _, m := range c.qc.Mutates {
@@ -44,3 +65,70 @@ func (c *compilerContext) renderInsertStmt(m qcode.Mutate, embedded bool) {
 		c.w.WriteString(` RETURNING *)`)
 	}
 }
+
+// renderInsertChecks fails the statement when a row written by an insert
+// does not pass the role's insert filters. Plain SQL cannot raise an error
+// so the message is cast to the name of a role that does not exist to raise
+// one with the SQLSTATE InsertCheckFailed, the row count keeps it from being
+// folded into a constant (and raised) at plan time.
+func (c *compilerContext) renderInsertChecks() int {
+	i := 0
+	for _, m := range c.qc.Mutates {
+		if m.Check.Exp == nil {
+			continue
+		}
+		if i == 0 {
+			c.w.WriteString(` WHERE `)
+		} else {
+			c.w.WriteString(` AND `)
+		}
+		c.w.WriteString(`(SELECT CASE WHEN count(*) = 0 THEN true ELSE `)
+		c.w.WriteString(`CAST(('`)
+		c.w.WriteString(InsertCheckMsg)
+		nameQuoter.WriteString(c.w, m.Ti.Name)
+		c.w.WriteString(`: ' || count(*) || ' row(s)"') AS regrole) IS NULL END FROM `)
+		c.renderCteName(m)
+		c.w.WriteString(` AS `)
+		c.quoted(m.Ti.Name)
+		c.w.WriteString(` WHERE (`)
+		c.renderExp(m.Ti, m.Check.Exp, false)
+		c.w.WriteString(`) IS NOT TRUE)`)
+		i++
+	}
+	return i
+}
+
+// renderVersionCheck fails the statement when fewer rows were changed than
+// matched since the version of some did not match. The message is cast to
+// the name of a schema that does not exist to raise an error with the
+// SQLSTATE VersionConflict. The table is schema qualified as the mutation
+// statement is named after it.
+func (c *compilerContext) renderVersionCheck(i int) {
+	for _, m := range c.qc.Mutates {
+		if m.Version.Col.Name == "" {
+			continue
+		}
+		sel := c.qc.Selects[0]
+
+		if i == 0 {
+			c.w.WriteString(` WHERE `)
+		} else {
+			c.w.WriteString(` AND `)
+		}
+		c.w.WriteString(`(SELECT CASE WHEN count(*) <= (SELECT count(*) FROM `)
+		c.renderCteName(m)
+		c.w.WriteString(`) THEN true ELSE CAST(('`)
+		c.w.WriteString(VersionConflictMsg)
+		nameQuoter.WriteString(c.w, m.Ti.Name)
+		c.w.WriteString(`: ' || (count(*) - (SELECT count(*) FROM `)
+		c.renderCteName(m)
+		c.w.WriteString(`)) || ' row(s)"') AS regnamespace) IS NULL END FROM `)
+		c.quoted(m.Ti.Schema)
+		c.w.WriteString(`.`)
+		c.quoted(m.Ti.Name)
+		c.w.WriteString(` WHERE `)
+		c.renderExp(m.Ti, sel.Where.Exp, false)
+		c.w.WriteString(`)`)
+		i++
+	}
+}
@@ -2,7 +2,11 @@ package psql_test
 
 import (
 	"encoding/json"
+	"regexp"
+	"strings"
 	"testing"
+
+	"github.com/dosco/graphjin/core/internal/psql"
 )
 
 func simpleInsert(t *testing.T) {
@@ -327,6 +331,108 @@ func nestedInsertRecursive(t *testing.T) {
 	compileGQLToPSQL(t, gql, vars, "user")
 }
 
+func insertWithCheck(t *testing.T) {
+	gql := `mutation {
+		products(insert: $data) {
+			id
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`{"name": "my_name", "description": "my_desc"}`),
+	}
+
+	qc, err := qcompile.Compile([]byte(gql), vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sql, err := pcompile.CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the preset params are numbered in map order
+	exp := regexp.MustCompile(`CAST\(\('"insert check failed on products: ' \|\| count\(\*\) \|\| ' row\(s\)"'\) AS regrole\) IS NULL END FROM "products" AS "products" WHERE \(\(\("products"\.user_id\) = \$\d+\)\) IS NOT TRUE\)`)
+	if !exp.Match(sql) {
+		t.Errorf("insert check missing: %s", sql)
+	}
+}
+
+func bulkInsertCopy(t *testing.T) {
+	gql := `mutation {
+		products(insert: $data) {
+			id
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`[{"name": "my_name", "description": "my_desc"}]`),
+	}
+
+	qc, err := qcompile.Compile([]byte(gql), vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !psql.CanCopy(qc) {
+		t.Fatal("expected a plain bulk insert")
+	}
+
+	ci, md, err := pcompile.CompileCopy(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(ci.SQL, `_sg_input`) || !strings.Contains(ci.SQL, `FROM "_gj_copy" t`) {
+		t.Errorf("insert not reading from the copy table: %s", ci.SQL)
+	}
+
+	// presets and insert filters still apply
+	if !strings.Contains(ci.SQL, `IS NOT TRUE)`) {
+		t.Errorf("insert check missing: %s", ci.SQL)
+	}
+
+	for _, p := range md.Params() {
+		if p.Name == "data" {
+			t.Errorf("unexpected data param: %s", ci.SQL)
+		}
+	}
+
+	if len(ci.Cols) != 2 || !strings.HasSuffix(ci.Create, `text) ON COMMIT DROP`) {
+		t.Errorf("unexpected copy table: %s %v", ci.Create, ci.Cols)
+	}
+
+	for i, k := range ci.Keys {
+		if k != "name" && k != "description" || !strings.Contains(ci.SQL, `"t".`+ci.Cols[i]+` ::`) {
+			t.Errorf("unexpected copy column: %s %s", k, ci.Cols[i])
+		}
+	}
+
+	// nested inserts use the json input
+	gql = `mutation {
+		users(insert: $data) {
+			id
+			products {
+				id
+			}
+		}
+	}`
+
+	vars = map[string]json.RawMessage{
+		"data": json.RawMessage(`[{"email": "thedude@rug.com", "products": { "name": "Apple" }}]`),
+	}
+
+	qc, err = qcompile.Compile([]byte(gql), vars, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if psql.CanCopy(qc) {
+		t.Error("nested inserts cannot use copy")
+	}
+}
+
 func TestCompileInsert(t *testing.T) {
 	t.Run("simpleInsert", simpleInsert)
 	t.Run("singleInsert", singleInsert)
@@ -341,4 +447,6 @@ func TestCompileInsert(t *testing.T) {
 	t.Run("nestedInsertOneToOneWithConnect", nestedInsertOneToOneWithConnect)
 	t.Run("nestedInsertOneToOneWithConnectArray", nestedInsertOneToOneWithConnectArray)
 	t.Run("nestedInsertRecursive", nestedInsertRecursive)
+	t.Run("insertWithCheck", insertWithCheck)
+	t.Run("bulkInsertCopy", bulkInsertCopy)
 }
@@ -0,0 +1,87 @@
+//nolint:errcheck
+
+package psql
+
+import (
+	"strings"
+
+	"github.com/dosco/graphjin/core/internal/qcode"
+)
+
+// renderMask renders the redacted value of a column in place of it.
+// Null values stay null so it's still clear if a value is set.
+func (c *compilerContext) renderMask(sel *qcode.Select, col qcode.Column) {
+	colName := func() {
+		colWithTableID(c.w, sel.Table, sel.ID, col.Col.Name)
+	}
+
+	if col.Mask == qcode.MaskNull {
+		c.w.WriteString(`NULL`)
+		return
+	}
+
+	// the expression can use the columns of the table by name
+	if strings.HasPrefix(col.Mask, "sql:") {
+		c.w.WriteString(`(`)
+		c.renderVar(col.Mask[4:])
+		c.w.WriteString(`)`)
+		return
+	}
+
+	c.w.WriteString(`(CASE WHEN `)
+	colName()
+	c.w.WriteString(` IS NOT NULL THEN `)
+
+	switch col.Mask {
+	case qcode.MaskPartialEmail:
+		switch c.ct {
+		case "mysql":
+			c.w.WriteString(`CONCAT(LEFT(`)
+			colName()
+			c.w.WriteString(`, 1), '***@', SUBSTRING_INDEX(`)
+			colName()
+			c.w.WriteString(`, '@', -1))`)
+		case "mssql":
+			c.w.WriteString(`CONCAT(LEFT(`)
+			colName()
+			c.w.WriteString(`, 1), '***@', SUBSTRING(`)
+			colName()
+			c.w.WriteString(`, CHARINDEX('@', `)
+			colName()
+			c.w.WriteString(`) + 1, LEN(`)
+			colName()
+			c.w.WriteString(`)))`)
+		case "sqlite":
+			c.w.WriteString(`substr(`)
+			colName()
+			c.w.WriteString(`, 1, 1) || '***@' || substr(`)
+			colName()
+			c.w.WriteString(`, instr(`)
+			colName()
+			c.w.WriteString(`, '@') + 1)`)
+		default:
+			c.w.WriteString(`left(`)
+			colName()
+			c.w.WriteString(`, 1) || '***@' || split_part(`)
+			colName()
+			c.w.WriteString(`, '@', 2)`)
+		}
+
+	case qcode.MaskLast4:
+		switch c.ct {
+		case "mysql", "mssql":
+			c.w.WriteString(`CONCAT('***', RIGHT(`)
+			colName()
+			c.w.WriteString(`, 4))`)
+		case "sqlite":
+			c.w.WriteString(`'***' || substr(`)
+			colName()
+			c.w.WriteString(`, -4)`)
+		default:
+			c.w.WriteString(`'***' || right(`)
+			colName()
+			c.w.WriteString(` :: text, 4)`)
+		}
+	}
+	c.w.WriteString(` END)`)
+}
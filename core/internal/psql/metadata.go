@@ -76,6 +76,12 @@ func (c *compilerContext) renderParam(p Param) {
 	switch c.ct {
 	case "mysql":
 		c.w.WriteString(`?`)
+	case "mssql":
+		c.w.WriteString(`@p`)
+		int32String(c.w, int32(id))
+	case "sqlite":
+		c.w.WriteString(`?`)
+		int32String(c.w, int32(id))
 	default:
 		c.w.WriteString(`$`)
 		int32String(c.w, int32(id))
@@ -86,6 +92,82 @@ func (md Metadata) Params() []Param {
 	return md.params
 }
 
+// Stmt is a single statement of a query that compiles to more than one
+// statement. Its parameters are Params()[PStart:PEnd].
+type Stmt struct {
+	SQL    string
+	PStart int
+	PEnd   int
+
+	// Each is true if the statement is run once for every item of the
+	// array in the mutation variable, consecutive statements with Each
+	// set are run together in order for an item.
+	Each bool
+}
+
+type stmtEnd struct {
+	pos    int
+	params int
+	each   bool
+}
+
+// nolint: errcheck
+func (c *compilerContext) endStmt() {
+	c.md.stmts = append(c.md.stmts, stmtEnd{pos: c.w.Len(), params: len(c.md.params)})
+	c.w.WriteString(`; `)
+}
+
+// nolint: errcheck
+func (c *compilerContext) endEachStmt() {
+	c.md.stmts = append(c.md.stmts, stmtEnd{pos: c.w.Len(), params: len(c.md.params), each: true})
+	c.w.WriteString(`; `)
+}
+
+// Stmts splits the compiled sql into its statements, it returns nil
+// when the query compiled to a single statement.
+func (md Metadata) Stmts(sql string) []Stmt {
+	if len(md.stmts) == 0 {
+		return nil
+	}
+	stmts := make([]Stmt, 0, len(md.stmts)+1)
+
+	s, p := 0, 0
+	for _, v := range md.stmts {
+		stmts = append(stmts, Stmt{SQL: sql[s:v.pos], PStart: p, PEnd: v.params, Each: v.each})
+		s, p = v.pos+2, v.params
+	}
+	stmts = append(stmts, Stmt{SQL: sql[s:], PStart: p, PEnd: len(md.params)})
+	return stmts
+}
+
+// Reset returns a statement that clears the session variables set by the
+// statements of the query, it returns an empty string if there are none.
+func (md Metadata) Reset() string {
+	if len(md.vars) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+
+	sb.WriteString(`SET `)
+	for i, v := range md.vars {
+		if i != 0 {
+			sb.WriteString(`, `)
+		}
+		sb.WriteString(v)
+		sb.WriteString(` = NULL`)
+	}
+	return sb.String()
+}
+
+func (md *Metadata) addVar(name string) {
+	for _, v := range md.vars {
+		if v == name {
+			return
+		}
+	}
+	md.vars = append(md.vars, name)
+}
+
 func parseVar(v string) (string, string) {
 	dt := "text"
 	if n := strings.IndexByte(v, ':'); n != -1 {
@@ -0,0 +1,114 @@
+package psql_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func mssqlSimpleQuery(t *testing.T) {
+	gql := `query {
+		products(limit: 10, order_by: { price: desc }) {
+			id
+			name
+			user {
+				id
+				email
+			}
+		}
+	}`
+
+	sql, err := compileGQLForDialect(t, "mssql", gql, nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{
+		`FOR JSON PATH`,
+		`OUTER APPLY`,
+		`JSON_QUERY(__sj_0.json) AS [products]`,
+		`OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`,
+		`[products].price DESC`,
+		`ROW_NUMBER() OVER ( ORDER BY [products].price DESC) AS __rn`,
+		`STRING_AGG(__sj_0.json, ',') WITHIN GROUP (ORDER BY __sj_0.__rn)`,
+	} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+
+	for _, v := range []string{`LATERAL`, `LIMIT`, `jsonb`} {
+		if strings.Contains(sql, v) {
+			t.Errorf("unexpected '%s' in: %s", v, sql)
+		}
+	}
+}
+
+func mssqlWithVariables(t *testing.T) {
+	gql := `query {
+		products(limit: $limit, offset: $offset, where: { id: { in: $ids }, name: { eq: $name } }) {
+			id
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"limit":  json.RawMessage(`10`),
+		"offset": json.RawMessage(`20`),
+		"ids":    json.RawMessage(`[1,2,3]`),
+		"name":   json.RawMessage(`"test"`),
+	}
+
+	sql, err := compileGQLForDialect(t, "mssql", gql, vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{`@p1`, `@p4`, `OPENJSON(`, `ORDER BY (SELECT NULL)`} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+}
+
+func mssqlSingular(t *testing.T) {
+	gql := `query {
+		products(id: $id) {
+			id
+			__typename
+		}
+	}`
+
+	sql, err := compileGQLForDialect(t, "mssql", gql, nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{`FETCH NEXT 1 ROWS ONLY`, `AS [__typename]`} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+
+	if strings.Contains(sql, `STRING_AGG`) {
+		t.Errorf("unexpected 'STRING_AGG' in: %s", sql)
+	}
+}
+
+func mssqlCursorNotSupported(t *testing.T) {
+	gql := `query {
+		products(first: 10, after: $cursor) {
+			id
+		}
+	}`
+
+	if _, err := compileGQLForDialect(t, "mssql", gql, nil, "user"); err == nil {
+		t.Error("we were expecting an error")
+	}
+}
+
+func TestCompileMSSQL(t *testing.T) {
+	t.Run("mssqlSimpleQuery", mssqlSimpleQuery)
+	t.Run("mssqlWithVariables", mssqlWithVariables)
+	t.Run("mssqlSingular", mssqlSingular)
+	t.Run("mssqlCursorNotSupported", mssqlCursorNotSupported)
+}
@@ -14,7 +14,7 @@ import (
 func (co *Compiler) compileMutation(
 	w *bytes.Buffer,
 	qc *qcode.QCode,
-	md *Metadata) {
+	md *Metadata) error {
 
 	c := compilerContext{
 		md:       md,
@@ -24,8 +24,17 @@ func (co *Compiler) compileMutation(
 		Compiler: co,
 	}
 
+	if c.ct == "mysql" {
+		return c.renderMySQLMutation()
+	}
+	return c.renderMutation()
+}
+
+func (c *compilerContext) renderMutation() error {
+	qc := c.qc
+
 	if qc.SType != qcode.QTDelete {
-		if c.isJSON {
+		if c.isJSON && !c.copy {
 			c.w.WriteString(`WITH _sg_input AS (SELECT `)
 			c.renderParam(Param{Name: qc.ActionVar, Type: "json"})
 			c.w.WriteString(` :: json AS j), `)
@@ -44,11 +53,13 @@ func (co *Compiler) compileMutation(
 	case qcode.QTDelete:
 		c.renderDelete()
 	default:
-		return
+		return nil
 	}
 
 	c.renderUnionStmt()
-	co.CompileQuery(w, qc, c.md)
+	c.renderAudit()
+	c.CompileQuery(c.w, qc, c.md)
+	return nil
 }
 
 func (c *compilerContext) renderUnionStmt() {
@@ -232,10 +243,15 @@ func (c *compilerContext) renderNestedRelTables(m qcode.Mutate, prefix bool) {
 
 func (c *compilerContext) renderUpsert() {
 	sel := c.qc.Selects[0]
+	m := c.qc.Mutates[0]
+
+	if m.OnConflict != nil {
+		c.renderOnConflict(m)
+		return
+	}
 
 	c.renderInsert()
 	c.w.WriteString(` ON CONFLICT (`)
-	m := c.qc.Mutates[0]
 
 	i := 0
 	for _, col := range m.Cols {
@@ -255,36 +271,124 @@ func (c *compilerContext) renderUpsert() {
 
 	c.w.WriteString(` DO UPDATE SET `)
 
-	for i, col := range m.Cols {
-		if i != 0 {
-			c.w.WriteString(`, `)
+	i = 0
+	for _, col := range m.Cols {
+		if col.Col.Name == m.Version.Col.Name {
+			continue
 		}
+		i = c.renderComma(i)
 		c.w.WriteString(col.Col.Name)
 		c.w.WriteString(` = EXCLUDED.`)
 		c.w.WriteString(col.Col.Name)
 	}
+	c.renderUpsertVersion(m, i)
+
+	c.w.WriteString(` WHERE `)
+	c.renderExp(m.Ti, sel.Where.Exp, false)
+	c.renderUpsertVersionWhere(m)
+	c.w.WriteString(` RETURNING *) `)
+}
+
+// renderOnConflict renders an upsert using the conflict target
+// and update columns from the on_conflict argument
+func (c *compilerContext) renderOnConflict(m qcode.Mutate) {
+	sel := c.qc.Selects[0]
+	oc := m.OnConflict
+
+	c.renderInsert()
+	c.w.WriteString(` ON CONFLICT (`)
+
+	for i, col := range oc.Cols {
+		if i != 0 {
+			c.w.WriteString(`, `)
+		}
+		c.quoted(col.Name)
+	}
+	c.w.WriteString(`)`)
+
+	c.w.WriteString(` DO UPDATE SET `)
+
+	i := 0
+	for _, col := range oc.UpdateCols {
+		if col.Name == m.Version.Col.Name {
+			continue
+		}
+		i = c.renderComma(i)
+		c.quoted(col.Name)
+		c.w.WriteString(` = EXCLUDED.`)
+		c.quoted(col.Name)
+	}
+	c.renderUpsertVersion(m, i)
 
 	c.w.WriteString(` WHERE `)
 	c.renderExp(m.Ti, sel.Where.Exp, false)
+
+	if oc.Where.Exp != nil {
+		c.w.WriteString(` AND `)
+		c.renderExp(m.Ti, oc.Where.Exp, false)
+	}
+	c.renderUpsertVersionWhere(m)
 	c.w.WriteString(` RETURNING *) `)
 }
 
+// renderUpsertVersion bumps the version column of the existing row
+func (c *compilerContext) renderUpsertVersion(m qcode.Mutate, i int) {
+	if m.Version.Col.Name == "" {
+		return
+	}
+	c.renderComma(i)
+	c.renderVersionSet(m)
+}
+
+// renderUpsertVersionWhere only lets the existing row be updated when
+// it has the version sent with the new row
+func (c *compilerContext) renderUpsertVersionWhere(m qcode.Mutate) {
+	if m.Version.Col.Name == "" {
+		return
+	}
+	c.w.WriteString(` AND `)
+	c.colWithTable(m.Ti.Name, m.Version.Col.Name)
+	c.w.WriteString(` = EXCLUDED.`)
+	c.quoted(m.Version.Col.Name)
+}
+
 func (c *compilerContext) renderDelete() {
 	sel := c.qc.Selects[0]
+	m := c.qc.Mutates[0]
 
 	c.w.WriteString(`WITH `)
 	c.quoted(sel.Table)
 
-	c.w.WriteString(` AS (DELETE FROM `)
-	c.quoted(sel.Table)
-	c.w.WriteString(` WHERE `)
-	c.renderExp(sel.Ti, sel.Where.Exp, false)
+	if m.SoftDelete.Name != "" {
+		c.w.WriteString(` AS (UPDATE `)
+		c.quoted(sel.Table)
+		c.renderSoftDelete(m)
+	} else {
+		c.w.WriteString(` AS (DELETE FROM `)
+		c.quoted(sel.Table)
+		c.w.WriteString(` WHERE `)
+		c.renderExp(sel.Ti, sel.Where.Exp, false)
+	}
 
 	c.w.WriteString(` RETURNING `)
 	c.quoted(sel.Table)
 	c.w.WriteString(`.*) `)
 }
 
+// renderSoftDelete renders the set and where clauses of the update
+// used to soft delete rows, rows already deleted are left as is
+func (c *compilerContext) renderSoftDelete(m qcode.Mutate) {
+	sel := c.qc.Selects[0]
+
+	c.w.WriteString(` SET `)
+	c.quoted(m.SoftDelete.Name)
+	c.w.WriteString(` = CURRENT_TIMESTAMP WHERE `)
+	c.renderExp(sel.Ti, sel.Where.Exp, false)
+	c.w.WriteString(` AND `)
+	c.colWithTable(sel.Table, m.SoftDelete.Name)
+	c.w.WriteString(` IS NULL`)
+}
+
 func (c *compilerContext) renderOneToManyConnectStmt(m qcode.Mutate) {
 	// Render only for parent-to-child relationship of one-to-one
 	// For this to work the json child needs to found first so it's primary key
@@ -446,6 +550,13 @@ func (c *compilerContext) renderValues(m qcode.Mutate, prefix bool) {
 		n := c.renderInsertUpdateColumns(m, true)
 		c.renderNestedRelColumns(m, true, prefix, n)
 
+		if c.copy {
+			c.w.WriteString(` FROM `)
+			c.quoted(CopyTable)
+			c.w.WriteString(` t`)
+			return
+		}
+
 		c.w.WriteString(` FROM _sg_input i`)
 		c.renderNestedRelTables(m, prefix)
 
@@ -0,0 +1,660 @@
+//nolint:errcheck
+package psql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dosco/graphjin/core/internal/graph"
+	"github.com/dosco/graphjin/core/internal/qcode"
+	"github.com/dosco/graphjin/core/internal/sdata"
+)
+
+// MySQL has no writable CTEs so mutations are rendered as a list of
+// statements that pass values to each other using session variables.
+// The statements must be run in order within a single transaction and
+// the last one returns the result. Use Metadata.Stmts to split them and
+// run Metadata.Reset afterwards to clear the variables, since they are
+// kept on the connection.
+//
+// @_gj_input           the json data of the mutation
+// @_gj_m<id>           json array of primary keys of the rows changed by
+//                      mutate <id> or the value found by a connect
+// @_gj_m<id>_<column>  json array of values of a column of those rows
+// @_gj_i               index of the row inserted by statements run for
+//                      every row of a bulk insert
+
+func (c *compilerContext) renderMySQLMutation() error {
+	qc := c.qc
+
+	if qc.SType == qcode.QTDelete {
+		c.renderMySQLDelete()
+		return nil
+	}
+
+	root, ok := mysqlRootMutate(qc)
+	if !ok {
+		return fmt.Errorf("mysql: mutation root not found")
+	}
+
+	if root.IsArray && len(qc.Mutates) > 1 {
+		return fmt.Errorf("mysql: nested mutations are not supported with bulk data")
+	}
+
+	for _, m := range qc.Mutates {
+		if m.Check.Exp != nil {
+			return fmt.Errorf("mysql: insert filters are not supported on table '%s'", m.Ti.Name)
+		}
+		switch m.Type {
+		case qcode.MTInsert, qcode.MTUpdate, qcode.MTUpsert:
+			if m.Ti.PrimaryCol.Name == "" {
+				return fmt.Errorf("mysql: table '%s' has no primary key", m.Ti.Name)
+			}
+		}
+		if m.Version.Col.Name != "" {
+			return fmt.Errorf("mysql: version_column is not supported on table '%s'", m.Ti.Name)
+		}
+		if m.OnConflict != nil && m.OnConflict.Constraint != "" {
+			return fmt.Errorf("mysql: on_conflict constraint is not supported, all unique keys are checked")
+		}
+		if m.Type == qcode.MTUpsert && m.IsArray {
+			if _, ok := mysqlPrimaryCol(m); !ok {
+				return fmt.Errorf("mysql: bulk upserts require the primary key in the data")
+			}
+		}
+	}
+
+	if c.isJSON {
+		c.renderMySQLSet(`@_gj_input`)
+		c.w.WriteString(`CONVERT(`)
+		c.renderParam(Param{Name: qc.ActionVar, Type: "json"})
+		c.w.WriteString(` USING utf8mb4)`)
+		c.endStmt()
+	}
+
+	for _, m := range qc.Mutates {
+		switch {
+		case m.Type == qcode.MTInsert:
+			c.renderMySQLInsert(m, false)
+
+		case m.Type == qcode.MTUpsert:
+			c.renderMySQLInsert(m, true)
+
+		case m.Type == qcode.MTUpdate:
+			c.renderMySQLUpdate(m)
+
+		case m.Type == qcode.MTConnect && m.Rel.Type == sdata.RelOneToMany:
+			c.renderMySQLOneToManyConnect(m)
+
+		case m.Type == qcode.MTConnect && m.Rel.Type == sdata.RelOneToOne:
+			c.renderMySQLOneToOneConnect(m)
+
+		case m.Type == qcode.MTDisconnect && m.Rel.Type == sdata.RelOneToOne:
+			c.renderMySQLOneToOneDisconnect(m)
+		}
+	}
+
+	c.CompileQuery(c.w, qc, c.md)
+	return nil
+}
+
+// renderMySQLDelete saves the result before deleting the rows since
+// they cannot be selected afterwards.
+func (c *compilerContext) renderMySQLDelete() {
+	sel := c.qc.Selects[0]
+
+	c.renderMySQLSet(`@_gj_result`)
+	c.w.WriteString(`(`)
+	c.CompileQuery(c.w, c.qc, c.md)
+	c.w.WriteString(`)`)
+	c.endStmt()
+
+	if m := c.qc.Mutates[0]; m.SoftDelete.Name != "" {
+		c.w.WriteString(`UPDATE `)
+		c.quoted(sel.Table)
+		c.renderSoftDelete(m)
+	} else {
+		c.w.WriteString(`DELETE FROM `)
+		c.quoted(sel.Table)
+		c.w.WriteString(` WHERE `)
+		c.renderExp(sel.Ti, sel.Where.Exp, false)
+	}
+	c.endStmt()
+
+	c.w.WriteString(`SELECT @_gj_result`)
+}
+
+func (c *compilerContext) renderMySQLInsert(m qcode.Mutate, upsert bool) {
+	pc, ok := mysqlPrimaryCol(m)
+
+	// auto-increment values of a multi-row insert are not always
+	// consecutive so the rows are inserted one at a time to get
+	// the value of each from LAST_INSERT_ID()
+	if !ok && m.IsJSON && m.IsArray && c.mysqlCapture(m) {
+		c.renderMySQLInsertEach(m)
+		return
+	}
+
+	c.w.WriteString(`INSERT INTO `)
+	c.quoted(m.Ti.Name)
+
+	c.w.WriteString(` (`)
+	n := c.renderInsertUpdateColumns(m, false)
+	c.renderNestedRelColumns(m, false, false, n)
+	c.w.WriteString(`)`)
+
+	if m.IsJSON {
+		c.w.WriteString(` SELECT `)
+		c.renderMySQLValues(m)
+		c.w.WriteString(` FROM`)
+		c.renderMySQLJSONTable(m, false)
+	} else {
+		c.w.WriteString(` VALUES (`)
+		c.renderMySQLValues(m)
+		c.w.WriteString(`)`)
+	}
+
+	if upsert {
+		c.renderMySQLUpsert(m)
+	}
+	c.endStmt()
+
+	if !c.mysqlCapture(m) {
+		return
+	}
+
+	c.renderMySQLSetVar(m, "")
+
+	switch {
+	case ok && m.IsJSON && pc.Value == "" && m.IsArray:
+		c.renderMySQLJSONExtract(append(append([]string{}, m.Path...), "[*]", pc.FieldName))
+
+	case ok && m.IsJSON && pc.Value == "":
+		c.w.WriteString(`JSON_ARRAY(`)
+		c.renderMySQLJSONExtract(append(append([]string{}, m.Path...), pc.FieldName))
+		c.w.WriteString(`)`)
+
+	case ok:
+		c.w.WriteString(`JSON_ARRAY(`)
+		c.renderMySQLColValue(m, pc)
+		c.w.WriteString(`)`)
+
+	default:
+		c.w.WriteString(`JSON_ARRAY(LAST_INSERT_ID())`)
+	}
+	c.endStmt()
+
+	c.renderMySQLCaptureCols(m)
+}
+
+func (c *compilerContext) renderMySQLInsertEach(m qcode.Mutate) {
+	c.renderMySQLSetVar(m, "")
+	c.w.WriteString(`JSON_ARRAY(), @_gj_i = 0`)
+	c.md.addVar(`@_gj_i`)
+	c.endStmt()
+
+	c.w.WriteString(`INSERT INTO `)
+	c.quoted(m.Ti.Name)
+
+	c.w.WriteString(` (`)
+	n := c.renderInsertUpdateColumns(m, false)
+	c.renderNestedRelColumns(m, false, false, n)
+	c.w.WriteString(`) SELECT `)
+	c.renderMySQLValues(m)
+	c.w.WriteString(` FROM`)
+	c.renderMySQLJSONTable(m, true)
+	c.endEachStmt()
+
+	c.renderMySQLSetVar(m, "")
+	c.w.WriteString(`JSON_ARRAY_APPEND(`)
+	c.renderMySQLVar(m, "")
+	c.w.WriteString(`, '$', LAST_INSERT_ID()), @_gj_i = @_gj_i + 1`)
+	c.endEachStmt()
+
+	c.renderMySQLCaptureCols(m)
+}
+
+func (c *compilerContext) renderMySQLUpsert(m qcode.Mutate) {
+	sel := c.qc.Selects[0]
+
+	c.w.WriteString(` ON DUPLICATE KEY UPDATE `)
+
+	var cols []sdata.DBColumn
+	var where *qcode.Exp
+
+	if m.OnConflict != nil {
+		cols = m.OnConflict.UpdateCols
+		where = m.OnConflict.Where.Exp
+	} else {
+		for _, col := range m.Cols {
+			cols = append(cols, col.Col)
+		}
+	}
+
+	for i, col := range cols {
+		if i != 0 {
+			c.w.WriteString(`, `)
+		}
+		c.colWithTable(m.Ti.Name, col.Name)
+		c.w.WriteString(` = IF(`)
+		c.renderExp(m.Ti, sel.Where.Exp, false)
+		if where != nil {
+			c.w.WriteString(` AND `)
+			c.renderExp(m.Ti, where, false)
+		}
+		c.w.WriteString(`, VALUES(`)
+		c.quoted(col.Name)
+		c.w.WriteString(`), `)
+		c.colWithTable(m.Ti.Name, col.Name)
+		c.w.WriteString(`)`)
+	}
+
+	// makes LAST_INSERT_ID() return the primary key of an updated row
+	pk := m.Ti.PrimaryCol.Name
+	if len(cols) != 0 {
+		c.w.WriteString(`, `)
+	}
+	c.colWithTable(m.Ti.Name, pk)
+	c.w.WriteString(` = LAST_INSERT_ID(`)
+	c.colWithTable(m.Ti.Name, pk)
+	c.w.WriteString(`)`)
+}
+
+func (c *compilerContext) renderMySQLUpdate(m qcode.Mutate) {
+	// the rows are found before the update since it
+	// might change the columns used to find them
+	if c.mysqlCapture(m) {
+		c.renderMySQLSetVar(m, "")
+		c.w.WriteString(`(SELECT JSON_ARRAYAGG(`)
+		c.quoted(m.Ti.PrimaryCol.Name)
+		c.w.WriteString(`) FROM `)
+		c.quoted(m.Ti.Name)
+		c.renderMySQLUpdateWhere(m)
+		c.w.WriteString(`)`)
+		c.endStmt()
+	}
+
+	// the relationship column of a nested update already
+	// matches the parent so it's not set again
+	var rcols []qcode.MRColumn
+	for _, col := range m.RCols {
+		if m.ParentID != -1 && m.Rel.Type == sdata.RelOneToMany &&
+			col.Col.Name == m.Rel.Left.Col.Name {
+			continue
+		}
+		rcols = append(rcols, col)
+	}
+
+	if len(m.Cols) != 0 || len(rcols) != 0 {
+		c.w.WriteString(`UPDATE `)
+		c.quoted(m.Ti.Name)
+
+		if m.IsJSON {
+			c.w.WriteString(`,`)
+			c.renderMySQLJSONTable(m, false)
+		}
+
+		c.w.WriteString(` SET `)
+		i := 0
+		for _, col := range m.Cols {
+			i = c.renderComma(i)
+			c.colWithTable(m.Ti.Name, col.Col.Name)
+			c.w.WriteString(` = `)
+			c.renderMySQLColValue(m, col)
+		}
+		for _, col := range rcols {
+			i = c.renderComma(i)
+			c.colWithTable(m.Ti.Name, col.Col.Name)
+			c.w.WriteString(` = `)
+			c.renderMySQLDepValue(m, col.VCol)
+		}
+
+		c.renderMySQLUpdateWhere(m)
+		c.endStmt()
+	}
+
+	if c.mysqlCapture(m) {
+		c.renderMySQLCaptureCols(m)
+	}
+}
+
+func (c *compilerContext) renderMySQLUpdateWhere(m qcode.Mutate) {
+	c.w.WriteString(` WHERE `)
+
+	if m.ParentID == -1 {
+		c.renderExp(m.Ti, c.qc.Selects[0].Where.Exp, false)
+		return
+	}
+
+	rel := m.Rel
+	c.w.WriteString(`(`)
+	c.colWithTable(rel.Left.Col.Table, rel.Left.Col.Name)
+	c.w.WriteString(` MEMBER OF(`)
+	c.renderMySQLVar(c.qc.Mutates[m.ParentID], rel.Right.Col.Name)
+	c.w.WriteString(`))`)
+
+	if rel.Type == sdata.RelOneToOne && m.Where.Exp != nil {
+		path := append(append([]string{}, m.Path...), "where")
+		c.w.WriteString(` AND `)
+		c.renderExpPath(m.Ti, m.Where.Exp, false, path)
+	}
+}
+
+// renderMySQLOneToManyConnect finds the value to set in the related
+// column of the parent. Eg. Create product and connect a user to it.
+func (c *compilerContext) renderMySQLOneToManyConnect(m qcode.Mutate) {
+	c.renderMySQLSetVar(m, "")
+	c.w.WriteString(`(SELECT `)
+	c.quoted(m.Rel.Left.Col.Name)
+	c.w.WriteString(` FROM `)
+	c.quoted(m.Ti.Name)
+	c.w.WriteString(` WHERE `)
+	c.renderExpPath(m.Ti, m.Where.Exp, false, m.Path)
+	c.w.WriteString(` LIMIT 1)`)
+	c.endStmt()
+}
+
+func (c *compilerContext) renderMySQLOneToOneConnect(m qcode.Mutate) {
+	c.w.WriteString(`UPDATE `)
+	c.quoted(m.Ti.Name)
+	c.w.WriteString(` SET `)
+	c.colWithTable(m.Ti.Name, m.Rel.Left.Col.Name)
+	c.w.WriteString(` = `)
+	c.renderMySQLDepValue(m, m.Rel.Right.Col)
+	c.w.WriteString(` WHERE `)
+	c.renderExpPath(m.Ti, m.Where.Exp, false, m.Path)
+	c.endStmt()
+}
+
+func (c *compilerContext) renderMySQLOneToOneDisconnect(m qcode.Mutate) {
+	c.w.WriteString(`UPDATE `)
+	c.quoted(m.Ti.Name)
+	c.w.WriteString(` SET `)
+	c.colWithTable(m.Ti.Name, m.Rel.Left.Col.Name)
+	c.w.WriteString(` = NULL WHERE (`)
+	c.colWithTable(m.Ti.Name, m.Rel.Left.Col.Name)
+	c.w.WriteString(` MEMBER OF(`)
+	if d, ok := c.mysqlDep(m, m.Rel.Right.Col.Table); ok {
+		c.renderMySQLVar(d, m.Rel.Right.Col.Name)
+	} else {
+		c.w.WriteString(`NULL`)
+	}
+	c.w.WriteString(`)) AND `)
+	c.renderExpPath(m.Ti, m.Where.Exp, false, m.Path)
+	c.endStmt()
+}
+
+// renderMySQLMutationWhere limits the result of a mutation to the rows
+// it changed.
+func (c *compilerContext) renderMySQLMutationWhere(sel *qcode.Select) {
+	m, _ := mysqlRootMutate(c.qc)
+
+	c.w.WriteString(` WHERE `)
+	c.colWithTable(sel.Table, sel.Ti.PrimaryCol.Name)
+	c.w.WriteString(` MEMBER OF(`)
+	c.renderMySQLVar(m, "")
+	c.w.WriteString(`)`)
+}
+
+func (c *compilerContext) renderMySQLValues(m qcode.Mutate) {
+	i := 0
+	for _, col := range m.Cols {
+		i = c.renderComma(i)
+		c.renderMySQLColValue(m, col)
+	}
+	for _, col := range m.RCols {
+		i = c.renderComma(i)
+		c.renderMySQLDepValue(m, col.VCol)
+	}
+}
+
+func (c *compilerContext) renderMySQLColValue(m qcode.Mutate, col qcode.MColumn) {
+	// v will be a blank strings unless the value is from a preset
+	v := col.Value
+
+	if v != "" && v[0] == '$' {
+		if v1, ok := c.svars[v[1:]]; ok {
+			v = v1
+		}
+	}
+
+	switch {
+	case len(v) > 1 && v[0] == '$':
+		c.renderParam(Param{Name: v[1:], Type: col.Col.Type})
+
+	case strings.HasPrefix(v, "sql:"):
+		c.w.WriteString(`(`)
+		c.renderVar(v[4:])
+		c.w.WriteString(`)`)
+
+	case v == "now":
+		c.w.WriteString(`NOW()`)
+
+	case v != "":
+		c.squoted(v)
+
+	case m.IsJSON:
+		c.colWithTable("t", col.Col.Name)
+
+	default:
+		field := m.Data.CMap[col.FieldName]
+		v = field.Val
+
+		if field.Type == graph.NodeVar {
+			if v1, ok := c.svars[v]; ok {
+				v = v1
+			} else {
+				c.renderParam(Param{Name: v, Type: col.Col.Type})
+				return
+			}
+		}
+		c.squoted(v)
+	}
+}
+
+// renderMySQLDepValue renders the value of a column of the
+// mutate that m depends on.
+func (c *compilerContext) renderMySQLDepValue(m qcode.Mutate, col sdata.DBColumn) {
+	d, ok := c.mysqlDep(m, col.Table)
+
+	switch {
+	case !ok, d.Type == qcode.MTDisconnect:
+		c.w.WriteString(`NULL`)
+
+	case d.Type == qcode.MTConnect:
+		c.renderMySQLVar(d, "")
+
+	default:
+		c.w.WriteString(`JSON_UNQUOTE(JSON_EXTRACT(`)
+		c.renderMySQLVar(d, col.Name)
+		c.w.WriteString(`, '$[0]'))`)
+	}
+}
+
+// renderMySQLJSONTable reads the rows of the mutation from the input, with
+// each set only the row at @_gj_i is read.
+func (c *compilerContext) renderMySQLJSONTable(m qcode.Mutate, each bool) {
+	switch {
+	case each:
+		c.w.WriteString(` JSON_TABLE(JSON_EXTRACT(@_gj_input, CONCAT('`)
+		c.renderMySQLJSONPath(m.Path)
+		c.w.WriteString(`[', @_gj_i, ']')), '$' COLUMNS (`)
+	default:
+		c.w.WriteString(` JSON_TABLE(@_gj_input, '`)
+		c.renderMySQLJSONPath(m.Path)
+		if m.IsArray {
+			c.w.WriteString(`[*]`)
+		}
+		c.w.WriteString(`' COLUMNS (`)
+	}
+
+	i := 0
+	for _, col := range m.Cols {
+		if col.Value != "" {
+			continue
+		}
+		i = c.renderComma(i)
+		c.quoted(col.Col.Name)
+		c.w.WriteString(` `)
+		c.w.WriteString(mysqlJSONType(col.Col.Type))
+		c.w.WriteString(` PATH '`)
+		c.renderMySQLJSONPath([]string{col.FieldName})
+		c.w.WriteString(`'`)
+	}
+
+	if i == 0 {
+		c.w.WriteString(`_gj_row FOR ORDINALITY`)
+	}
+	c.w.WriteString(`)) AS t`)
+}
+
+func (c *compilerContext) renderMySQLJSONExtract(path []string) {
+	c.w.WriteString(`JSON_EXTRACT(@_gj_input, '`)
+	c.renderMySQLJSONPath(path)
+	c.w.WriteString(`')`)
+}
+
+func (c *compilerContext) renderMySQLJSONPath(path []string) {
+	c.w.WriteString(`$`)
+	for _, p := range path {
+		if p == "[*]" {
+			c.w.WriteString(p)
+			continue
+		}
+		c.w.WriteString(`."`)
+		c.w.WriteString(p)
+		c.w.WriteString(`"`)
+	}
+}
+
+// renderMySQLCaptureCols saves the values of the columns
+// needed by the mutates that depend on m.
+func (c *compilerContext) renderMySQLCaptureCols(m qcode.Mutate) {
+	for _, col := range c.mysqlDepCols(m) {
+		c.renderMySQLSetVar(m, col)
+		c.w.WriteString(`(SELECT JSON_ARRAYAGG(`)
+		c.quoted(col)
+		c.w.WriteString(`) FROM `)
+		c.quoted(m.Ti.Name)
+		c.w.WriteString(` WHERE `)
+		c.colWithTable(m.Ti.Name, m.Ti.PrimaryCol.Name)
+		c.w.WriteString(` MEMBER OF(`)
+		c.renderMySQLVar(m, "")
+		c.w.WriteString(`))`)
+		c.endStmt()
+	}
+}
+
+func (c *compilerContext) renderMySQLSetVar(m qcode.Mutate, col string) {
+	c.renderMySQLSet(mysqlVar(m, col))
+}
+
+func (c *compilerContext) renderMySQLVar(m qcode.Mutate, col string) {
+	c.w.WriteString(mysqlVar(m, col))
+}
+
+// renderMySQLSet starts setting a session variable and adds
+// it to the ones cleared by Metadata.Reset.
+func (c *compilerContext) renderMySQLSet(name string) {
+	c.w.WriteString(`SET `)
+	c.w.WriteString(name)
+	c.w.WriteString(` = `)
+	c.md.addVar(name)
+}
+
+func mysqlVar(m qcode.Mutate, col string) string {
+	v := `@_gj_m` + strconv.Itoa(int(m.ID))
+	if col != "" {
+		v += `_` + col
+	}
+	return v
+}
+
+// mysqlCapture returns true if the rows changed by m are needed
+// by the result or by other mutates.
+func (c *compilerContext) mysqlCapture(m qcode.Mutate) bool {
+	if r, ok := mysqlRootMutate(c.qc); ok && r.ID == m.ID {
+		return true
+	}
+	return len(c.mysqlDepCols(m)) != 0
+}
+
+// mysqlDepCols returns the columns of m used by the mutates that depend on it.
+func (c *compilerContext) mysqlDepCols(m qcode.Mutate) []string {
+	var cols []string
+	cm := make(map[string]struct{})
+
+	add := func(col sdata.DBColumn) {
+		if col.Table != m.Ti.Name {
+			return
+		}
+		if _, ok := cm[col.Name]; ok {
+			return
+		}
+		cm[col.Name] = struct{}{}
+		cols = append(cols, col.Name)
+	}
+
+	for _, m1 := range c.qc.Mutates {
+		if _, ok := m1.DependsOn[m.ID]; !ok {
+			continue
+		}
+		for _, col := range m1.RCols {
+			add(col.VCol)
+		}
+		add(m1.Rel.Right.Col)
+	}
+	return cols
+}
+
+func (c *compilerContext) mysqlDep(m qcode.Mutate, table string) (qcode.Mutate, bool) {
+	for id := range m.DependsOn {
+		if d := c.qc.Mutates[id]; d.Ti.Name == table && d.Type != qcode.MTNone {
+			return d, true
+		}
+	}
+	return qcode.Mutate{}, false
+}
+
+func mysqlRootMutate(qc *qcode.QCode) (qcode.Mutate, bool) {
+	for _, m := range qc.Mutates {
+		if m.ParentID != -1 {
+			continue
+		}
+		switch m.Type {
+		case qcode.MTInsert, qcode.MTUpdate, qcode.MTUpsert:
+			return m, true
+		}
+	}
+	return qcode.Mutate{}, false
+}
+
+func mysqlPrimaryCol(m qcode.Mutate) (qcode.MColumn, bool) {
+	for _, col := range m.Cols {
+		if col.Col.Name == m.Ti.PrimaryCol.Name {
+			return col, true
+		}
+	}
+	return qcode.MColumn{}, false
+}
+
+// mysqlJSONType returns the type to read a column as from json
+func mysqlJSONType(t string) string {
+	if n := strings.IndexAny(t, "( "); n != -1 {
+		t = t[:n]
+	}
+
+	switch strings.ToLower(t) {
+	case "json":
+		return "JSON"
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint", "bit", "bool", "boolean":
+		return "BIGINT"
+	case "decimal", "numeric":
+		return "DECIMAL(65,30)"
+	case "float", "double", "real":
+		return "DOUBLE"
+	default:
+		return "LONGTEXT"
+	}
+}
@@ -2,7 +2,12 @@ package psql_test
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+
+	"github.com/dosco/graphjin/core/internal/psql"
+	"github.com/dosco/graphjin/core/internal/qcode"
+	"github.com/dosco/graphjin/core/internal/sdata"
 )
 
 func singleUpsert(t *testing.T) {
@@ -35,6 +40,186 @@ func singleUpsertWhere(t *testing.T) {
 	compileGQLToPSQL(t, gql, vars, "user")
 }
 
+func upsertOnConflict(t *testing.T) {
+	gql := `mutation {
+		products(upsert: $upsert, where: { id: { eq: 1 } }, on_conflict: {
+			constraint: "products_name_user_id_key",
+			update_columns: [description],
+			where: { price: { lt: 10 } } }) {
+			id
+			name
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"upsert": json.RawMessage(` { "name": "my_name", "description": "my_desc", "user_id": 5 }`),
+	}
+
+	qc, err := qcompile.Compile([]byte(gql), vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sql, err := pcompile.CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `ON CONFLICT ("name", "user_id") DO UPDATE SET "description" = EXCLUDED."description" WHERE`
+	if !strings.Contains(string(sql), exp) {
+		t.Errorf("conflict target missing: %s", sql)
+	}
+
+	if !strings.Contains(string(sql), `AND (("products".price) < '10') RETURNING`) {
+		t.Errorf("conflict filter missing: %s", sql)
+	}
+
+	_, err = compileGQLForDialect(t, "mysql", gql, vars, "user")
+	if err == nil {
+		t.Error("expected an error for a mysql conflict constraint")
+	}
+}
+
+func upsertOnConflictInvalid(t *testing.T) {
+	vars := map[string]json.RawMessage{
+		"upsert": json.RawMessage(` { "name": "my_name", "user_id": 5 }`),
+	}
+
+	args := []string{
+		// unknown constraint
+		`constraint: "products_name_key"`,
+		// blocked for the role
+		`update_columns: [price]`,
+		// not in the upsert data
+		`update_columns: [description]`,
+		// not a column
+		`update_columns: [name], where: { price: { lt: 10 } }, target: "name"`,
+	}
+
+	for _, a := range args {
+		gql := `mutation {
+			products(upsert: $upsert, where: { id: { eq: 1 } }, on_conflict: { ` + a + ` }) {
+				id
+			}
+		}`
+		compileGQLToPSQLExpectErr(t, gql, vars, "user")
+	}
+
+	gql := `mutation {
+		products(insert: $upsert, on_conflict: { update_columns: [name] }) {
+			id
+		}
+	}`
+	compileGQLToPSQLExpectErr(t, gql, vars, "user")
+}
+
+func softDelete(t *testing.T) {
+	schema, err := sdata.GetTestSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qcc, err := qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig: map[string]qcode.TConfig{
+			"publiccomments": {SoftDelete: "deleted_at"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = qcc.AddRole("user", "public", "comments", qcode.TRConfig{
+		Query: qcode.QueryConfig{WithDeleted: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pcc := psql.NewCompiler(psql.Config{})
+
+	compile := func(gql, role string) (string, error) {
+		qc, err := qcc.Compile([]byte(gql), nil, role)
+		if err != nil {
+			return "", err
+		}
+		_, sql, err := pcc.CompileEx(qc)
+		return string(sql), err
+	}
+
+	filter := `(("comments".deleted_at) IS NULL)`
+
+	queries := []string{
+		`query { comments { id } }`,
+		`query { products { id comments { id } } }`,
+		`query { products(where: { comments: { body: { eq: "x" } } }) { id } }`,
+	}
+
+	for _, gql := range queries {
+		sql, err := compile(gql, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(sql, filter) {
+			t.Errorf("soft delete filter missing: %s", sql)
+		}
+	}
+
+	sql, err := compile(`query { comments(with_deleted: true) { id } }`, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sql, filter) {
+		t.Errorf("unexpected soft delete filter: %s", sql)
+	}
+
+	if _, err := compile(`query { comments(with_deleted: true) { id } }`, "anon"); err == nil {
+		t.Error("expected with_deleted to be blocked for anon")
+	}
+
+	sql, err = compile(`mutation { comments(delete: true, where: { id: { eq: 1 } }) { id } }`, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `AS (UPDATE "comments" SET "deleted_at" = CURRENT_TIMESTAMP WHERE (("comments".id) = '1') AND "comments".deleted_at IS NULL RETURNING`
+	if !strings.Contains(sql, exp) {
+		t.Errorf("soft delete update missing: %s", sql)
+	}
+
+	// soft deleted rows cannot be updated or upserted
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`{ "id": 1, "body": "x", "deleted_at": null }`),
+	}
+
+	mutations := []struct {
+		gql string
+		exp string
+	}{
+		{
+			`mutation { comments(update: $data, where: { id: { eq: 1 } }) { id } }`,
+			`WHERE ((("comments".deleted_at) IS NULL) AND (("comments".id) = '1')) RETURNING`,
+		},
+		{
+			`mutation { comments(upsert: $data) { id } }`,
+			`WHERE (("comments".deleted_at) IS NULL) RETURNING *)`,
+		},
+	}
+
+	for _, v := range mutations {
+		qc, err := qcc.Compile([]byte(v.gql), vars, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, sql, err := pcc.CompileEx(qc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(sql), v.exp) {
+			t.Errorf("expected '%s' in: %s", v.exp, sql)
+		}
+	}
+}
+
 // func bulkUpsert(t *testing.T) {
 // 	gql := `mutation {
 // 		product(upsert: $upsert, where: { id: { eq: 1 } }) {
@@ -111,11 +296,119 @@ func delete(t *testing.T) {
 // 	}
 // }
 
+func auditLog(t *testing.T) {
+	pcc := psql.NewCompiler(psql.Config{AuditTable: "audit_log"})
+
+	gql := `mutation updateProduct {
+		products(id: $id, update: $data) {
+			id
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`{"name": "my_name", "description": "my_desc"}`),
+	}
+
+	qc, err := qcompile.Compile([]byte(gql), vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sql, err := pcc.CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		`, "_gj_audit" AS (INSERT INTO "audit_log" ("operation", "action", "role", "user_id", "table_name", "row_id", "old_row", "new_row")`,
+		` SELECT 'updateProduct', 'update', 'user', $`,
+		`, 'products', "n".id :: text, (SELECT to_jsonb(o) FROM "public"."products" o WHERE "o".id = "n".id), to_jsonb(n) FROM "products" n) `,
+	}
+	for _, v := range exp {
+		if !strings.Contains(string(sql), v) {
+			t.Errorf("audit statement missing '%s': %s", v, sql)
+		}
+	}
+
+	gql = `mutation {
+		products(id: $id, delete: true) {
+			id
+		}
+	}`
+
+	qc, err = qcompile.Compile([]byte(gql), nil, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sql, err = pcc.CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp1 := ` SELECT NULL, 'delete', 'admin', $2 :: text, 'products', "n".id :: text, (SELECT to_jsonb(o) FROM "public"."products" o WHERE "o".id = "n".id), NULL FROM "products" n) `
+	if !strings.Contains(string(sql), exp1) {
+		t.Errorf("audit statement missing: %s", sql)
+	}
+}
+
+func mutationFunction(t *testing.T) {
+	gql := `mutation {
+		return_purchase(args: { purchase_id: $id }) {
+			id
+			returned
+			customer {
+				id
+			}
+		}
+	}`
+
+	qc, err := qcompile.Compile([]byte(gql), nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sql, err := pcompile.CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		`FROM "return_purchase"("purchase_id" => $1 :: bigint) AS "purchases"`,
+		`FROM "customers" WHERE (("customers".id) = (purchases_0.customer_id))`,
+	}
+	for _, v := range exp {
+		if !strings.Contains(string(sql), v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+
+	// only roles listed can call the function
+	if _, err := qcompile.Compile([]byte(gql), nil, "anon"); err == nil {
+		t.Error("expected an error for a role not allowed to call the function")
+	}
+
+	gql = `query {
+		return_purchase(args: { purchase_id: 1 }) {
+			id
+		}
+	}`
+
+	if _, err := qcompile.Compile([]byte(gql), nil, "user"); err == nil {
+		t.Error("expected an error for a mutation function in a query")
+	}
+}
+
 func TestCompileMutate(t *testing.T) {
 	t.Run("singleUpsert", singleUpsert)
 	t.Run("singleUpsertWhere", singleUpsertWhere)
+	t.Run("upsertOnConflict", upsertOnConflict)
+	t.Run("upsertOnConflictInvalid", upsertOnConflictInvalid)
 	// t.Run("bulkUpsert", bulkUpsert)
 	t.Run("delete", delete)
+	t.Run("softDelete", softDelete)
+	t.Run("auditLog", auditLog)
+	t.Run("mutationFunction", mutationFunction)
 	// t.Run("blockedInsert", blockedInsert)
 	// t.Run("blockedUpdate", blockedUpdate)
 }
@@ -0,0 +1,254 @@
+package psql_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func mysqlNestedInsert(t *testing.T) {
+	gql := `mutation {
+		users(insert: $data) {
+			id
+			products {
+				id
+				name
+			}
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`{
+			"email": "thedude@rug.com",
+			"full_name": "The Dude",
+			"products": {
+				"name": "Apple",
+				"price": 1.25
+			}
+		}`),
+	}
+
+	md, sql, err := compileGQLForDialectMD(t, "mysql", gql, vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{
+		`SET @_gj_input = CONVERT(? USING utf8mb4)`,
+		`INSERT INTO ` + "`users`",
+		`SET @_gj_m0 = JSON_ARRAY(LAST_INSERT_ID())`,
+		`JSON_TABLE(@_gj_input, '$."products"'`,
+		`JSON_UNQUOTE(JSON_EXTRACT(@_gj_m0_id, '$[0]'))`,
+		"`users`.id MEMBER OF(@_gj_m0)",
+	} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+
+	if strings.Contains(sql, `WITH`) {
+		t.Errorf("unexpected 'WITH' in: %s", sql)
+	}
+
+	stmts := md.Stmts(sql)
+	if len(stmts) != 6 {
+		t.Fatalf("expected 6 statements got %d: %s", len(stmts), sql)
+	}
+
+	if st := stmts[0]; st.PStart != 0 || st.PEnd != 1 {
+		t.Errorf("expected the input param in the first statement: %+v", st)
+	}
+
+	if st := stmts[5]; !strings.HasPrefix(st.SQL, `SELECT json_object(`) {
+		t.Errorf("expected the last statement to return the result: %s", st.SQL)
+	}
+
+	exp := `SET @_gj_input = NULL, @_gj_m0 = NULL, @_gj_m0_id = NULL`
+	if r := md.Reset(); r != exp {
+		t.Errorf("expected '%s' got: %s", exp, r)
+	}
+}
+
+func mysqlNestedInsertWithConnect(t *testing.T) {
+	gql := `mutation {
+		products(insert: $data) {
+			id
+			user {
+				id
+			}
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`{
+			"name": "Apple",
+			"user": {
+				"connect": { "id": 5 }
+			}
+		}`),
+	}
+
+	sql, err := compileGQLForDialect(t, "mysql", gql, vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{
+		`SET @_gj_m1 = (SELECT ` + "`id` FROM `users`",
+		`JSON_UNQUOTE(JSON_EXTRACT(@_gj_input, '$."user"."connect"."id"'))`,
+		"`t`.name, @_gj_m1 FROM",
+	} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+}
+
+func mysqlUpdate(t *testing.T) {
+	gql := `mutation {
+		products(update: $data, id: $id) {
+			id
+			name
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`{ "name": "Apple" }`),
+	}
+
+	sql, err := compileGQLForDialect(t, "mysql", gql, vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{
+		"SET @_gj_m0 = (SELECT JSON_ARRAYAGG(`id`) FROM `products` WHERE",
+		"UPDATE `products`, JSON_TABLE(@_gj_input, '$'",
+		"SET `products`.name = `t`.name",
+	} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+}
+
+func mysqlUpsert(t *testing.T) {
+	gql := `mutation {
+		products(upsert: $upsert, where: { id: { eq: 1 } }) {
+			id
+			name
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"upsert": json.RawMessage(`{ "name": "my_name", "description": "my_desc" }`),
+	}
+
+	sql, err := compileGQLForDialect(t, "mysql", gql, vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{
+		`ON DUPLICATE KEY UPDATE`,
+		"VALUES(`name`)",
+		"`products`.id = LAST_INSERT_ID(`products`.id)",
+	} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+}
+
+func mysqlDelete(t *testing.T) {
+	gql := `mutation {
+		products(delete: true, where: { id: { eq: 1 } }) {
+			id
+		}
+	}`
+
+	md, sql, err := compileGQLForDialectMD(t, "mysql", gql, nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmts := md.Stmts(sql)
+	if len(stmts) != 3 {
+		t.Fatalf("expected 3 statements got %d: %s", len(stmts), sql)
+	}
+
+	if !strings.Contains(stmts[0].SQL, `SET @_gj_result = (SELECT json_object(`) {
+		t.Errorf("expected the result to be saved first: %s", stmts[0].SQL)
+	}
+
+	if !strings.HasPrefix(stmts[1].SQL, "DELETE FROM `products`") {
+		t.Errorf("expected a delete: %s", stmts[1].SQL)
+	}
+}
+
+func mysqlNestedBulkInsert(t *testing.T) {
+	gql := `mutation {
+		users(insert: $data) {
+			id
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`[{
+			"email": "thedude@rug.com",
+			"products": { "name": "Apple" }
+		}]`),
+	}
+
+	if _, err := compileGQLForDialect(t, "mysql", gql, vars, "user"); err == nil {
+		t.Error("we were expecting an error")
+	}
+}
+
+func mysqlBulkInsert(t *testing.T) {
+	gql := `mutation {
+		products(insert: $data) {
+			id
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`[{ "name": "Apple" }, { "name": "Orange" }]`),
+	}
+
+	md, sql, err := compileGQLForDialectMD(t, "mysql", gql, vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{
+		`SET @_gj_m0 = JSON_ARRAY(), @_gj_i = 0`,
+		`JSON_TABLE(JSON_EXTRACT(@_gj_input, CONCAT('$[', @_gj_i, ']')), '$' COLUMNS`,
+		`SET @_gj_m0 = JSON_ARRAY_APPEND(@_gj_m0, '$', LAST_INSERT_ID()), @_gj_i = @_gj_i + 1`,
+	} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+
+	var each []string
+	for _, st := range md.Stmts(sql) {
+		if st.Each {
+			each = append(each, st.SQL)
+		}
+	}
+
+	if len(each) != 2 || !strings.HasPrefix(each[0], "INSERT INTO `products`") {
+		t.Errorf("expected the insert to run for every row: %v", each)
+	}
+}
+
+func TestCompileMySQL(t *testing.T) {
+	t.Run("mysqlNestedInsert", mysqlNestedInsert)
+	t.Run("mysqlNestedInsertWithConnect", mysqlNestedInsertWithConnect)
+	t.Run("mysqlUpdate", mysqlUpdate)
+	t.Run("mysqlUpsert", mysqlUpsert)
+	t.Run("mysqlDelete", mysqlDelete)
+	t.Run("mysqlNestedBulkInsert", mysqlNestedBulkInsert)
+	t.Run("mysqlBulkInsert", mysqlBulkInsert)
+}
@@ -38,6 +38,7 @@ func TestMain(m *testing.M) {
 			},
 		},
 		Insert: qcode.InsertConfig{
+			Filters: []string{"{ user_id: { eq: $user_id } }"},
 			Presets: map[string]string{
 				"price":      "$get_price",
 				"user_id":    "$user_id",
@@ -49,6 +50,9 @@ func TestMain(m *testing.M) {
 			Filters: []string{"{ user_id: { eq: $user_id } }"},
 			Presets: map[string]string{"updated_at": "now"},
 		},
+		Upsert: qcode.UpsertConfig{
+			Columns: []string{"id", "name", "description", "user_id"},
+		},
 		Delete: qcode.DeleteConfig{
 			Filters: []string{
 				"{ price: { gt: 0 } }",
@@ -124,6 +128,11 @@ func TestMain(m *testing.M) {
 		log.Fatal(err)
 	}
 
+	err = qcompile.AddRoleFunction("user", "return_purchase")
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	vars := map[string]string{
 		"admin_account_id": "5",
 		"get_price":        "sql:select price from prices where id = $product_id",
@@ -164,3 +173,33 @@ func _compileGQLToPSQL(t *testing.T, gql string, vars qcode.Variables, role stri
 
 	return nil
 }
+
+func compileGQLForDialect(t *testing.T, dbType, gql string, vars qcode.Variables, role string) (string, error) {
+	_, sql, err := compileGQLForDialectMD(t, dbType, gql, vars, role)
+	return sql, err
+}
+
+func compileGQLForDialectMD(t *testing.T, dbType, gql string, vars qcode.Variables, role string) (psql.Metadata, string, error) {
+	dbinfo := sdata.GetTestDBInfo()
+	dbinfo.Type = dbType
+
+	schema, err := sdata.NewDBSchema(dbinfo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qcc, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pcc := psql.NewCompiler(psql.Config{DBType: dbType})
+
+	qc, err := qcc.Compile([]byte(gql), vars, role)
+	if err != nil {
+		return psql.Metadata{}, "", err
+	}
+
+	md, sql, err := pcc.CompileEx(qc)
+	return md, string(sql), err
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/dosco/graphjin/core/internal/qcode"
 	"github.com/dosco/graphjin/core/internal/sdata"
@@ -25,6 +26,8 @@ type Metadata struct {
 	poll   bool
 	params []Param
 	pindex map[string]int
+	stmts  []stmtEnd
+	vars   []string
 }
 
 type compilerContext struct {
@@ -32,25 +35,33 @@ type compilerContext struct {
 	w      *bytes.Buffer
 	qc     *qcode.QCode
 	isJSON bool
+	copy   bool
 	*Compiler
 }
 
 type Variables map[string]json.RawMessage
 
 type Config struct {
-	Vars      map[string]string
-	DBType    string
-	DBVersion int
+	Vars       map[string]string
+	DBType     string
+	DBVersion  int
+	AuditTable string
 }
 
 type Compiler struct {
 	svars map[string]string
 	ct    string // db type
 	cv    int    // db version
+	audit string // audit table
 }
 
 func NewCompiler(conf Config) *Compiler {
-	return &Compiler{svars: conf.Vars, ct: conf.DBType, cv: conf.DBVersion}
+	return &Compiler{
+		svars: conf.Vars,
+		ct:    conf.DBType,
+		cv:    conf.DBVersion,
+		audit: conf.AuditTable,
+	}
 }
 
 func (co *Compiler) CompileEx(qc *qcode.QCode) (Metadata, []byte, error) {
@@ -81,7 +92,12 @@ func (co *Compiler) Compile(w *bytes.Buffer, qc *qcode.QCode) (Metadata, error)
 		co.CompileQuery(w, qc, &md)
 
 	case qcode.QTMutation:
-		co.compileMutation(w, qc, &md)
+		// functions that change data return their rows like a query
+		if len(qc.Selects) != 0 && qc.Selects[0].SetFunc != nil {
+			co.CompileQuery(w, qc, &md)
+		} else {
+			err = co.compileMutation(w, qc, &md)
+		}
 
 	default:
 		err = fmt.Errorf("unknown operation type %d", qc.Type)
@@ -109,6 +125,17 @@ func (co *Compiler) CompileQuery(
 		Compiler: co,
 	}
 
+	if c.ct == "mssql" {
+		c.renderMSSQLRoot(st)
+		c.renderQuery(st, true)
+		return
+	}
+
+	if c.ct == "sqlite" {
+		c.renderSQLiteRoot()
+		return
+	}
+
 	i := 0
 	switch c.ct {
 	case "mysql":
@@ -127,7 +154,7 @@ func (co *Compiler) CompileQuery(
 			c.w.WriteString(sel.FieldName)
 			c.w.WriteString(`', NULL`)
 
-			if sel.Paging.Cursor {
+			if sel.Paging.Cursor && sel.Connection == nil {
 				c.w.WriteString(`, '`)
 				c.w.WriteString(sel.FieldName)
 				c.w.WriteString(`_cursor', NULL`)
@@ -141,7 +168,7 @@ func (co *Compiler) CompileQuery(
 			c.w.WriteString(`.json`)
 
 			// return the cursor for the this child selector as part of the parents json
-			if sel.Paging.Cursor {
+			if sel.Paging.Cursor && sel.Connection == nil {
 				c.w.WriteString(`, '`)
 				c.w.WriteString(sel.FieldName)
 				c.w.WriteString(`_cursor', `)
@@ -160,10 +187,39 @@ func (co *Compiler) CompileQuery(
 	// This helps multi-root work as well as return a null json value when
 	// there are no rows found.
 
-	c.w.WriteString(`) AS __root FROM ((SELECT true)) AS __root_x`)
+	c.w.WriteString(`) AS __root FROM ((SELECT true`)
+	c.renderVersionCheck(c.renderInsertChecks())
+	c.w.WriteString(`)) AS __root_x`)
 	c.renderQuery(st, true)
 }
 
+func (c *compilerContext) renderMSSQLRoot(st *IntStack) {
+	c.w.WriteString(`SELECT (SELECT `)
+
+	for i, id := range c.qc.Roots {
+		if i != 0 {
+			c.w.WriteString(`, `)
+		}
+		sel := &c.qc.Selects[id]
+
+		if sel.SkipRender == qcode.SkipTypeUserNeeded {
+			c.w.WriteString(`NULL`)
+			c.alias(sel.FieldName)
+
+		} else {
+			c.w.WriteString(`JSON_QUERY(__sj_`)
+			int32String(c.w, sel.ID)
+			c.w.WriteString(`.json)`)
+			c.alias(sel.FieldName)
+
+			st.Push(sel.ID + closeBlock)
+			st.Push(sel.ID)
+		}
+	}
+
+	c.w.WriteString(` FOR JSON PATH, INCLUDE_NULL_VALUES, WITHOUT_ARRAY_WRAPPER) AS __root FROM (SELECT 1 AS x) AS __root_x`)
+}
+
 func (c *compilerContext) renderQuery(st *IntStack, multi bool) {
 	for {
 		var sel *qcode.Select
@@ -216,11 +272,29 @@ func (c *compilerContext) renderPluralSelect(sel *qcode.Select) {
 	if sel.Singular {
 		return
 	}
+	if sel.Connection != nil {
+		c.renderConnectionSelect(sel)
+		return
+	}
 	switch c.ct {
 	case "mysql":
 		c.w.WriteString(`SELECT CAST(COALESCE(json_arrayagg(__sj_`)
 		int32String(c.w, sel.ID)
 		c.w.WriteString(`.json), '[]') AS JSON) AS json`)
+	case "mssql":
+		c.w.WriteString(`SELECT COALESCE('[' + STRING_AGG(__sj_`)
+		int32String(c.w, sel.ID)
+		c.w.WriteString(`.json, ',')`)
+		if c.hasRowNum(sel) {
+			c.w.WriteString(` WITHIN GROUP (ORDER BY __sj_`)
+			int32String(c.w, sel.ID)
+			c.w.WriteString(`.__rn)`)
+		}
+		c.w.WriteString(` + ']', '[]') AS json`)
+	case "sqlite":
+		c.w.WriteString(`SELECT json_group_array(json(__sj_`)
+		int32String(c.w, sel.ID)
+		c.w.WriteString(`.json)) AS json`)
 	default:
 		c.w.WriteString(`SELECT COALESCE(jsonb_agg(__sj_`)
 		int32String(c.w, sel.ID)
@@ -243,10 +317,17 @@ func (c *compilerContext) renderPluralSelect(sel *qcode.Select) {
 
 func (c *compilerContext) renderSelect(sel *qcode.Select) {
 	switch c.ct {
-	case "mysql":
+	case "mysql", "sqlite":
 		c.w.WriteString(`SELECT json_object(`)
 		c.renderJSONFields(sel)
 		c.w.WriteString(`) `)
+	case "mssql":
+		// FOR JSON PATH builds the json object from the columns of the
+		// correlated row, nested json values are wrapped in JSON_QUERY
+		// to stop them from being escaped as strings
+		c.w.WriteString(`SELECT (SELECT `)
+		c.renderJSONFields(sel)
+		c.w.WriteString(` FOR JSON PATH, INCLUDE_NULL_VALUES, WITHOUT_ARRAY_WRAPPER) `)
 	default:
 		c.w.WriteString(`SELECT to_jsonb(__sr_`)
 		int32String(c.w, sel.ID)
@@ -262,13 +343,34 @@ func (c *compilerContext) renderSelect(sel *qcode.Select) {
 				c.w.WriteString(`' `)
 			}
 		}
+		if c.hasRest(sel) {
+			c.w.WriteString(`- '__rest' `)
+		}
 	}
 
 	c.w.WriteString(`AS json `)
 
+	if c.hasRowNum(sel) {
+		c.w.WriteString(`, __rn `)
+	}
+
 	// We manually insert the cursor values into row we're building outside
 	// of the generated json object so they can be used higher up in the sql.
-	if sel.Paging.Cursor {
+	// Connections need a cursor for every row so it's built here.
+	switch {
+	case sel.Connection != nil:
+		c.w.WriteString(`, CONCAT_WS(','`)
+		for i := range sel.OrderBy {
+			c.w.WriteString(`, __cur_`)
+			int32String(c.w, int32(i))
+		}
+		c.w.WriteString(`) AS __cursor `)
+
+		if c.hasRest(sel) {
+			c.w.WriteString(`, __rest `)
+		}
+
+	case sel.Paging.Cursor:
 		for i := range sel.OrderBy {
 			c.w.WriteString(`, __cur_`)
 			int32String(c.w, int32(i))
@@ -277,18 +379,37 @@ func (c *compilerContext) renderSelect(sel *qcode.Select) {
 	}
 
 	c.w.WriteString(`FROM (SELECT `)
-	c.renderColumns(sel)
+	n := c.renderColumns(sel)
 
 	// This is how we get the values to use to build the cursor.
 	if sel.Paging.Cursor {
 		for i, ob := range sel.OrderBy {
-			c.w.WriteString(`, LAST_VALUE(`)
-			colWithTableID(c.w, sel.Table, sel.ID, ob.Col.Name)
-			c.w.WriteString(`) OVER() AS __cur_`)
+			if n != 0 {
+				c.w.WriteString(`, `)
+			}
+			if sel.Connection != nil {
+				colWithTableID(c.w, sel.Table, sel.ID, ob.Col.Name)
+			} else {
+				c.w.WriteString(`LAST_VALUE(`)
+				colWithTableID(c.w, sel.Table, sel.ID, ob.Col.Name)
+				c.w.WriteString(`) OVER()`)
+			}
+			c.w.WriteString(` AS __cur_`)
 			int32String(c.w, int32(i))
+			n++
 		}
 	}
 
+	if c.hasRest(sel) {
+		c.w.WriteString(`, `)
+		colWithTableID(c.w, sel.Table, sel.ID, `__rest`)
+	}
+
+	if c.hasRowNum(sel) {
+		c.w.WriteString(`, `)
+		colWithTableID(c.w, sel.Table, sel.ID, `__rn`)
+	}
+
 	c.w.WriteString(` FROM (`)
 	if sel.Rel.Type == sdata.RelRecursive {
 		c.renderRecursiveBaseSelect(sel)
@@ -299,6 +420,16 @@ func (c *compilerContext) renderSelect(sel *qcode.Select) {
 	aliasWithID(c.w, sel.Table, sel.ID)
 }
 
+// hasRowNum returns true if the rows of a list are numbered in the order
+// requested, sql server cannot order a subquery so the rows are aggregated
+// in the order of the number instead
+func (c *compilerContext) hasRowNum(sel *qcode.Select) bool {
+	return c.ct == "mssql" &&
+		!sel.Singular &&
+		len(sel.OrderBy) != 0 &&
+		sel.Rel.Type != sdata.RelRecursive
+}
+
 func (c *compilerContext) renderSelectClose(sel *qcode.Select) {
 	c.w.WriteString(`)`)
 	aliasWithID(c.w, "__sr", sel.ID)
@@ -310,13 +441,20 @@ func (c *compilerContext) renderSelectClose(sel *qcode.Select) {
 }
 
 func (c *compilerContext) renderLateralJoin() {
-	c.w.WriteString(` LEFT OUTER JOIN LATERAL (`)
+	switch c.ct {
+	case "mssql":
+		c.w.WriteString(` OUTER APPLY (`)
+	default:
+		c.w.WriteString(` LEFT OUTER JOIN LATERAL (`)
+	}
 }
 
 func (c *compilerContext) renderLateralJoinClose(sel *qcode.Select) {
 	c.w.WriteString(`)`)
 	aliasWithID(c.w, `__sj`, sel.ID)
-	c.w.WriteString(` ON true`)
+	if c.ct != "mssql" {
+		c.w.WriteString(` ON true`)
+	}
 }
 
 func (c *compilerContext) renderJoinTables(sel *qcode.Select) {
@@ -339,11 +477,23 @@ func (c *compilerContext) renderBaseSelect(sel *qcode.Select) {
 	c.renderDistinctOn(sel)
 	n := c.renderBaseColumns(sel)
 	c.renderFunctions(sel, n)
+
+	// rows left before the limit is applied
+	if c.hasRest(sel) {
+		c.w.WriteString(`, count(*) OVER() AS __rest`)
+	}
+
+	if c.hasRowNum(sel) {
+		c.w.WriteString(`, ROW_NUMBER() OVER (`)
+		c.renderOrderBy(sel)
+		c.w.WriteString(`) AS __rn`)
+	}
 	c.renderFrom(sel)
 	c.renderJoinTables(sel)
 	c.renderFromCursor(sel)
 	c.renderWhere(sel)
 	c.renderGroupBy(sel)
+	c.renderHaving(sel)
 	c.renderOrderBy(sel)
 	c.renderLimit(sel)
 }
@@ -368,6 +518,11 @@ func (c *compilerContext) renderRecursiveBaseSelect(sel *qcode.Select) {
 }
 
 func (c *compilerContext) renderLimit(sel *qcode.Select) {
+	if c.ct == "mssql" {
+		c.renderMSSQLLimit(sel)
+		return
+	}
+
 	switch {
 	case sel.Paging.NoLimit:
 		break
@@ -375,6 +530,13 @@ func (c *compilerContext) renderLimit(sel *qcode.Select) {
 	case sel.Singular:
 		c.w.WriteString(` LIMIT 1`)
 
+	case sel.Paging.LimitVar != "" && c.ct == "sqlite":
+		c.w.WriteString(` LIMIT MIN(`)
+		c.renderParam(Param{Name: sel.Paging.LimitVar, Type: "integer"})
+		c.w.WriteString(`, `)
+		int32String(c.w, sel.Paging.Limit)
+		c.w.WriteString(`)`)
+
 	case sel.Paging.LimitVar != "":
 		c.w.WriteString(` LIMIT LEAST(`)
 		c.renderParam(Param{Name: sel.Paging.LimitVar, Type: "integer"})
@@ -398,6 +560,55 @@ func (c *compilerContext) renderLimit(sel *qcode.Select) {
 	}
 }
 
+// renderMSSQLLimit uses the offset and fetch clauses since sql server
+// has no limit clause, these are only valid when an order by is present
+func (c *compilerContext) renderMSSQLLimit(sel *qcode.Select) {
+	noOffset := sel.Paging.OffsetVar == "" && sel.Paging.Offset == 0
+
+	if len(sel.OrderBy) == 0 {
+		if sel.Paging.NoLimit && noOffset {
+			return
+		}
+		c.w.WriteString(` ORDER BY (SELECT NULL)`)
+	}
+
+	switch {
+	case sel.Paging.OffsetVar != "":
+		c.w.WriteString(` OFFSET `)
+		c.renderParam(Param{Name: sel.Paging.OffsetVar, Type: "integer"})
+		c.w.WriteString(` ROWS`)
+
+	default:
+		c.w.WriteString(` OFFSET `)
+		int32String(c.w, sel.Paging.Offset)
+		c.w.WriteString(` ROWS`)
+	}
+
+	switch {
+	case sel.Paging.NoLimit:
+		break
+
+	case sel.Singular:
+		c.w.WriteString(` FETCH NEXT 1 ROWS ONLY`)
+
+	case sel.Paging.LimitVar != "":
+		c.w.WriteString(` FETCH NEXT (CASE WHEN `)
+		c.renderParam(Param{Name: sel.Paging.LimitVar, Type: "integer"})
+		c.w.WriteString(` < `)
+		int32String(c.w, sel.Paging.Limit)
+		c.w.WriteString(` THEN `)
+		c.renderParam(Param{Name: sel.Paging.LimitVar, Type: "integer"})
+		c.w.WriteString(` ELSE `)
+		int32String(c.w, sel.Paging.Limit)
+		c.w.WriteString(` END) ROWS ONLY`)
+
+	default:
+		c.w.WriteString(` FETCH NEXT `)
+		int32String(c.w, sel.Paging.Limit)
+		c.w.WriteString(` ROWS ONLY`)
+	}
+}
+
 func (c *compilerContext) renderRecursiveCTE(sel *qcode.Select) {
 	c.w.WriteString(`WITH RECURSIVE `)
 	c.quoted("__rcte_" + sel.Table)
@@ -433,20 +644,71 @@ func (c *compilerContext) renderFrom(sel *qcode.Select) {
 	switch sel.Rel.Type {
 	case sdata.RelEmbedded:
 		c.w.WriteString(sel.Rel.Left.Col.Table)
-		c.w.WriteString(`, `)
+
+		switch c.ct {
+		case "mssql":
+			c.w.WriteString(` CROSS APPLY `)
+		default:
+			c.w.WriteString(`, `)
+		}
 
 		switch c.ct {
 		case "mysql":
 			c.renderJSONTable(sel)
+		case "mssql":
+			c.renderOpenJSON(sel)
 		default:
 			c.renderRecordSet(sel)
 		}
 
 	default:
-		c.quoted(sel.Table)
+		if sel.SetFunc != nil {
+			c.renderSetFunc(sel)
+		} else {
+			c.quoted(sel.Table)
+		}
 	}
 }
 
+// renderSetFunc calls the function returning the rows, it's named
+// after the table so the filters and joins work as they do on it
+func (c *compilerContext) renderSetFunc(sel *qcode.Select) {
+	c.quoted(sel.SetFunc.Name)
+	c.w.WriteString(`(`)
+
+	for i, a := range sel.SetFunc.Args {
+		if i != 0 {
+			c.w.WriteString(`, `)
+		}
+		c.quoted(a.Name)
+		c.w.WriteString(` => `)
+
+		val, isVal := c.svars[a.Val]
+
+		switch {
+		case a.ValType == qcode.ValVar && isVal && strings.HasPrefix(val, "sql:"):
+			c.w.WriteString(`(`)
+			c.renderVar(val[4:])
+			c.w.WriteString(`)`)
+		case a.ValType == qcode.ValVar && isVal:
+			c.w.WriteString(`'`)
+			c.renderVar(val)
+			c.w.WriteString(`'`)
+		case a.ValType == qcode.ValVar:
+			c.renderParam(Param{Name: a.Val, Type: a.Type})
+		case a.ValType == qcode.ValStr:
+			c.squoted(a.Val)
+		default:
+			c.w.WriteString(a.Val)
+		}
+		c.w.WriteString(` :: `)
+		c.w.WriteString(a.Type)
+	}
+
+	c.w.WriteString(`) AS `)
+	c.quoted(sel.Table)
+}
+
 func (c *compilerContext) renderFromCursor(sel *qcode.Select) {
 	if sel.Paging.Cursor {
 		c.w.WriteString(`, __cur`)
@@ -473,6 +735,26 @@ func (c *compilerContext) renderJSONTable(sel *qcode.Select) {
 	c.quoted(sel.Table)
 }
 
+func (c *compilerContext) renderOpenJSON(sel *qcode.Select) {
+	c.w.WriteString(`OPENJSON(`)
+	c.colWithTable(sel.Rel.Left.Col.Table, sel.Rel.Left.Col.Name)
+	c.w.WriteString(`) WITH (`)
+
+	for i, col := range sel.Ti.Columns {
+		if i != 0 {
+			c.w.WriteString(`, `)
+		}
+		c.quoted(col.Name)
+		c.w.WriteString(` `)
+		c.w.WriteString(col.Type)
+		c.w.WriteString(` '$.`)
+		c.w.WriteString(col.Name)
+		c.w.WriteString(`'`)
+	}
+	c.w.WriteString(`) AS `)
+	c.quoted(sel.Table)
+}
+
 func (c *compilerContext) renderRecordSet(sel *qcode.Select) {
 	// jsonb_to_recordset('[{"a":1,"b":[1,2,3],"c":"bar"}, {"a":2,"b":[1,2,3],"c":"bar"}]') as x(a int, b text, d text);
 	c.w.WriteString(sel.Ti.Type)
@@ -532,6 +814,12 @@ func (c *compilerContext) renderCursorCTE(sel *qcode.Select) {
 }
 
 func (c *compilerContext) renderWhere(sel *qcode.Select) {
+	if c.ct == "mysql" && c.qc.Type == qcode.QTMutation &&
+		c.qc.SType != qcode.QTDelete && sel.ParentID == -1 {
+		c.renderMySQLMutationWhere(sel)
+		return
+	}
+
 	if sel.Rel.Type == sdata.RelNone && sel.Where.Exp == nil {
 		return
 	}
@@ -546,12 +834,49 @@ func (c *compilerContext) renderGroupBy(sel *qcode.Select) {
 	}
 	c.w.WriteString(` GROUP BY `)
 
-	for i, col := range sel.BCols {
+	if len(sel.GroupBy) != 0 {
+		for i, gb := range sel.GroupBy {
+			if i != 0 {
+				c.w.WriteString(`, `)
+			}
+			if gb.Func != nil {
+				c.renderFuncCall(sel.Table, *gb.Func)
+			} else {
+				c.colWithTable(sel.Table, gb.Col.Name)
+			}
+		}
+		return
+	}
+
+	i := 0
+	for _, col := range sel.BCols {
 		if i != 0 {
 			c.w.WriteString(`, `)
 		}
 		c.colWithTable(sel.Table, col.Col.Name)
+		i++
 	}
+
+	// time buckets are grouped on the expression since
+	// not all databases allow grouping on an alias
+	for _, fn := range sel.Funcs {
+		if fn.Name != "date_trunc" {
+			continue
+		}
+		if i != 0 {
+			c.w.WriteString(`, `)
+		}
+		c.renderFuncCall(sel.Table, fn)
+		i++
+	}
+}
+
+func (c *compilerContext) renderHaving(sel *qcode.Select) {
+	if sel.Having.Exp == nil {
+		return
+	}
+	c.w.WriteString(` HAVING `)
+	c.renderExp(sel.Ti, sel.Having.Exp, false)
 }
 
 func (c *compilerContext) renderRecursiveGroupBy(sel *qcode.Select) {
@@ -580,6 +905,17 @@ func (c *compilerContext) renderOrderBy(sel *qcode.Select) {
 		}
 		c.colWithTable(col.Col.Table, col.Col.Name)
 
+		// sql server has no support for 'nulls first' or 'nulls last'
+		if c.ct == "mssql" {
+			switch col.Order {
+			case qcode.OrderDesc, qcode.OrderDescNullsFirst, qcode.OrderDescNullsLast:
+				c.w.WriteString(` DESC`)
+			default:
+				c.w.WriteString(` ASC`)
+			}
+			continue
+		}
+
 		switch col.Order {
 		case qcode.OrderAsc:
 			c.w.WriteString(` ASC`)
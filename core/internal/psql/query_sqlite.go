@@ -0,0 +1,40 @@
+//nolint:errcheck
+package psql
+
+import (
+	"github.com/dosco/graphjin/core/internal/qcode"
+)
+
+// SQLite has no lateral joins so unlike the other dialects each child
+// selector is rendered inline as a correlated subquery of its parent.
+
+func (c *compilerContext) renderSQLiteRoot() {
+	c.w.WriteString(`SELECT json_object(`)
+
+	i := 0
+	for _, id := range c.qc.Roots {
+		if i != 0 {
+			c.w.WriteString(`, `)
+		}
+		sel := &c.qc.Selects[id]
+		c.squoted(sel.FieldName)
+
+		if sel.SkipRender == qcode.SkipTypeUserNeeded {
+			c.w.WriteString(`, NULL`)
+		} else {
+			c.w.WriteString(`, json(`)
+			c.renderSQLiteSelect(sel)
+			c.w.WriteString(`)`)
+		}
+		i++
+	}
+	c.w.WriteString(`) AS __root`)
+}
+
+func (c *compilerContext) renderSQLiteSelect(sel *qcode.Select) {
+	c.w.WriteString(`(`)
+	c.renderPluralSelect(sel)
+	c.renderSelect(sel)
+	c.renderSelectClose(sel)
+	c.w.WriteString(`)`)
+}
@@ -3,7 +3,13 @@ package psql_test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+
+	"github.com/dosco/graphjin/core/internal/psql"
+	"github.com/dosco/graphjin/core/internal/qcode"
+	"github.com/dosco/graphjin/core/internal/sdata"
 )
 
 func simpleQuery(t *testing.T) {
@@ -313,6 +319,157 @@ func aggFunctionWithFilter(t *testing.T) {
 	compileGQLToPSQL(t, gql, nil, "user")
 }
 
+func aggFunctionWithGroupByAndHaving(t *testing.T) {
+	gql := `query {
+		products(group_by: [user_id], having: { count_id: { gt: 5 } }) {
+			user_id
+			count_id
+		}
+	}`
+
+	sql, err := compileGQLForDialect(t, "postgres", gql, nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := `GROUP BY "products".user_id HAVING ((count("products".id)) > 5)`
+	if !strings.Contains(sql, v) {
+		t.Errorf("expected '%s' in: %s", v, sql)
+	}
+}
+
+func aggFunctionWithDateTrunc(t *testing.T) {
+	gql := `query {
+		products {
+			date_trunc_month_created_at
+			sum_price
+		}
+	}`
+
+	sql, err := compileGQLForDialect(t, "postgres", gql, nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{
+		`date_trunc('month', "products".created_at) AS "date_trunc_month_created_at"`,
+		`GROUP BY date_trunc('month', "products".created_at)`,
+	} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+}
+
+func aggFunctionGroupByMissingColumn(t *testing.T) {
+	gql := `query {
+		products(group_by: user_id) {
+			id
+			user_id
+			count_id
+		}
+	}`
+
+	compileGQLToPSQLExpectErr(t, gql, nil, "user")
+}
+
+func aggFunctionGroupByBlocked(t *testing.T) {
+	tests := []struct {
+		gql  string
+		role string
+	}{
+		{`query { products(group_by: [user_id]) { count_id } }`, "user"},
+		{`query { products(group_by: [date_trunc_month_created_at]) { count_id } }`, "user"},
+		{`query { products(group_by: [name], having: { max_user_id: { gt: 5 } }) { name } }`, "user"},
+		{`query { products(group_by: [name], having: { count_id: { gt: 5 } }) { name } }`, "anon1"},
+	}
+
+	for _, v := range tests {
+		var perr *qcode.PermissionError
+		_, err := qcompile.Compile([]byte(v.gql), nil, v.role)
+		if !errors.As(err, &perr) {
+			t.Errorf("expected a permission error: %s: %v", v.gql, err)
+		}
+	}
+
+	gql := `query { products(group_by: [name], having: { max_price: { gt: 5 } }) { name } }`
+	if _, err := qcompile.Compile([]byte(gql), nil, "user"); err != nil {
+		t.Error(err)
+	}
+}
+
+func connectionWithPageInfo(t *testing.T) {
+	gql := `query {
+		products_connection(first: 10, after: $cursor, where: { price: { gt: 10 } }) {
+			totalCount
+			pageInfo {
+				hasNextPage
+				hasPreviousPage
+				endCursor
+			}
+			edges {
+				cursor
+				node {
+					id
+					name
+				}
+			}
+		}
+	}`
+
+	sql, err := compileGQLForDialect(t, "postgres", gql, nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{
+		`'totalCount', (SELECT count(*) FROM "products" WHERE (("products".price) > '10'))`,
+		`'hasNextPage', COALESCE(max(__sj_0.__rest) > count(*), false)`,
+		`'hasPreviousPage', COALESCE($1 :: text IS NOT NULL, false)`,
+		`'__gj_cur_endCursor', (jsonb_agg(__sj_0.__cursor) -> -1)`,
+		`jsonb_agg(jsonb_build_object('__gj_cur_cursor', __sj_0.__cursor, 'node', __sj_0.json))`,
+		`count(*) OVER() AS __rest`,
+	} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+
+	if strings.Contains(sql, `products_connection_cursor`) {
+		t.Errorf("unexpected cursor field in: %s", sql)
+	}
+
+	if _, err := compileGQLForDialect(t, "mysql", gql, nil, "user"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func connectionNested(t *testing.T) {
+	gql := `query {
+		users {
+			id
+			products_connection(first: 5) {
+				totalCount
+				edges {
+					node {
+						id
+					}
+				}
+			}
+		}
+	}`
+
+	sql, err := compileGQLForDialect(t, "postgres", gql, nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := `(SELECT count(*) FROM "products" WHERE (("products".user_id) = (users_0.id)))`
+	if !strings.Contains(sql, v) {
+		t.Errorf("expected '%s' in: %s", v, sql)
+	}
+}
+
 func syntheticTables(t *testing.T) {
 	gql := `query {
 		me {
@@ -661,7 +818,187 @@ func blockedFunctions(t *testing.T) {
 	compileGQLToPSQLExpectErr(t, gql, nil, "bad_dude")
 }
 
+func withSetFunction(t *testing.T) {
+	gql := `query {
+		search_products(args: { query: $query }, where: { name: { ilike: "%x%" } }, limit: 5) {
+			id
+			name
+			user {
+				id
+			}
+		}
+	}`
+
+	qc, err := qcompile.Compile([]byte(gql), nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sql, err := pcompile.CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the role filters of the table apply to the function
+	exp := []string{
+		`FROM "search_products"("query" => $1 :: text) AS "products" WHERE`,
+		`(("products".price) > '0')`,
+		`FROM "users" WHERE (("users".id) = (products_0.user_id))`,
+	}
+	for _, v := range exp {
+		if !strings.Contains(string(sql), v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+
+	gql = `query {
+		product_stats(args: { min_price: 10 }, order_by: { total: desc }) {
+			name
+			total
+		}
+	}`
+
+	sql1, err := compileGQLForDialect(t, "postgres", gql, nil, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp1 := `FROM "product_stats"("min_price" => 10 :: numeric) AS "product_stats"`
+	if !strings.Contains(sql1, exp1) {
+		t.Errorf("expected '%s' in: %s", exp1, sql1)
+	}
+
+	gql = `query {
+		users {
+			id
+			search_products(args: { query: "x" }) {
+				id
+			}
+		}
+	}`
+
+	if _, err := compileGQLForDialect(t, "postgres", gql, nil, "admin"); err == nil {
+		t.Error("expected an error for a nested function")
+	}
+
+	gql = `query {
+		search_products(args: { text: "x" }) {
+			id
+		}
+	}`
+
+	if _, err := compileGQLForDialect(t, "postgres", gql, nil, "admin"); err == nil {
+		t.Error("expected an error for an unknown argument")
+	}
+}
+
+func columnMasks(t *testing.T) {
+	schema, err := sdata.GetTestSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qcc, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = qcc.AddRole("support", "public", "users", qcode.TRConfig{
+		Query: qcode.QueryConfig{
+			Masks: map[string]string{
+				"email":     "partial_email",
+				"phone":     "last4",
+				"full_name": "null",
+				"avatar":    "sql:md5(avatar)",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query {
+		users {
+			id
+			email
+			phone
+			full_name
+			avatar
+		}
+	}`
+
+	qc, err := qcc.Compile([]byte(gql), nil, "support")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sql, err := psql.NewCompiler(psql.Config{}).CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		`users_0.id AS "id"`,
+		`(CASE WHEN users_0.email IS NOT NULL THEN left(users_0.email, 1) || '***@' || split_part(users_0.email, '@', 2) END) AS "email"`,
+		`(CASE WHEN users_0.phone IS NOT NULL THEN '***' || right(users_0.phone :: text, 4) END) AS "phone"`,
+		`NULL AS "full_name"`,
+		`(md5(avatar)) AS "avatar"`,
+	}
+	for _, v := range exp {
+		if !strings.Contains(string(sql), v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+
+	// other roles get the values
+	qc, err = qcc.Compile([]byte(gql), nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sql, err = psql.NewCompiler(psql.Config{}).CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(sql), `users_0.email AS "email"`) {
+		t.Errorf("expected the email unmasked: %s", sql)
+	}
+
+	// masked columns cannot be used to filter or sort
+	for _, gql := range []string{
+		`query { users(where: { email: { eq: "a@b.com" } }) { id } }`,
+		`query { users(where: { or: { id: 1, phone: { like: "%1234" } } }) { id } }`,
+		`query { users(order_by: { full_name: asc }) { id } }`,
+		`query { users(distinct_on: [email]) { id } }`,
+		`query { users(group_by: [email]) { count_id } }`,
+		`query { users(group_by: [id], having: { max_phone: { gt: "5" } }) { id } }`,
+	} {
+		var perr *qcode.PermissionError
+		_, err := qcc.Compile([]byte(gql), nil, "support")
+		if !errors.As(err, &perr) {
+			t.Errorf("expected a permission error: %s: %v", gql, err)
+		}
+	}
+
+	if _, err := qcc.Compile([]byte(`query { users(where: { id: 1 }, order_by: { id: asc }) { id } }`),
+		nil, "support"); err != nil {
+		t.Error(err)
+	}
+
+	err = qcc.AddRole("support", "public", "products", qcode.TRConfig{
+		Query: qcode.QueryConfig{
+			Masks: map[string]string{"name": "hidden"},
+		},
+	})
+	if err == nil {
+		t.Error("expected an error for an invalid mask")
+	}
+}
+
 func TestCompileQuery(t *testing.T) {
+	t.Run("withSetFunction", withSetFunction)
+	t.Run("columnMasks", columnMasks)
 	t.Run("simpleQuery", simpleQuery)
 	t.Run("withVariableLimit", withVariableLimit)
 	t.Run("withComplexArgs", withComplexArgs)
@@ -683,6 +1020,12 @@ func TestCompileQuery(t *testing.T) {
 	t.Run("aggFunctionBlockedByCol", aggFunctionBlockedByCol)
 	t.Run("aggFunctionDisabled", aggFunctionDisabled)
 	t.Run("aggFunctionWithFilter", aggFunctionWithFilter)
+	t.Run("aggFunctionWithGroupByAndHaving", aggFunctionWithGroupByAndHaving)
+	t.Run("aggFunctionWithDateTrunc", aggFunctionWithDateTrunc)
+	t.Run("aggFunctionGroupByMissingColumn", aggFunctionGroupByMissingColumn)
+	t.Run("aggFunctionGroupByBlocked", aggFunctionGroupByBlocked)
+	t.Run("connectionWithPageInfo", connectionWithPageInfo)
+	t.Run("connectionNested", connectionNested)
 	t.Run("syntheticTables", syntheticTables)
 	t.Run("queryWithVariables", queryWithVariables)
 	t.Run("withWhereOnRelations", withWhereOnRelations)
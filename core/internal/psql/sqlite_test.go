@@ -0,0 +1,91 @@
+package psql_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func sqliteSimpleQuery(t *testing.T) {
+	gql := `query {
+		products(limit: 10, order_by: { price: desc }) {
+			id
+			name
+			user {
+				id
+				email
+			}
+		}
+	}`
+
+	sql, err := compileGQLForDialect(t, "sqlite", gql, nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{
+		`json_group_array(json(__sj_0.json))`,
+		`json_object('id', __sr_0."id"`,
+		`'user', json(__sr_0."user")`,
+		`(("users".id) = (products_0.user_id))`,
+		`"products".price DESC LIMIT 10`,
+	} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+
+	for _, v := range []string{`LATERAL`, `jsonb`, `__sj_1.json`} {
+		if strings.Contains(sql, v) {
+			t.Errorf("unexpected '%s' in: %s", v, sql)
+		}
+	}
+}
+
+func sqliteWithVariables(t *testing.T) {
+	gql := `query {
+		products(limit: $limit, offset: $offset, where: { id: { in: $ids }, name: { ilike: $name } }) {
+			id
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"limit":  json.RawMessage(`10`),
+		"offset": json.RawMessage(`20`),
+		"ids":    json.RawMessage(`[1,2,3]`),
+		"name":   json.RawMessage(`"test"`),
+	}
+
+	sql, err := compileGQLForDialect(t, "sqlite", gql, vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{`?1`, `?4`, `json_each(`, `LIMIT MIN(`, ` LIKE `} {
+		if !strings.Contains(sql, v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+
+	if strings.Contains(sql, `ILIKE`) {
+		t.Errorf("unexpected 'ILIKE' in: %s", sql)
+	}
+}
+
+func sqliteCursorNotSupported(t *testing.T) {
+	gql := `query {
+		products(first: 10, after: $cursor) {
+			id
+		}
+	}`
+
+	if _, err := compileGQLForDialect(t, "sqlite", gql, nil, "user"); err == nil {
+		t.Error("we were expecting an error")
+	}
+}
+
+func TestCompileSQLite(t *testing.T) {
+	t.Run("sqliteSimpleQuery", sqliteSimpleQuery)
+	t.Run("sqliteWithVariables", sqliteWithVariables)
+	t.Run("sqliteCursorNotSupported", sqliteCursorNotSupported)
+}
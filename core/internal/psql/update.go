@@ -3,6 +3,9 @@
 package psql
 
 import (
+	"strings"
+
+	"github.com/dosco/graphjin/core/internal/graph"
 	"github.com/dosco/graphjin/core/internal/qcode"
 	"github.com/dosco/graphjin/core/internal/sdata"
 )
@@ -36,18 +39,39 @@ func (c *compilerContext) renderUpdateStmt(m qcode.Mutate) {
 	c.w.WriteString(`UPDATE `)
 	c.quoted(m.Ti.Name)
 
-	c.w.WriteString(` SET (`)
-	n := c.renderInsertUpdateColumns(m, false)
-	c.renderNestedRelColumns(m, false, false, n)
+	c.w.WriteString(` SET `)
+
+	// the version column is not in the columns of the data,
+	// it might be the only column changed
+	if len(m.Cols) != 0 || len(m.RCols) != 0 {
+		c.w.WriteString(`(`)
+		n := c.renderInsertUpdateColumns(m, false)
+		c.renderNestedRelColumns(m, false, false, n)
+
+		c.w.WriteString(`) = (`)
+		c.renderValues(m, true)
+		c.w.WriteString(`)`)
+		// inner select ended
 
-	c.w.WriteString(`) = (`)
-	c.renderValues(m, true)
-	c.w.WriteString(`)`)
-	// inner select ended
+		if m.Version.Col.Name != "" {
+			c.w.WriteString(`, `)
+		}
+	}
+
+	if m.Version.Col.Name != "" {
+		c.renderVersionSet(m)
+	}
 
 	if m.ParentID == -1 {
 		c.w.WriteString(` WHERE `)
 		c.renderExp(m.Ti, sel.Where.Exp, false)
+
+		if m.Version.Col.Name != "" {
+			c.w.WriteString(` AND `)
+			c.colWithTable(m.Ti.Name, m.Version.Col.Name)
+			c.w.WriteString(` = `)
+			c.renderVersionValue(m)
+		}
 	} else {
 		// Render sql to set id values if child-to-parent
 		// relationship is one-to-one
@@ -87,3 +111,49 @@ func (c *compilerContext) renderUpdateStmt(m qcode.Mutate) {
 	c.quoted(m.Ti.Name)
 	c.w.WriteString(`.*)`)
 }
+
+// renderVersionSet bumps the version column, timestamps
+// are set to the current time and numbers are incremented
+func (c *compilerContext) renderVersionSet(m qcode.Mutate) {
+	col := m.Version.Col
+
+	c.quoted(col.Name)
+	c.w.WriteString(` = `)
+
+	if strings.Contains(col.Type, "time") {
+		c.w.WriteString(`CURRENT_TIMESTAMP`)
+	} else {
+		c.colWithTable(m.Ti.Name, col.Name)
+		c.w.WriteString(` + 1`)
+	}
+}
+
+// renderVersionValue renders the version sent by the client
+func (c *compilerContext) renderVersionValue(m qcode.Mutate) {
+	col := m.Version
+
+	if m.IsJSON {
+		c.w.WriteString(`(SELECT `)
+		c.colWithTable("t", col.FieldName)
+		c.w.WriteString(` FROM _sg_input i, json_populate_record(NULL::"`)
+		c.w.WriteString(m.Ti.Name)
+		joinPath(c.w, `", i.j`, m.Path)
+		c.w.WriteString(`) t)`)
+		return
+	}
+
+	field := m.Data.CMap[col.FieldName]
+	v := field.Val
+
+	if field.Type == graph.NodeVar {
+		if v1, ok := c.svars[v]; ok {
+			v = v1
+		}
+		c.renderParam(Param{Name: v, Type: col.Col.Type})
+	} else {
+		c.squoted(v)
+	}
+
+	c.w.WriteString(` :: `)
+	c.w.WriteString(col.Col.Type)
+}
@@ -2,7 +2,12 @@ package psql_test
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+
+	"github.com/dosco/graphjin/core/internal/psql"
+	"github.com/dosco/graphjin/core/internal/qcode"
+	"github.com/dosco/graphjin/core/internal/sdata"
 )
 
 func singleUpdate(t *testing.T) {
@@ -265,6 +270,64 @@ func nestedUpdateRecursive(t *testing.T) {
 	compileGQLToPSQL(t, gql, vars, "user")
 }
 
+func updateWithVersion(t *testing.T) {
+	schema, err := sdata.GetTestSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qcc, err := qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig: map[string]qcode.TConfig{
+			"publicproducts": {VersionColumn: "updated_at"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pcc := psql.NewCompiler(psql.Config{})
+
+	gql := `mutation {
+		products(id: $id, update: $data) {
+			id
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`{"name": "my_name", "updated_at": "2021-01-01 00:00:00"}`),
+	}
+
+	qc, err := qcc.Compile([]byte(gql), vars, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sql, err := pcc.CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		`UPDATE "products" SET ("name") = ( SELECT "t".name :: character varying FROM _sg_input i, json_populate_record(NULL::"products", i.j) t), "updated_at" = CURRENT_TIMESTAMP WHERE `,
+		` AND "products".updated_at = (SELECT "t".updated_at FROM _sg_input i, json_populate_record(NULL::"products", i.j) t) RETURNING`,
+		`(SELECT CASE WHEN count(*) <= (SELECT count(*) FROM "products") THEN true ELSE CAST(('"version conflict on products: ' || (count(*) - (SELECT count(*) FROM "products")) || ' row(s)"') AS regnamespace) IS NULL END FROM "public"."products" WHERE (("products".id) = $2))`,
+	}
+	for _, v := range exp {
+		if !strings.Contains(string(sql), v) {
+			t.Errorf("expected '%s' in: %s", v, sql)
+		}
+	}
+
+	vars = map[string]json.RawMessage{
+		"data": json.RawMessage(`{"name": "my_name"}`),
+	}
+
+	if _, err := qcc.Compile([]byte(gql), vars, "admin"); err == nil {
+		t.Error("expected an error when the version is missing")
+	}
+}
+
 func TestCompileUpdate(t *testing.T) {
 	t.Run("singleUpdate", singleUpdate)
 	t.Run("simpleUpdateWithPresets", simpleUpdateWithPresets)
@@ -276,5 +339,6 @@ func TestCompileUpdate(t *testing.T) {
 	t.Run("nestedUpdateOneToOneWithDisconnect", nestedUpdateOneToOneWithDisconnect)
 	t.Run("nestedUpdateOneToOneWithDisconnectArray", nestedUpdateOneToOneWithDisconnectArray)
 	t.Run("nestedUpdateRecursive", nestedUpdateRecursive)
+	t.Run("updateWithVersion", updateWithVersion)
 
 }
@@ -39,6 +39,10 @@ func (c *compilerContext) quoted(identifier string) {
 		c.w.WriteByte('`')
 		c.w.WriteString(identifier)
 		c.w.WriteByte('`')
+	case "mssql":
+		c.w.WriteByte('[')
+		c.w.WriteString(identifier)
+		c.w.WriteByte(']')
 	default:
 		c.w.WriteByte('"')
 		c.w.WriteString(identifier)
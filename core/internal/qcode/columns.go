@@ -28,6 +28,8 @@ func (co *Compiler) compileColumns(
 		return err
 	}
 
+	setMasks(sel, tr)
+
 	if err := co.addColumns(qc, sel); err != nil {
 		return err
 	}
@@ -45,6 +47,7 @@ func (co *Compiler) compileChildColumns(
 	tr trval) error {
 
 	aggExist := false
+	bucketExist := false
 
 	for _, cid := range field.Children {
 		var fname string
@@ -93,7 +96,7 @@ func (co *Compiler) compileChildColumns(
 				return err
 			}
 			if dbc.Blocked {
-				return fmt.Errorf("column: '%s.%s.%s' blocked",
+				return permError("column: '%s.%s.%s' blocked",
 					dbc.Schema, dbc.Table, dbc.Name)
 			}
 			// is a function
@@ -101,11 +104,14 @@ func (co *Compiler) compileChildColumns(
 			if agg {
 				aggExist = true
 			}
+			if fn.Name == "date_trunc" {
+				bucketExist = true
+			}
 			sel.addFunc(fn)
 		}
 	}
 
-	if aggExist && len(sel.Cols) != 0 {
+	if aggExist && (len(sel.Cols) != 0 || bucketExist) {
 		sel.GroupCols = true
 	}
 
@@ -202,15 +208,64 @@ func (co *Compiler) orderByIDCol(sel *Select) error {
 func validateSelector(qc *QCode, sel *Select, tr trval) error {
 	for _, col := range sel.Cols {
 		if !tr.columnAllowed(qc, col.Col.Name) {
-			return fmt.Errorf("column blocked: %s (%s)", col.Col.Name, tr.role)
+			return permError("column blocked: %s (%s)", col.Col.Name, tr.role)
 		}
 	}
 
-	if len(sel.Funcs) != 0 && tr.isFuncsBlocked() {
-		return fmt.Errorf("functions blocked: %s (%s)", sel.Funcs[0].Col.Name, tr.role)
+	if err := validateFuncs(qc, sel.Funcs, tr); err != nil {
+		return err
 	}
 
 	for _, fn := range sel.Funcs {
+		// functions would return values computed from the unmasked column
+		if _, ok := tr.query.masks[fn.Col.Name]; ok && fn.Col.Name != "" {
+			return permError("column masked: %s (%s)", fn.Name, tr.role)
+		}
+	}
+	return nil
+}
+
+// validateGroupArgs checks the columns and functions used in the group_by
+// and having arguments like the selected ones, grouping or filtering on
+// them would reveal the values of columns the role cannot read.
+func validateGroupArgs(qc *QCode, sel *Select, tr trval) error {
+	var funcs []Function
+
+	for _, gb := range sel.GroupBy {
+		if gb.Func != nil {
+			funcs = append(funcs, *gb.Func)
+			continue
+		}
+		if !tr.columnAllowed(qc, gb.Col.Name) {
+			return permError("column blocked: %s (%s)", gb.Col.Name, tr.role)
+		}
+	}
+
+	st := util.NewStackInf()
+	if sel.Having.Exp != nil {
+		st.Push(sel.Having.Exp)
+	}
+
+	for st.Len() != 0 {
+		ex := st.Pop().(*Exp)
+
+		if ex.Left.Func != nil {
+			funcs = append(funcs, *ex.Left.Func)
+		}
+		for _, cex := range ex.Children {
+			st.Push(cex)
+		}
+	}
+
+	return validateFuncs(qc, funcs, tr)
+}
+
+func validateFuncs(qc *QCode, funcs []Function, tr trval) error {
+	if len(funcs) != 0 && tr.isFuncsBlocked() {
+		return permError("functions blocked: %s (%s)", funcs[0].Col.Name, tr.role)
+	}
+
+	for _, fn := range funcs {
 		var blocked bool
 
 		if fn.Col.Name != "" {
@@ -220,12 +275,92 @@ func validateSelector(qc *QCode, sel *Select, tr trval) error {
 		}
 
 		if blocked {
-			return fmt.Errorf("column blocked: %s (%s)", fn.Name, tr.role)
+			return permError("column blocked: %s (%s)", fn.Name, tr.role)
+		}
+	}
+	return nil
+}
+
+// validateMaskedArgs rejects the columns masked for the role in the where,
+// search, order_by, distinct_on, group_by and having arguments, filtering,
+// sorting or grouping on them would reveal their values. Columns of related tables are checked against
+// the role's masks for those tables.
+func (co *Compiler) validateMaskedArgs(sel *Select, tr trval) error {
+	masked := func(col sdata.DBColumn) bool {
+		masks := tr.query.masks
+		if col.Table != sel.Ti.Name || col.Schema != sel.Ti.Schema {
+			masks = co.getRole(tr.role, col.Schema, col.Table, "").query.masks
+		}
+		_, ok := masks[col.Name]
+		return ok
+	}
+
+	st := util.NewStackInf()
+	if sel.Where.Exp != nil {
+		st.Push(sel.Where.Exp)
+	}
+	if sel.Having.Exp != nil {
+		st.Push(sel.Having.Exp)
+	}
+
+	for st.Len() != 0 {
+		ex := st.Pop().(*Exp)
+
+		if ex.Op == OpTsQuery {
+			for _, col := range sel.Ti.FullText {
+				if masked(col) {
+					return permError("column masked: %s (%s)", col.Name, tr.role)
+				}
+			}
+		}
+
+		for _, col := range []sdata.DBColumn{ex.Left.Col, ex.Right.Col} {
+			if col.Name != "" && masked(col) {
+				return permError("column masked: %s (%s)", col.Name, tr.role)
+			}
+		}
+
+		for _, cex := range ex.Children {
+			st.Push(cex)
+		}
+	}
+
+	for _, ob := range sel.OrderBy {
+		if masked(ob.Col) {
+			return permError("column masked: %s (%s)", ob.Col.Name, tr.role)
+		}
+	}
+
+	for _, col := range sel.DistinctOn {
+		if masked(col) {
+			return permError("column masked: %s (%s)", col.Name, tr.role)
+		}
+	}
+
+	for _, gb := range sel.GroupBy {
+		col := gb.Col
+		if gb.Func != nil {
+			col = gb.Func.Col
+		}
+		if masked(col) {
+			return permError("column masked: %s (%s)", col.Name, tr.role)
 		}
 	}
 	return nil
 }
 
+// setMasks sets the masks of the role on the columns returned
+func setMasks(sel *Select, tr trval) {
+	if len(tr.query.masks) == 0 {
+		return
+	}
+	for i, col := range sel.Cols {
+		if m, ok := tr.query.masks[col.Col.Name]; ok {
+			sel.Cols[i].Mask = m
+		}
+	}
+}
+
 func (sel *Select) addCol(col Column, baseOnly bool) {
 	if sel.bcolExists(col.Col.Name) == -1 {
 		sel.BCols = append(sel.BCols, col)
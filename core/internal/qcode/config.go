@@ -1,8 +1,10 @@
 package qcode
 
 import (
+	"fmt"
 	"strings"
 
+	"github.com/dosco/graphjin/core/internal/sdata"
 	"github.com/gobuffalo/flect"
 )
 
@@ -17,11 +19,21 @@ type Config struct {
 	EnableCamelcase  bool
 	EnableInflection bool
 	DBSchema         string
+	MaxDepth         int
+	MaxRootFields    int
+	MaxSelectors     int
+	MaxCost          int
+	RoleMaxCost      map[string]int
 	defTrv           trval
 }
 
 type TConfig struct {
 	OrderBy map[string][][2]string
+	Cost    int
+	// SoftDelete is the column set when a row is soft deleted
+	SoftDelete string
+	// VersionColumn is checked and bumped by updates and upserts
+	VersionColumn string
 }
 
 type TRConfig struct {
@@ -38,9 +50,12 @@ type QueryConfig struct {
 	Columns          []string
 	DisableFunctions bool
 	Block            bool
+	WithDeleted      bool
+	Masks            map[string]string
 }
 
 type InsertConfig struct {
+	Filters []string
 	Columns []string
 	Presets map[string]string
 	Block   bool
@@ -76,9 +91,13 @@ type trval struct {
 		cols    map[string]struct{}
 		disable struct{ funcs bool }
 		block   bool
+		deleted bool
+		masks   map[string]string
 	}
 
 	insert struct {
+		fil     *Exp
+		filNU   bool
 		cols    map[string]struct{}
 		presets map[string]string
 		block   bool
@@ -130,8 +149,17 @@ func (co *Compiler) AddRole(role, schema, table string, trc TRConfig) error {
 	trv.query.cols = makeSet(trc.Query.Columns)
 	trv.query.disable.funcs = trc.Query.DisableFunctions
 	trv.query.block = trc.Query.Block
+	trv.query.deleted = trc.Query.WithDeleted
+
+	if trv.query.masks, err = compileMasks(ti, trc.Query.Masks); err != nil {
+		return err
+	}
 
 	// insert config
+	trv.insert.fil, trv.insert.filNU, err = compileFilter(co.s, ti, trc.Insert.Filters, false)
+	if err != nil {
+		return err
+	}
 	trv.insert.cols = makeSet(trc.Insert.Columns)
 	trv.insert.presets = trc.Insert.Presets
 	trv.insert.block = trc.Insert.Block
@@ -146,7 +174,7 @@ func (co *Compiler) AddRole(role, schema, table string, trc TRConfig) error {
 	trv.update.block = trc.Update.Block
 
 	// upsert config
-	trv.upsert.fil, trv.update.filNU, err = compileFilter(co.s, ti, trc.Upsert.Filters, false)
+	trv.upsert.fil, trv.upsert.filNU, err = compileFilter(co.s, ti, trc.Upsert.Filters, false)
 	if err != nil {
 		return err
 	}
@@ -178,6 +206,32 @@ func (co *Compiler) AddRole(role, schema, table string, trc TRConfig) error {
 	return nil
 }
 
+// compileMasks checks the masks are on columns of the table
+func compileMasks(ti sdata.DBTable, masks map[string]string) (map[string]string, error) {
+	for col, m := range masks {
+		if _, err := ti.GetColumn(col); err != nil {
+			return nil, fmt.Errorf("masks: %w", err)
+		}
+		switch {
+		case m == MaskNull, m == MaskPartialEmail, m == MaskLast4:
+		case strings.HasPrefix(m, "sql:"):
+		default:
+			return nil, fmt.Errorf("masks: invalid mask '%s' for column '%s'", m, col)
+		}
+	}
+	return masks, nil
+}
+
+// AddRoleFunction allows the role to call a function that changes data
+func (co *Compiler) AddRoleFunction(role, name string) error {
+	f, ok := co.s.GetSetFunction(name)
+	if !ok || !f.Mutation {
+		return fmt.Errorf("function not found: %s", name)
+	}
+	co.rf[(role + ":" + f.Name)] = struct{}{}
+	return nil
+}
+
 func (co *Compiler) getRole(role, schema, table, field string) trval {
 	var k string
 
@@ -197,6 +251,14 @@ func (co *Compiler) getRole(role, schema, table, field string) trval {
 	return tr
 }
 
+// maxCost returns the cost budget for a role, zero means no limit
+func (co *Compiler) maxCost(role string) int {
+	if v, ok := co.c.RoleMaxCost[role]; ok {
+		return v
+	}
+	return co.c.MaxCost
+}
+
 func (co *Compiler) getTConfig(schema, name string) TConfig {
 	return co.c.TConfig[(schema + name)]
 }
@@ -217,6 +279,18 @@ func (trv *trval) filter(qt QType) (*Exp, bool) {
 	return nil, false
 }
 
+// check returns the filter that rows written by an insert or the insert
+// branch of an upsert must pass
+func (trv *trval) check(mt MType) (*Exp, bool) {
+	switch mt {
+	case MTInsert:
+		return trv.insert.fil, trv.insert.filNU
+	case MTUpsert:
+		return trv.upsert.fil, trv.upsert.filNU
+	}
+	return nil, false
+}
+
 func (trv *trval) columnAllowed(qt *QCode, name string) bool {
 	switch qt.SType {
 	case QTQuery:
@@ -290,7 +364,8 @@ func (trv *trval) getPresets(mt MType) map[string]string {
 		return trv.insert.presets
 	case MTUpdate:
 		return trv.update.presets
-
+	case MTUpsert:
+		return trv.upsert.presets
 	}
 	return nil
 }
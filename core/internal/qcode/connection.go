@@ -0,0 +1,131 @@
+package qcode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dosco/graphjin/core/internal/graph"
+)
+
+// connSuffix is added to a table name to query it as a relay style
+// connection, eg. products_connection (or productsConnection)
+const connSuffix = "_connection"
+
+// Connection is set on selectors that return the relay connection
+// shape of edges, pageInfo and totalCount instead of a list of rows
+type Connection struct {
+	Fields []ConnField
+	// Where is the filter of the selector without the cursor
+	// predicate, it's used to count all the rows for totalCount
+	Where Filter
+}
+
+// ConnField is a field of the connection, of it's pageInfo or of
+// it's edges. Name is one of the relay field names (eg. totalCount)
+// and FieldName is the name (or alias) used in the result
+type ConnField struct {
+	Name      string
+	FieldName string
+	Fields    []ConnField
+}
+
+// Has returns true if the field name was requested
+// at any level of the connection
+func (c *Connection) Has(name string) bool {
+	return hasConnField(c.Fields, name)
+}
+
+func hasConnField(fields []ConnField, name string) bool {
+	for _, f := range fields {
+		if f.Name == name || hasConnField(f.Fields, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileConnection checks if the field is a connection and if it is
+// then the fields of the edge node become the fields of the selector.
+func (co *Compiler) compileConnection(op *graph.Operation, sel *Select, field *graph.Field) error {
+	if !strings.HasSuffix(field.Name, connSuffix) {
+		return nil
+	}
+
+	// a table named with the suffix takes precedence
+	if _, err := co.s.Find(co.c.DBSchema, field.Name); err == nil {
+		return nil
+	}
+
+	conn := &Connection{}
+	var node *graph.Field
+
+	for _, cid := range field.Children {
+		f := op.Fields[cid]
+		cf := newConnField(f)
+
+		switch f.Name {
+		case "totalCount", "__typename":
+
+		case "pageInfo":
+			for _, id := range f.Children {
+				f1 := op.Fields[id]
+
+				switch f1.Name {
+				case "hasNextPage", "hasPreviousPage", "startCursor", "endCursor", "__typename":
+					cf.Fields = append(cf.Fields, newConnField(f1))
+				default:
+					return fmt.Errorf("pageInfo: unknown field: %s", f1.Name)
+				}
+			}
+
+		case "edges":
+			for _, id := range f.Children {
+				f1 := op.Fields[id]
+
+				switch f1.Name {
+				case "cursor", "__typename":
+				case "node":
+					if node != nil {
+						return fmt.Errorf("edges: only one node field is allowed")
+					}
+					node = &op.Fields[id]
+				default:
+					return fmt.Errorf("edges: unknown field: %s", f1.Name)
+				}
+				cf.Fields = append(cf.Fields, newConnField(f1))
+			}
+
+		default:
+			return fmt.Errorf("connection: unknown field: %s", f.Name)
+		}
+		conn.Fields = append(conn.Fields, cf)
+	}
+
+	if co.s.DBType() == "mssql" || co.s.DBType() == "sqlite" {
+		return fmt.Errorf("%s: connections are not supported", co.s.DBType())
+	}
+
+	name := strings.TrimSuffix(field.Name, connSuffix)
+	field.Name = name
+	op.Fields[field.ID].Name = name
+
+	// the fields of the node are moved up to the connection
+	field.Children = nil
+	if node != nil {
+		field.Children = node.Children
+		for _, id := range node.Children {
+			op.Fields[id].ParentID = field.ID
+		}
+	}
+
+	sel.Connection = conn
+	return nil
+}
+
+func newConnField(f graph.Field) ConnField {
+	cf := ConnField{Name: f.Name, FieldName: f.Name}
+	if f.Alias != "" {
+		cf.FieldName = f.Alias
+	}
+	return cf
+}
@@ -20,3 +20,44 @@ func graphError(err error, from, to, through string) error {
 		return err
 	}
 }
+
+// Error is returned when a selector fails to compile, Path holds
+// the field names from the query root down to the selector.
+type Error struct {
+	Path []string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func selectorError(qc *QCode, sel *Select, err error) error {
+	path := []string{sel.FieldName}
+	for id := sel.ParentID; id != -1; id = qc.Selects[id].ParentID {
+		path = append([]string{qc.Selects[id].FieldName}, path...)
+	}
+	return &Error{Path: path, Err: err}
+}
+
+// PermissionError is returned when the role is not allowed to use
+// a table, column or function in the way the query does.
+type PermissionError struct {
+	Err error
+}
+
+func (e *PermissionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+func permError(format string, a ...interface{}) error {
+	return &PermissionError{Err: fmt.Errorf(format, a...)}
+}
@@ -3,6 +3,7 @@ package qcode
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/dosco/graphjin/core/internal/graph"
 	"github.com/dosco/graphjin/core/internal/sdata"
@@ -24,6 +25,9 @@ type aexpst struct {
 	ti       sdata.DBTable
 	edge     string
 	savePath bool
+	// hsel is set when compiling a having argument, the names of
+	// aggregate functions on the table are allowed as keys
+	hsel *Select
 }
 
 type aexp struct {
@@ -40,12 +44,6 @@ func (co *Compiler) compileArgNode(
 	node *graph.Node,
 	savePath bool) (*Exp, bool, error) {
 
-	if node == nil || len(node.Children) == 0 {
-		return nil, false, errors.New("invalid argument value")
-	}
-
-	needsUser := false
-
 	ast := &aexpst{
 		co:       co,
 		st:       st,
@@ -54,10 +52,21 @@ func (co *Compiler) compileArgNode(
 		savePath: savePath,
 	}
 
+	return ast.compileNode(node)
+}
+
+func (ast *aexpst) compileNode(node *graph.Node) (*Exp, bool, error) {
+	if node == nil || len(node.Children) == 0 {
+		return nil, false, errors.New("invalid argument value")
+	}
+
+	needsUser := false
+	st := ast.st
+
 	var root *Exp
 
 	st.Push(aexp{
-		ti:   ti,
+		ti:   ast.ti,
 		node: node,
 	})
 
@@ -157,10 +166,12 @@ func (ast *aexpst) parseNode(av aexp, node *graph.Node) (*Exp, error) {
 			return nil, fmt.Errorf("[Where] invalid operation: %s", name)
 		}
 
-		if ok, err := ast.processNestedTable(av, ex, node); err != nil {
-			return nil, err
-		} else if ok {
-			return ex, nil
+		if ast.hsel == nil {
+			if ok, err := ast.processNestedTable(av, ex, node); err != nil {
+				return nil, err
+			} else if ok {
+				return ex, nil
+			}
 		}
 
 		if _, err := ast.processColumn(av, ex, node); err != nil {
@@ -435,6 +446,19 @@ func (ast *aexpst) processColumn(av aexp, ex *Exp, node *graph.Node) (bool, erro
 	} else {
 		nn = node.Name
 	}
+
+	if ast.hsel != nil &&
+		(strings.HasPrefix(nn, "date_trunc_") || ast.co.funcPrefixLen(nn) != 0) {
+		fn, _, err := ast.co.isFunction(ast.hsel, nn, "")
+		if err != nil {
+			return false, err
+		}
+		ex.Left.Col = fn.Col
+		ex.Left.Col.Type = fn.Type()
+		ex.Left.Func = &fn
+		return true, nil
+	}
+
 	col, err := av.ti.GetColumn(nn)
 	if err != nil {
 		return false, err
@@ -487,7 +511,7 @@ func (ast *aexpst) processNestedTable(av aexp, ex *Exp, node *graph.Node) (bool,
 			rel := sdata.PathToRel(path[i])
 			joins = append(joins, Join{
 				Rel:    rel,
-				Filter: buildFilter(rel, -1),
+				Filter: ast.co.joinFilter(rel, -1),
 			})
 		}
 
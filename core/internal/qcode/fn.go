@@ -54,6 +54,16 @@ func (co *Compiler) isFunction(sel *Select, fname, alias string) (Function, bool
 	case strings.HasSuffix(fname, "_cursor"):
 		fn.skip = true
 
+	// time buckets eg. date_trunc_month_created_at
+	case strings.HasPrefix(fname, "date_trunc_"):
+		v := strings.SplitN(fname[11:], "_", 2)
+		if len(v) != 2 || !isDateTruncUnit(v[0]) {
+			return fn, false, fmt.Errorf("invalid date_trunc function: %s", fname)
+		}
+		fn.Name = "date_trunc"
+		fn.Arg = v[0]
+		cn = v[1]
+
 	default:
 		n := co.funcPrefixLen(fname)
 		if n != 0 {
@@ -89,3 +99,35 @@ func (co *Compiler) funcPrefixLen(col string) int {
 
 	return 0
 }
+
+var dateTruncUnits = []string{
+	"year",
+	"quarter",
+	"month",
+	"week",
+	"day",
+	"hour",
+	"minute",
+}
+
+func isDateTruncUnit(unit string) bool {
+	for _, v := range dateTruncUnits {
+		if v == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// Type returns the database type of the value returned by the function
+func (fn Function) Type() string {
+	switch fn.Name {
+	case "count":
+		return "bigint"
+	case "avg", "sum", "stddev", "stddev_pop", "stddev_samp",
+		"variance", "var_pop", "var_samp":
+		return "numeric"
+	default:
+		return fn.Col.Type
+	}
+}
@@ -60,9 +60,34 @@ type Mutate struct {
 	Ti       sdata.DBTable
 	Rel      sdata.DBRel
 	Where    Filter
+	Check    Filter
 	Multi    bool
 	children []int32
 	render   bool
+
+	// OnConflict is only set on the root of an upsert
+	OnConflict *OnConflict
+
+	// SoftDelete is the column set by a delete instead of
+	// deleting the rows, the name is empty if not used
+	SoftDelete sdata.DBColumn
+
+	// Version is the version column of the table and the value
+	// read by the client, only set on the root of an update or upsert
+	Version MColumn
+}
+
+// OnConflict is the conflict target of an upsert and the columns
+// to update when a row with the same key already exists
+type OnConflict struct {
+	// Constraint is the name of the unique index used as the conflict
+	// target, when not set the unique columns in the data are used
+	Constraint string
+	Cols       []sdata.DBColumn
+	// UpdateCols are set from the new row on a conflict, when not
+	// set in the argument all the columns in the data are updated
+	UpdateCols []sdata.DBColumn
+	Where      Filter
 }
 
 type MColumn struct {
@@ -93,7 +118,7 @@ func (co *Compiler) compileMutation(qc *QCode, role string) error {
 	var whereReq bool
 
 	sel := &qc.Selects[0]
-	m := Mutate{ParentID: -1, Key: sel.Table, Ti: sel.Ti}
+	m := Mutate{ParentID: -1, Key: sel.Table, Ti: sel.Ti, OnConflict: sel.onConflict}
 
 	switch qc.SType {
 	case QTInsert:
@@ -116,6 +141,7 @@ func (co *Compiler) compileMutation(qc *QCode, role string) error {
 	}
 
 	if m.Type == MTDelete {
+		m.SoftDelete, _ = co.softDeleteCol(sel.Ti)
 		m.render = true
 		qc.Mutates = append(qc.Mutates, m)
 		return nil
@@ -265,6 +291,18 @@ func (co *Compiler) newMutate(ms *mState, m Mutate, role string) error {
 		return err
 	}
 
+	if err := addCheck(&m, trv); err != nil {
+		return err
+	}
+
+	if err := addOnConflict(ms.qc, &m, trv); err != nil {
+		return err
+	}
+
+	if err := co.addVersion(&m); err != nil {
+		return err
+	}
+
 	m.render = true
 
 	// For inserts order the children according to
@@ -405,6 +443,100 @@ func (co *Compiler) processNestedMutations(ms *mState, m *Mutate, data *graph.No
 	return items, nil
 }
 
+// addCheck sets the role filter that the rows written by this mutate
+// must pass, it's the insert version of a row level security WITH CHECK
+func addCheck(m *Mutate, trv trval) error {
+	fil, userNeeded := trv.check(m.Type)
+	if fil == nil || fil.Op == OpNop {
+		return nil
+	}
+	if userNeeded && trv.role == "anon" {
+		return errUserIDReq
+	}
+	m.Check.Exp = fil
+	return nil
+}
+
+// addOnConflict checks the update columns of the on_conflict argument
+// against the role and the data, and defaults them to all the columns
+func addOnConflict(qc *QCode, m *Mutate, trv trval) error {
+	oc := m.OnConflict
+	if oc == nil {
+		return nil
+	}
+
+	if len(oc.Cols) == 0 {
+		for _, col := range m.Cols {
+			if col.Col.UniqueKey || col.Col.PrimaryKey {
+				oc.Cols = append(oc.Cols, col.Col)
+			}
+		}
+		if len(oc.Cols) == 0 {
+			oc.Cols = append(oc.Cols, m.Ti.PrimaryCol)
+		}
+	}
+
+	if oc.UpdateCols == nil {
+		for _, col := range m.Cols {
+			oc.UpdateCols = append(oc.UpdateCols, col.Col)
+		}
+		return nil
+	}
+
+	for _, col := range oc.UpdateCols {
+		if !trv.columnAllowed(qc, col.Name) {
+			return permError("on_conflict: column blocked: %s (%s)", col.Name, trv.role)
+		}
+		if !m.hasCol(col.Name) {
+			return fmt.Errorf("on_conflict: column '%s' not found in the upsert data", col.Name)
+		}
+	}
+	return nil
+}
+
+// addVersion moves the version column out of the data of an update, the
+// value sent is the version the client read and is only used to match
+// the row. The column is bumped instead of set when the row is changed
+func (co *Compiler) addVersion(m *Mutate) error {
+	if m.ParentID != -1 || (m.Type != MTUpdate && m.Type != MTUpsert) {
+		return nil
+	}
+
+	tc := co.getTConfig(m.Ti.Schema, m.Ti.Name)
+	if tc.VersionColumn == "" {
+		return nil
+	}
+
+	if m.IsArray {
+		return fmt.Errorf("version_column: a list of rows cannot be updated on table '%s'", m.Ti.Name)
+	}
+
+	for i, col := range m.Cols {
+		if col.Col.Name != tc.VersionColumn {
+			continue
+		}
+		if col.Value != "" {
+			return fmt.Errorf("version_column: '%s' cannot be a preset", col.Col.Name)
+		}
+		m.Version = col
+		if m.Type == MTUpdate {
+			m.Cols = append(m.Cols[:i], m.Cols[i+1:]...)
+		}
+		return nil
+	}
+	return fmt.Errorf("version_column: '%s' required to change table '%s'",
+		tc.VersionColumn, m.Ti.Name)
+}
+
+func (m *Mutate) hasCol(name string) bool {
+	for _, col := range m.Cols {
+		if col.Col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (co *Compiler) processDirectives(ms *mState, m *Mutate, data *graph.Node, trv trval) error {
 	var filterNode *graph.Node
 	var err error
@@ -586,7 +718,7 @@ func (co *Compiler) getColumnsFromData(m *Mutate, data *graph.Node, trv trval, c
 			}
 
 			if col.Blocked {
-				return nil, fmt.Errorf("column blocked: %s", k)
+				return nil, permError("column blocked: %s", k)
 			}
 
 			cols = append(cols, MColumn{Col: m.Ti.Columns[i], FieldName: k})
@@ -612,7 +744,7 @@ func (co *Compiler) getColumnsFromData(m *Mutate, data *graph.Node, trv trval, c
 		}
 
 		if col.Blocked {
-			return nil, fmt.Errorf("column blocked: %s", k)
+			return nil, permError("column blocked: %s", k)
 		}
 
 		cols = append(cols, MColumn{Col: col, FieldName: k})
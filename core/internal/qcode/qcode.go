@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"path"
 	"strconv"
 	"strings"
@@ -58,6 +59,7 @@ type QCode struct {
 	Type      QType
 	SType     QType
 	Name      string
+	Role      string
 	ActionVar string
 	ActionArg graph.Arg
 	Selects   []Select
@@ -73,6 +75,7 @@ type QCode struct {
 	Metadata  allow.Metadata
 	Cache     Cache
 	OPA       OPA
+	Diff      bool
 }
 
 type Select struct {
@@ -90,8 +93,11 @@ type Select struct {
 	Where      Filter
 	OrderBy    []OrderBy
 	GroupCols  bool
+	GroupBy    []GroupBy
+	Having     Filter
 	DistinctOn []sdata.DBColumn
 	Paging     Paging
+	Connection *Connection
 	Children   []int32
 	SkipRender SkipType
 	Ti         sdata.DBTable
@@ -100,6 +106,11 @@ type Select struct {
 	order      Order
 	through    string
 	tc         TConfig
+	onConflict *OnConflict
+
+	// SetFunc is set when the rows come from a function
+	// instead of the table
+	SetFunc *SetFunc
 }
 
 type TableInfo struct {
@@ -109,16 +120,50 @@ type TableInfo struct {
 type Column struct {
 	Col       sdata.DBColumn
 	FieldName string
+	// Mask redacts the value returned for the role
+	Mask string
+}
+
+// Masks for the column values returned, a mask can also
+// be a 'sql:' expression
+const (
+	MaskNull         = "null"          // always null
+	MaskPartialEmail = "partial_email" // first letter and the domain
+	MaskLast4        = "last4"         // last four characters
+)
+
+// SetFunc is a database function returning rows that is called
+// in place of the table, the arguments are passed by name
+type SetFunc struct {
+	Name string
+	Args []SetFuncArg
+}
+
+type SetFuncArg struct {
+	Name    string
+	Type    string
+	Val     string
+	ValType ValType
 }
 
 type Function struct {
-	Name      string
+	Name string
+	// Arg is the argument of a function that takes one, for example
+	// the unit (month, day, etc) of date_trunc
+	Arg       string
 	Col       sdata.DBColumn
 	FieldName string
 	Alias     string
 	skip      bool
 }
 
+// GroupBy is an entry in the group_by argument, either
+// a column or a time bucket function like date_trunc
+type GroupBy struct {
+	Col  sdata.DBColumn
+	Func *Function
+}
+
 type Filter struct {
 	*Exp
 }
@@ -133,6 +178,9 @@ type Exp struct {
 		ID    int32
 		Table string
 		Col   sdata.DBColumn
+		// Func is set when the left side is a function of the
+		// column, eg. 'sum_total' in a having argument
+		Func *Function
 	}
 	Right struct {
 		ValType  ValType
@@ -273,6 +321,7 @@ type Compiler struct {
 	c  Config
 	s  *sdata.DBSchema
 	tr map[string]trval
+	rf map[string]struct{} // functions allowed for a role
 }
 
 func NewCompiler(s *sdata.DBSchema, c Config) (*Compiler, error) {
@@ -286,7 +335,16 @@ func NewCompiler(s *sdata.DBSchema, c Config) (*Compiler, error) {
 	c.defTrv.upsert.block = c.DefaultBlock
 	c.defTrv.delete.block = c.DefaultBlock
 
-	return &Compiler{c: c, s: s, tr: make(map[string]trval)}, nil
+	if c.MaxSelectors == 0 {
+		c.MaxSelectors = maxSelectors
+	}
+
+	return &Compiler{
+		c:  c,
+		s:  s,
+		tr: make(map[string]trval),
+		rf: make(map[string]struct{}),
+	}, nil
 }
 
 func (co *Compiler) Compile(query []byte, vars Variables, role string) (*QCode, error) {
@@ -297,7 +355,7 @@ func (co *Compiler) Compile(query []byte, vars Variables, role string) (*QCode,
 		return nil, err
 	}
 
-	qc := QCode{Name: op.Name, SType: QTQuery, Schema: co.s, Vars: vars}
+	qc := QCode{Name: op.Name, Role: role, SType: QTQuery, Schema: co.s, Vars: vars}
 	qc.Roots = qc.rootsA[:0]
 
 	switch op.Type {
@@ -315,7 +373,15 @@ func (co *Compiler) Compile(query []byte, vars Variables, role string) (*QCode,
 		return nil, err
 	}
 
-	if qc.Type == QTMutation {
+	if mc := co.maxCost(role); mc != 0 {
+		if cost := queryCost(&qc); cost > int64(mc) {
+			return nil, fmt.Errorf("query cost %d exceeds the limit for role '%s' (%d)",
+				cost, role, mc)
+		}
+	}
+
+	// functions called from a mutation are rendered like a query
+	if qc.Type == QTMutation && !isFuncMutation(&qc) {
 		if err := co.compileMutation(&qc, role); err != nil {
 			return nil, err
 		}
@@ -324,6 +390,58 @@ func (co *Compiler) Compile(query []byte, vars Variables, role string) (*QCode,
 	return &qc, nil
 }
 
+// isFuncMutation is true when the mutation calls a function
+func isFuncMutation(qc *QCode) bool {
+	return len(qc.Selects) != 0 && qc.Selects[0].SetFunc != nil
+}
+
+// selectDepth returns the nesting depth of a selector, root selectors
+// are at depth 1
+func selectDepth(qc *QCode, sel *Select) int {
+	d := 1
+	for id := sel.ParentID; id != -1; id = qc.Selects[id].ParentID {
+		d++
+	}
+	return d
+}
+
+// queryCost adds up the number of rows each selector can return multiplied
+// by the cost weight of its table. The rows of a nested selector are its
+// limit multiplied by the rows of its parent.
+func queryCost(qc *QCode) int64 {
+	const maxRows = math.MaxInt32
+
+	var cost int64
+	rows := make([]int64, len(qc.Selects))
+
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+		n := int64(1)
+
+		if !sel.Singular && sel.Paging.Limit > 0 {
+			n = int64(sel.Paging.Limit)
+		}
+		if sel.ParentID != -1 {
+			n *= rows[sel.ParentID]
+		}
+		if n > maxRows {
+			n = maxRows
+		}
+		rows[i] = n
+
+		if sel.SkipRender == SkipTypeUserNeeded {
+			continue
+		}
+
+		w := int64(1)
+		if sel.tc.Cost > 0 {
+			w = int64(sel.tc.Cost)
+		}
+		cost += n * w
+	}
+	return cost
+}
+
 // Recursively get all OpEquals type expressions in a slice
 func getExpressions(exp Exp) []Exp {
 	res := make([]Exp, 0, 10)
@@ -364,20 +482,26 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 		return errors.New("empty query")
 	}
 
+	var roots int
 	for _, f := range op.Fields {
 		if f.ParentID == -1 {
 			val := f.ID | (-1 << 16)
 			st.Push(val)
+			roots++
 		}
 	}
 
+	if co.c.MaxRootFields != 0 && roots > co.c.MaxRootFields {
+		return fmt.Errorf("too many root fields %d (max %d)", roots, co.c.MaxRootFields)
+	}
+
 	for {
 		if st.Len() == 0 {
 			break
 		}
 
-		if id >= maxSelectors {
-			return fmt.Errorf("selector limit reached (%d)", maxSelectors)
+		if id >= int32(co.c.MaxSelectors) {
+			return fmt.Errorf("selector limit reached (%d)", co.c.MaxSelectors)
 		}
 
 		val := st.Pop()
@@ -417,27 +541,53 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 
 		sel.Children = make([]int32, 0, 5)
 
+		if err := co.compileConnection(op, sel, &field); err != nil {
+			return selectorError(qc, sel, err)
+		}
+
+		if co.c.MaxDepth != 0 {
+			if d := selectDepth(qc, sel); d > co.c.MaxDepth {
+				return selectorError(qc, sel,
+					fmt.Errorf("query depth %d exceeds the limit (%d)", d, co.c.MaxDepth))
+			}
+		}
+
 		if err := co.compileDirectives(qc, sel, field.Directives); err != nil {
-			return err
+			return selectorError(qc, sel, err)
 		}
 
 		if err := co.addRelInfo(op, qc, sel, field); err != nil {
-			return err
+			return selectorError(qc, sel, err)
 		}
 
 		tr, err := co.setSelectorRole(role, field.Name, qc, sel)
 		if err != nil {
-			return err
+			return selectorError(qc, sel, err)
 		}
 
 		co.setLimit(tr, qc, sel)
 
 		if err := co.compileArgs(qc, sel, field.Args, role); err != nil {
-			return err
+			return selectorError(qc, sel, err)
+		}
+
+		if err := validateGroupArgs(qc, sel, tr); err != nil {
+			return selectorError(qc, sel, err)
+		}
+
+		if err := co.validateMaskedArgs(sel, tr); err != nil {
+			return selectorError(qc, sel, err)
+		}
+
+		if sel.Connection != nil {
+			if sel.Singular || sel.Rel.Type == sdata.RelRecursive {
+				return selectorError(qc, sel, errors.New("connection: selector must return a list"))
+			}
+			sel.Paging.Cursor = true
 		}
 
 		if err := co.compileColumns(st, op, qc, sel, field, tr); err != nil {
-			return err
+			return selectorError(qc, sel, err)
 		}
 
 		// Order is important AddFilters must come after compileArgs
@@ -445,9 +595,13 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 			sel.SkipRender = SkipTypeUserNeeded
 		}
 
+		if err := co.addSoftDeleteFilter(qc, sel, tr, role); err != nil {
+			return selectorError(qc, sel, err)
+		}
+
 		// Determine if the select can be singular based on the table constraints (PRIMARY and UNIQUE indices)
 		// This will clean up the output and limit the use of arrays, making it less convoluted
-		if !sel.Singular {
+		if !sel.Singular && sel.Connection == nil {
 			// A map of columns that are exactly matched (by either a WHERE statement or through a join relation)
 			cols := make(map[string]sdata.DBColumn)
 
@@ -490,12 +644,17 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 			}
 		}
 
+		// totalCount needs the filter without the cursor predicate
+		if sel.Connection != nil {
+			sel.Connection.Where = sel.Where
+		}
+
 		// If an actual cursor is available
 		if sel.Paging.Cursor {
 			// Set tie-breaker order column for the cursor direction
 			// this column needs to be the last in the order series.
 			if err := co.orderByIDCol(sel); err != nil {
-				return err
+				return selectorError(qc, sel, err)
 			}
 
 			// Set filter chain needed to make the cursor work
@@ -508,8 +667,15 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 		// this table with its parent
 		co.setRelFilters(qc, sel)
 
+		if sel.Connection != nil {
+			s := *sel
+			s.Where = sel.Connection.Where
+			co.setRelFilters(qc, &s)
+			sel.Connection.Where = s.Where
+		}
+
 		if err := co.validateSelect(sel); err != nil {
-			return err
+			return selectorError(qc, sel, err)
 		}
 
 		qc.Selects = append(qc.Selects, s1)
@@ -520,6 +686,15 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 		return errors.New("invalid query")
 	}
 
+	// the columns needed by the child selectors are only known
+	// once all the selectors are compiled
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+		if err := validateGroupBy(sel); err != nil {
+			return selectorError(qc, sel, err)
+		}
+	}
+
 	return nil
 }
 
@@ -571,6 +746,15 @@ func (co *Compiler) addRelInfo(
 		}
 		sel.Rel = sdata.PathToRel(path[0])
 
+		switch {
+		case sel.Rel.Type == sdata.RelRecursive && co.s.DBType() == "mssql",
+			sel.Rel.Type == sdata.RelRecursive && co.s.DBType() == "sqlite":
+			return fmt.Errorf("%s: recursive relationships are not supported", co.s.DBType())
+
+		case sel.Rel.Type == sdata.RelEmbedded && co.s.DBType() == "sqlite":
+			return fmt.Errorf("sqlite: embedded json tables are not supported")
+		}
+
 		// for _, p := range path {
 		// 	rel := sdata.PathToRel(p)
 		// 	fmt.Println(childF.Name, parentF.Name,
@@ -591,7 +775,7 @@ func (co *Compiler) addRelInfo(
 			}
 			sel.Joins = append(sel.Joins, Join{
 				Rel:    rel,
-				Filter: buildFilter(rel, pid),
+				Filter: co.joinFilter(rel, pid),
 			})
 		}
 	}
@@ -607,8 +791,20 @@ func (co *Compiler) addRelInfo(
 		sel.Ti = sel.Rel.Left.Ti
 	}
 
+	if f, ok := co.s.GetSetFunction(field.Name); ok {
+		switch {
+		case sel.ParentID != -1:
+			return fmt.Errorf("function '%s' can only be used as a root field", f.Name)
+		case f.Mutation && qc.Type != QTMutation:
+			return fmt.Errorf("function '%s' can only be used in a mutation", f.Name)
+		case !f.Mutation && qc.Type == QTMutation:
+			return fmt.Errorf("function '%s' cannot be used in a mutation", f.Name)
+		}
+		sel.SetFunc = &SetFunc{Name: f.Name}
+	}
+
 	if sel.Ti.Blocked {
-		return fmt.Errorf("table: '%t' (%s) blocked", sel.Ti.Blocked, field.Name)
+		return permError("table: '%t' (%s) blocked", sel.Ti.Blocked, field.Name)
 	}
 
 	sel.Table = sel.Ti.Name
@@ -786,6 +982,67 @@ func buildFilter(rel sdata.DBRel, pid int32) *Exp {
 	}
 }
 
+// joinFilter returns the filter to join the table on the left of the
+// relationship, it leaves out the soft deleted rows of the table
+func (co *Compiler) joinFilter(rel sdata.DBRel, pid int32) *Exp {
+	fil := buildFilter(rel, pid)
+
+	if ex := co.softDeleteFilter(rel.Left.Ti); ex != nil {
+		and := newExpOp(OpAnd)
+		and.Children = []*Exp{fil, ex}
+		return and
+	}
+	return fil
+}
+
+// softDeleteCol returns the column used to soft delete
+// the rows of the table if one is configured
+func (co *Compiler) softDeleteCol(ti sdata.DBTable) (sdata.DBColumn, bool) {
+	tc := co.getTConfig(ti.Schema, ti.Name)
+	if tc.SoftDelete == "" {
+		return sdata.DBColumn{}, false
+	}
+	// the column is checked when the config is loaded
+	col, err := ti.GetColumn(tc.SoftDelete)
+	return col, err == nil
+}
+
+// softDeleteFilter returns the filter that leaves out the soft deleted
+// rows of the table, nil if it does not use soft deletes
+func (co *Compiler) softDeleteFilter(ti sdata.DBTable) *Exp {
+	col, ok := co.softDeleteCol(ti)
+	if !ok {
+		return nil
+	}
+	ex := newExpOp(OpIsNull)
+	ex.Left.Col = col
+	return ex
+}
+
+// addSoftDeleteFilter leaves out the soft deleted rows of the selector
+// unless the with_deleted argument is set by a role allowed to use it.
+// The where clause of the root of an update or upsert also picks the rows
+// changed so soft deleted rows cannot be updated, while the root of other
+// mutations selects the rows they changed so it's left as is.
+func (co *Compiler) addSoftDeleteFilter(qc *QCode, sel *Select, tr trval, role string) error {
+	if _, ok := sel.Args["with_deleted"]; ok {
+		if !tr.query.deleted {
+			return permError("with_deleted: not allowed for role '%s'", role)
+		}
+		return nil
+	}
+
+	if qc.Type == QTMutation && sel.ParentID == -1 &&
+		qc.SType != QTUpdate && qc.SType != QTUpsert {
+		return nil
+	}
+
+	if ex := co.softDeleteFilter(sel.Ti); ex != nil {
+		setFilter(&sel.Where, ex)
+	}
+	return nil
+}
+
 func (co *Compiler) setSingular(fieldName string, sel *Select) {
 	if sel.Singular {
 		return
@@ -811,7 +1068,7 @@ func (co *Compiler) setSelectorRole(role, fieldName string, qc *QCode, sel *Sele
 
 	if tr.isBlocked(qc.SType) {
 		if qc.SType != QTQuery {
-			return tr, fmt.Errorf("%s blocked: %s (role: %s)", qc.SType, fieldName, role)
+			return tr, permError("%s blocked: %s (role: %s)", qc.SType, fieldName, role)
 		}
 		sel.SkipRender = SkipTypeUserNeeded
 	}
@@ -928,6 +1185,9 @@ func (co *Compiler) compileOpDirectives(qc *QCode, dirs []graph.Directive) error
 		case "script":
 			err = co.compileDirectiveScript(qc, d)
 
+		case "diff":
+			err = co.compileDirectiveDiff(qc, d)
+
 		case "constraint", "validate":
 			err = co.compileDirectiveConstraint(qc, d)
 
@@ -999,6 +1259,12 @@ func (co *Compiler) compileArgs(qc *QCode, sel *Select, args []graph.Arg, role s
 		case "distinct_on", "distinct":
 			err = co.compileArgDistinctOn(sel, arg)
 
+		case "group_by":
+			err = co.compileArgGroupBy(sel, arg)
+
+		case "having":
+			err = co.compileArgHaving(sel, arg, role)
+
 		case "limit":
 			err = co.compileArgLimit(sel, arg)
 
@@ -1019,6 +1285,15 @@ func (co *Compiler) compileArgs(qc *QCode, sel *Select, args []graph.Arg, role s
 
 		case "find":
 			err = co.compileArgFind(sel, arg)
+
+		case "on_conflict":
+			err = co.compileArgOnConflict(qc, sel, arg, role)
+
+		case "with_deleted":
+			err = co.compileArgWithDeleted(sel, arg)
+
+		case "args":
+			err = co.compileArgArgs(sel, arg)
 		}
 
 		if err != nil {
@@ -1057,6 +1332,15 @@ func (co *Compiler) setMutationType(qc *QCode, op *graph.Operation, role string)
 		return nil
 	}
 
+	// functions that change data are called with the
+	// permissions of the role and return rows like a query
+	if f, ok := co.s.GetSetFunction(op.Fields[0].Name); ok && f.Mutation {
+		if _, ok := co.rf[(role + ":" + f.Name)]; !ok {
+			return permError("function '%s' not allowed (role: %s)", f.Name, role)
+		}
+		return nil
+	}
+
 	args := op.Fields[0].Args
 
 	for _, arg := range args {
@@ -1192,6 +1476,19 @@ func (co *Compiler) compileDirectiveScript(qc *QCode, d *graph.Directive) error
 	return nil
 }
 
+func (co *Compiler) compileDirectiveDiff(qc *QCode, d *graph.Directive) error {
+	if qc.Type != QTSubscription {
+		return fmt.Errorf("@diff: only valid on subscriptions")
+	}
+
+	if len(d.Args) != 0 {
+		return fmt.Errorf("@diff: invalid argument: %s", d.Args[0].Name)
+	}
+
+	qc.Diff = true
+	return nil
+}
+
 type validator struct {
 	name   string
 	types  []graph.ParserType
@@ -1434,7 +1731,7 @@ func (co *Compiler) compileArgID(sel *Select, arg *graph.Arg) error {
 func (co *Compiler) compileArgSearch(sel *Select, arg *graph.Arg) error {
 	if len(sel.Ti.FullText) == 0 {
 		switch co.s.DBType() {
-		case "mysql":
+		case "mysql", "mssql", "sqlite":
 			return fmt.Errorf("no fulltext indexes defined for table '%s'", sel.Table)
 		default:
 			return fmt.Errorf("no tsvector column defined on table '%s'", sel.Table)
@@ -1468,6 +1765,139 @@ func (co *Compiler) compileArgWhere(ti sdata.DBTable, sel *Select, arg *graph.Ar
 	return nil
 }
 
+func (co *Compiler) compileArgOnConflict(qc *QCode, sel *Select, arg *graph.Arg, role string) error {
+	if qc.SType != QTUpsert || sel.ParentID != -1 {
+		return errors.New("on_conflict: only valid on the root of an upsert")
+	}
+
+	if arg.Val.Type != graph.NodeObj {
+		return argErr("on_conflict", "object")
+	}
+
+	oc := &OnConflict{}
+
+	for _, cn := range arg.Val.Children {
+		switch cn.Name {
+		case "constraint":
+			if cn.Type != graph.NodeStr {
+				return argErr("on_conflict.constraint", "string")
+			}
+			cols, ok := sel.Ti.Indices[cn.Val]
+			if !ok {
+				return fmt.Errorf("on_conflict: unique constraint '%s' not found on table '%s'",
+					cn.Val, sel.Ti.Name)
+			}
+			for _, ci := range cols {
+				col, err := sel.Ti.GetColumn(ci.Column)
+				if err != nil {
+					return err
+				}
+				oc.Cols = append(oc.Cols, col)
+			}
+			oc.Constraint = cn.Val
+
+		case "update_columns":
+			if cn.Type != graph.NodeList && cn.Type != graph.NodeStr {
+				return argErr("on_conflict.update_columns", "list of strings or just a string")
+			}
+			names := []string{cn.Val}
+			if cn.Type == graph.NodeList {
+				names = names[:0]
+				for _, v := range cn.Children {
+					names = append(names, v.Val)
+				}
+			}
+			for _, name := range names {
+				if co.c.EnableCamelcase {
+					name = util.ToSnake(name)
+				}
+				col, err := sel.Ti.GetColumn(name)
+				if err != nil {
+					return err
+				}
+				oc.UpdateCols = append(oc.UpdateCols, col)
+			}
+
+		case "where":
+			node := &graph.Node{
+				Type:     cn.Type,
+				Children: cn.Children,
+				CMap:     cn.CMap,
+			}
+			ex, nu, err := co.compileArgNode(sel.Table, sel.Ti, util.NewStackInf(), node, false)
+			if err != nil {
+				return err
+			}
+			if nu && role == "anon" {
+				return errUserIDReq
+			}
+			setFilter(&oc.Where, ex)
+
+		default:
+			return fmt.Errorf("on_conflict: unknown argument: %s", cn.Name)
+		}
+	}
+
+	sel.onConflict = oc
+	return nil
+}
+
+func (co *Compiler) compileArgWithDeleted(sel *Select, arg *graph.Arg) error {
+	if ifNotArg(*arg, graph.NodeBool) {
+		return argErr("with_deleted", "boolean")
+	}
+	if arg.Val.Val == "true" {
+		sel.addArg(arg)
+	}
+	return nil
+}
+
+// compileArgArgs sets the arguments of a function returning rows
+func (co *Compiler) compileArgArgs(sel *Select, arg *graph.Arg) error {
+	if sel.SetFunc == nil {
+		return fmt.Errorf("args: only valid on functions")
+	}
+	if ifNotArg(*arg, graph.NodeObj) {
+		return argErr("args", "object")
+	}
+
+	f, _ := co.s.GetSetFunction(sel.SetFunc.Name)
+
+	for _, node := range arg.Val.Children {
+		name := node.Name
+		if co.c.EnableCamelcase {
+			name = util.ToSnake(name)
+		}
+
+		var fa SetFuncArg
+		for _, p := range f.Params {
+			if p.Name.String == name {
+				fa = SetFuncArg{Name: name, Type: p.Type}
+				break
+			}
+		}
+		if fa.Name == "" {
+			return fmt.Errorf("args: function '%s' has no argument '%s'", f.Name, name)
+		}
+
+		switch node.Type {
+		case graph.NodeStr:
+			fa.ValType = ValStr
+		case graph.NodeNum:
+			fa.ValType = ValNum
+		case graph.NodeBool:
+			fa.ValType = ValBool
+		case graph.NodeVar:
+			fa.ValType = ValVar
+		default:
+			return argErr(("args." + name), "string, number, boolean or variable")
+		}
+		fa.Val = node.Val
+		sel.SetFunc.Args = append(sel.SetFunc.Args, fa)
+	}
+	return nil
+}
+
 func (co *Compiler) compileArgOrderBy(qc *QCode, sel *Select, arg *graph.Arg) error {
 	node := arg.Val
 
@@ -1639,7 +2069,7 @@ func (co *Compiler) compileArgDistinctOn(sel *Select, arg *graph.Arg) error {
 	if node.Type == graph.NodeStr {
 		if col, err := sel.Ti.GetColumn(node.Val); err == nil {
 			switch co.s.DBType() {
-			case "mysql":
+			case "mysql", "mssql", "sqlite":
 				sel.OrderBy = append(sel.OrderBy, OrderBy{Order: OrderAsc, Col: col})
 			default:
 				sel.DistinctOn = append(sel.DistinctOn, col)
@@ -1652,7 +2082,7 @@ func (co *Compiler) compileArgDistinctOn(sel *Select, arg *graph.Arg) error {
 	for _, cn := range node.Children {
 		if col, err := sel.Ti.GetColumn(cn.Val); err == nil {
 			switch co.s.DBType() {
-			case "mysql":
+			case "mysql", "mssql", "sqlite":
 				sel.OrderBy = append(sel.OrderBy, OrderBy{Order: OrderAsc, Col: col})
 			default:
 				sel.DistinctOn = append(sel.DistinctOn, col)
@@ -1665,6 +2095,110 @@ func (co *Compiler) compileArgDistinctOn(sel *Select, arg *graph.Arg) error {
 	return nil
 }
 
+func (co *Compiler) compileArgGroupBy(sel *Select, arg *graph.Arg) error {
+	node := arg.Val
+
+	if node.Type != graph.NodeList && node.Type != graph.NodeStr {
+		return argErr("group_by", "list of strings or just a string")
+	}
+
+	var names []string
+
+	if node.Type == graph.NodeStr {
+		names = append(names, node.Val)
+	}
+
+	for _, cn := range node.Children {
+		if cn.Type != graph.NodeStr {
+			return argErr("group_by", "list of strings or just a string")
+		}
+		names = append(names, cn.Val)
+	}
+
+	for _, name := range names {
+		if strings.HasPrefix(name, "date_trunc_") {
+			fn, _, err := co.isFunction(sel, name, "")
+			if err != nil {
+				return err
+			}
+			sel.GroupBy = append(sel.GroupBy, GroupBy{Func: &fn})
+			continue
+		}
+
+		col, err := sel.Ti.GetColumn(name)
+		if err != nil {
+			return err
+		}
+		sel.GroupBy = append(sel.GroupBy, GroupBy{Col: col})
+	}
+
+	sel.GroupCols = true
+	return nil
+}
+
+func (co *Compiler) compileArgHaving(sel *Select, arg *graph.Arg, role string) error {
+	if arg.Val.Type != graph.NodeObj {
+		return argErr("having", "object")
+	}
+
+	ast := &aexpst{
+		co:   co,
+		st:   util.NewStackInf(),
+		ti:   sel.Ti,
+		edge: sel.Table,
+		hsel: sel,
+	}
+
+	ex, nu, err := ast.compileNode(arg.Val)
+	if err != nil {
+		return err
+	}
+
+	if nu && role == "anon" {
+		sel.SkipRender = SkipTypeUserNeeded
+	}
+	setFilter(&sel.Having, ex)
+	return nil
+}
+
+// validateGroupBy checks that all columns selected are either in the
+// group_by argument or used in an aggregate function
+func validateGroupBy(sel *Select) error {
+	if len(sel.GroupBy) == 0 {
+		return nil
+	}
+
+	for _, col := range sel.BCols {
+		if !sel.inGroupBy(col.Col.Name, nil) {
+			return fmt.Errorf("column '%s' must be in group_by or used in an aggregate function",
+				col.Col.Name)
+		}
+	}
+
+	for i, fn := range sel.Funcs {
+		if fn.Name == "date_trunc" && !sel.inGroupBy("", &sel.Funcs[i]) {
+			return fmt.Errorf("'%s' must be in group_by", fn.FieldName)
+		}
+	}
+	return nil
+}
+
+func (sel *Select) inGroupBy(colName string, fn *Function) bool {
+	for _, gb := range sel.GroupBy {
+		switch {
+		case fn == nil && gb.Func == nil:
+			if gb.Col.Name == colName {
+				return true
+			}
+		case fn != nil && gb.Func != nil:
+			if gb.Func.Name == fn.Name && gb.Func.Arg == fn.Arg && gb.Func.Col.Name == fn.Col.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (co *Compiler) compileArgLimit(sel *Select, arg *graph.Arg) error {
 	node := arg.Val
 
@@ -1718,6 +2252,10 @@ func (co *Compiler) compileArgFirstLast(sel *Select, arg *graph.Arg, order Order
 		return err
 	}
 
+	if !sel.Singular && (co.s.DBType() == "mssql" || co.s.DBType() == "sqlite") {
+		return fmt.Errorf("%s: cursor pagination is not supported, use limit and offset", co.s.DBType())
+	}
+
 	if !sel.Singular {
 		sel.Paging.Cursor = true
 	}
@@ -1733,6 +2271,11 @@ func (co *Compiler) compileArgAfterBefore(sel *Select, arg *graph.Arg, pt Paging
 		return fmt.Errorf("value for argument '%s' must be a variable named $cursor", arg.Name)
 	}
 	sel.Paging.Type = pt
+
+	if !sel.Singular && (co.s.DBType() == "mssql" || co.s.DBType() == "sqlite") {
+		return fmt.Errorf("%s: cursor pagination is not supported, use limit and offset", co.s.DBType())
+	}
+
 	if !sel.Singular {
 		sel.Paging.Cursor = true
 	}
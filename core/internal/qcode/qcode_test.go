@@ -3,6 +3,7 @@ package qcode_test
 import (
 	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/dosco/graphjin/core/internal/qcode"
@@ -129,6 +130,147 @@ func TestInvalidCompile2(t *testing.T) {
 	}
 }
 
+func TestInvalidCompilePath(t *testing.T) {
+	qcompile, _ := qcode.NewCompiler(dbs, qcode.Config{})
+	_, err := qcompile.Compile([]byte(`{ products { id user { id no_such_column } } }`), nil, "user")
+
+	if err == nil {
+		t.Fatal(errors.New("expecting an error"))
+	}
+
+	var qerr *qcode.Error
+	if !errors.As(err, &qerr) {
+		t.Fatalf("expecting a qcode.Error got: %T", err)
+	}
+
+	if strings.Join(qerr.Path, ".") != "products.user" {
+		t.Fatalf("unexpected error path: %v", qerr.Path)
+	}
+}
+
+func TestPermissionError(t *testing.T) {
+	qcompile, _ := qcode.NewCompiler(dbs, qcode.Config{})
+	err := qcompile.AddRole("user", "public", "products", qcode.TRConfig{
+		Query: qcode.QueryConfig{
+			Columns: []string{"id"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = qcompile.Compile([]byte(`{ products { id name } }`), nil, "user")
+
+	var perr *qcode.PermissionError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expecting a qcode.PermissionError got: %v", err)
+	}
+
+	_, err = qcompile.Compile([]byte(`{ products { id no_such_column } }`), nil, "user")
+
+	if err == nil || errors.As(err, &perr) {
+		t.Fatalf("expecting an error that is not a qcode.PermissionError got: %v", err)
+	}
+}
+
+func TestCompileLimits(t *testing.T) {
+	gql := []byte(`query {
+		products(limit: 10) {
+			id
+			customers(limit: 10) {
+				id
+				user {
+					id
+				}
+			}
+		}
+		users {
+			id
+		}
+	}`)
+
+	tests := []struct {
+		name string
+		conf qcode.Config
+		err  string
+	}{
+		{"no limits", qcode.Config{}, ""},
+		{"depth", qcode.Config{MaxDepth: 2}, "query depth 3 exceeds the limit (2)"},
+		{"root fields", qcode.Config{MaxRootFields: 1}, "too many root fields 2 (max 1)"},
+		{"selectors", qcode.Config{MaxSelectors: 3}, "selector limit reached (3)"},
+		{"cost", qcode.Config{MaxCost: 2000}, "query cost 2130 exceeds the limit for role 'user' (2000)"},
+		{"role cost", qcode.Config{MaxCost: 2000, RoleMaxCost: map[string]int{"user": 5000}}, ""},
+		{"table cost", qcode.Config{
+			MaxCost: 5000,
+			TConfig: map[string]qcode.TConfig{"publicusers": {Cost: 5}},
+		}, "query cost 10210 exceeds the limit for role 'user' (5000)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qcompile, _ := qcode.NewCompiler(dbs, tt.conf)
+			_, err := qcompile.Compile(gql, nil, "user")
+
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatal(err)
+			case tt.err != "" && err == nil:
+				t.Fatalf("expecting an error: %s", tt.err)
+			case tt.err != "" && err.Error() != tt.err:
+				t.Fatalf("expecting error '%s' got '%s'", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestCompileDiff(t *testing.T) {
+	qcompile, _ := qcode.NewCompiler(dbs, qcode.Config{})
+
+	qc, err := qcompile.Compile([]byte(`subscription @diff { products { id } }`), nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !qc.Diff {
+		t.Fatal("expecting diff to be enabled")
+	}
+
+	_, err = qcompile.Compile([]byte(`query @diff { products { id } }`), nil, "user")
+	if err == nil {
+		t.Fatal(errors.New("expecting an error"))
+	}
+}
+
+func TestCompileConnection(t *testing.T) {
+	qcompile, _ := qcode.NewCompiler(dbs, qcode.Config{})
+
+	qc, err := qcompile.Compile([]byte(`query {
+		products_connection(first: 5) {
+			totalCount
+			edges { cursor node { id name } }
+		}
+	}`), nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sel := qc.Selects[0]
+	if sel.Connection == nil || sel.Table != "products" || !sel.Paging.Cursor {
+		t.Fatal("expecting a connection on products")
+	}
+
+	if len(sel.Cols) != 2 || sel.FieldName != "products_connection" {
+		t.Fatal("expecting the node fields as selector columns")
+	}
+
+	_, err = qcompile.Compile([]byte(`query {
+		products_connection { edges { id } }
+	}`), nil, "user")
+	if err == nil {
+		t.Fatal(errors.New("expecting an error"))
+	}
+}
+
 func TestEmptyCompile(t *testing.T) {
 	qcompile, _ := qcode.NewCompiler(dbs, qcode.Config{})
 	_, err := qcompile.Compile([]byte(``), nil, "user")
@@ -19,19 +19,20 @@ type nodeInfo struct {
 }
 
 type DBSchema struct {
-	typ            string                  // db type
-	ver            int                     // db version
-	schema         string                  // db schema
-	name           string                  // db name
-	tables         []DBTable               // tables
-	SingularSuffix string                  // singular suffix
-	vt             map[string]VirtualTable // for polymorphic relationships
-	fm             map[string]DBFunction   // db functions
-	tindex         map[string]nodeInfo     // table index
-	ai             map[string]nodeInfo     // table alias index
-	ei             map[string][]edgeInfo   // edges index
-	ae             map[int32]TEdge         // all edges
-	rg             *util.Graph             // relationship graph
+	typ            string                   // db type
+	ver            int                      // db version
+	schema         string                   // db schema
+	name           string                   // db name
+	tables         []DBTable                // tables
+	SingularSuffix string                   // singular suffix
+	vt             map[string]VirtualTable  // for polymorphic relationships
+	fm             map[string]DBFunction    // db functions
+	sf             map[string]DBSetFunction // db functions returning rows
+	tindex         map[string]nodeInfo      // table index
+	ai             map[string]nodeInfo      // table alias index
+	ei             map[string][]edgeInfo    // edges index
+	ae             map[int32]TEdge          // all edges
+	rg             *util.Graph              // relationship graph
 }
 
 type RelType int
@@ -75,6 +76,7 @@ func NewDBSchema(
 		name:   info.Name,
 		vt:     make(map[string]VirtualTable),
 		fm:     make(map[string]DBFunction),
+		sf:     make(map[string]DBSetFunction),
 		tindex: make(map[string]nodeInfo),
 		ai:     make(map[string]nodeInfo),
 		ei:     make(map[string][]edgeInfo),
@@ -93,6 +95,10 @@ func NewDBSchema(
 		}
 	}
 
+	for _, f := range info.SetFunctions {
+		schema.addSetFunction(f)
+	}
+
 	for _, t := range schema.tables {
 		err := schema.addRels(t)
 		if err != nil {
@@ -112,6 +118,17 @@ func NewDBSchema(
 		}
 	}
 
+	// Functions returning the rows of a table have the
+	// relationships of the table
+	for _, f := range schema.sf {
+		if f.Table == "" {
+			continue
+		}
+		if e, ok := schema.ei[f.Table]; ok {
+			schema.ei[f.Name] = e
+		}
+	}
+
 	for k, f := range info.Functions {
 		if len(f.Params) == 1 {
 			schema.fm[strings.ToLower(f.Name)] = info.Functions[k]
@@ -360,6 +377,49 @@ func (s *DBSchema) GetFunctions() map[string]DBFunction {
 	return s.fm
 }
 
+// addSetFunction adds a function returning rows as a table. The ones
+// returning a table point to that table and the others get a table of
+// their own built from the columns returned. Functions with unnamed
+// params are left out since the arguments are passed by name.
+func (s *DBSchema) addSetFunction(f DBSetFunction) {
+	k := (f.Schema + ":" + f.Name)
+	if _, ok := s.tindex[k]; ok {
+		return
+	}
+
+	for _, p := range f.Params {
+		if p.Name.String == "" {
+			return
+		}
+	}
+
+	switch {
+	case f.Table != "":
+		n, ok := s.tindex[(f.Schema + ":" + f.Table)]
+		if !ok || s.tables[n.nodeID].Blocked {
+			return
+		}
+		s.tindex[k] = n
+
+	case len(f.Columns) != 0:
+		s.addNode(NewDBTable(f.Schema, f.Name, "function", f.Columns, nil, nil))
+
+	default:
+		return
+	}
+	s.sf[f.Name] = f
+}
+
+// GetSetFunctions returns the functions that return rows
+func (s *DBSchema) GetSetFunctions() map[string]DBSetFunction {
+	return s.sf
+}
+
+func (s *DBSchema) GetSetFunction(name string) (DBSetFunction, bool) {
+	f, ok := s.sf[name]
+	return f, ok
+}
+
 func getRelName(colName string) string {
 	cn := strings.ToLower(colName)
 
@@ -2,8 +2,10 @@ package sdata
 
 const functionsStmt = `
 SELECT 
+	r.routine_schema as func_schema,
 	r.routine_name as func_name, 
 	p.specific_name as func_id,
+	COALESCE(r.data_type, '') as ret_type,
 	p.data_type as func_type, 
 	p.parameter_name as param_name,
 	p.ordinal_position	as param_id
@@ -19,6 +21,68 @@ ORDER BY
 	r.routine_name, p.ordinal_position;
 `
 
+const postgresFunctionsStmt = `
+SELECT
+	r.routine_schema as func_schema,
+	r.routine_name as func_name,
+	r.specific_name as func_id,
+	(CASE
+		WHEN r.data_type = 'USER-DEFINED' THEN r.type_udt_name
+		ELSE COALESCE(r.data_type, '')
+	END) as ret_type,
+	COALESCE(p.data_type, '') as func_type,
+	p.parameter_name as param_name,
+	COALESCE(p.ordinal_position, 0) as param_id
+FROM
+	information_schema.routines r
+LEFT JOIN
+	information_schema.parameters p
+	ON (r.specific_name = p.specific_name and p.ordinal_position IS NOT NULL)
+WHERE
+	r.specific_schema NOT IN ('_graphjin', 'information_schema', 'pg_catalog')
+AND r.external_language NOT IN ('C')
+ORDER BY
+	r.routine_name, p.ordinal_position;
+`
+
+const postgresSetFunctionsStmt = `
+SELECT
+	n.nspname AS func_schema,
+	p.proname AS func_name,
+	p.oid::text AS func_id,
+	COALESCE(tc.relname, '') AS ret_table,
+	COALESCE(a.name, '') AS param_name,
+	COALESCE(pg_catalog.format_type(a.type, NULL), '') AS param_type,
+	COALESCE(a.mode, 'i') AS param_mode,
+	COALESCE(a.pos, 0) AS param_id
+FROM
+	pg_catalog.pg_proc p
+JOIN
+	pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+JOIN
+	pg_catalog.pg_type t ON t.oid = p.prorettype
+LEFT JOIN
+	pg_catalog.pg_class tc ON (tc.oid = t.typrelid AND tc.relnamespace = p.pronamespace
+		AND tc.relkind IN ('r', 'v', 'm', 'p', 'f'))
+LEFT JOIN LATERAL unnest(
+	COALESCE(p.proallargtypes, p.proargtypes::oid[]),
+	p.proargnames,
+	p.proargmodes::text[]) WITH ORDINALITY AS a(type, name, mode, pos) ON true
+WHERE
+	p.proretset
+AND p.prokind = 'f'
+AND p.provolatile IN ('s', 'i')
+AND n.nspname NOT IN ('_graphjin', 'information_schema', 'pg_catalog')
+AND (tc.oid IS NOT NULL OR t.typname = 'record')
+AND NOT EXISTS (
+	SELECT 1 FROM pg_catalog.pg_depend d
+	WHERE d.classid = 'pg_catalog.pg_proc'::regclass
+	AND d.objid = p.oid
+	AND d.deptype = 'e')
+ORDER BY
+	p.oid, a.pos;
+`
+
 const postgresInfo = `
 SELECT 
 	CAST(current_setting('server_version_num') AS integer) as db_version,
@@ -174,5 +238,228 @@ INNER JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS kcu ON(
 )
 WHERE
 tc.CONSTRAINT_SCHEMA NOT IN ('_graphjin', 'information_schema', 'performance_schema', 'mysql', 'sys') AND
-tc.CONSTRAINT_TYPE IN ("UNIQUE", "PRIMARY KEY");
+tc.CONSTRAINT_TYPE IN ('UNIQUE', 'PRIMARY KEY');
+`
+
+const mssqlInfo = `
+SELECT
+	CAST(SERVERPROPERTY('ProductMajorVersion') AS int) AS db_version,
+	SCHEMA_NAME() AS db_schema,
+	DB_NAME() AS db_name;
+`
+
+const mssqlColumnsStmt = `
+SELECT
+	col.TABLE_SCHEMA AS [schema],
+	col.TABLE_NAME AS [table],
+	col.COLUMN_NAME AS [column],
+	col.DATA_TYPE AS [type],
+	CAST(CASE
+		WHEN col.IS_NULLABLE = 'NO' THEN 1
+		ELSE 0
+	END AS bit) AS not_null,
+	CAST(0 AS bit) AS primary_key,
+	CAST(0 AS bit) AS unique_key,
+	CAST(0 AS bit) AS is_array,
+	CAST(CASE
+		WHEN fti.column_id IS NOT NULL THEN 1
+		ELSE 0
+	END AS bit) AS full_text,
+	'' AS foreignkey_schema,
+	'' AS foreignkey_table,
+	'' AS foreignkey_column
+FROM
+	INFORMATION_SCHEMA.COLUMNS col
+LEFT JOIN sys.columns sc ON sc.object_id = OBJECT_ID(QUOTENAME(col.TABLE_SCHEMA) + '.' + QUOTENAME(col.TABLE_NAME))
+	AND sc.name = col.COLUMN_NAME
+LEFT JOIN sys.fulltext_index_columns fti ON fti.object_id = sc.object_id
+	AND fti.column_id = sc.column_id
+WHERE
+	col.TABLE_SCHEMA NOT IN ('_graphjin', 'INFORMATION_SCHEMA', 'sys')
+UNION ALL
+SELECT
+	kcu.TABLE_SCHEMA AS [schema],
+	kcu.TABLE_NAME AS [table],
+	kcu.COLUMN_NAME AS [column],
+	'' AS [type],
+	CAST(0 AS bit) AS not_null,
+	CAST(CASE
+		WHEN tc.CONSTRAINT_TYPE = 'PRIMARY KEY' THEN 1
+		ELSE 0
+	END AS bit) AS primary_key,
+	CAST(CASE
+		WHEN tc.CONSTRAINT_TYPE = 'UNIQUE' THEN 1
+		ELSE 0
+	END AS bit) AS unique_key,
+	CAST(0 AS bit) AS is_array,
+	CAST(0 AS bit) AS full_text,
+	'' AS foreignkey_schema,
+	'' AS foreignkey_table,
+	'' AS foreignkey_column
+FROM
+	INFORMATION_SCHEMA.KEY_COLUMN_USAGE kcu
+JOIN
+	INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc ON kcu.TABLE_SCHEMA = tc.TABLE_SCHEMA
+	AND kcu.TABLE_NAME = tc.TABLE_NAME
+	AND kcu.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+WHERE
+	tc.CONSTRAINT_TYPE IN ('PRIMARY KEY', 'UNIQUE')
+	AND kcu.TABLE_SCHEMA NOT IN ('_graphjin', 'INFORMATION_SCHEMA', 'sys')
+UNION ALL
+SELECT
+	SCHEMA_NAME(t.schema_id) AS [schema],
+	t.name AS [table],
+	c.name AS [column],
+	'' AS [type],
+	CAST(0 AS bit) AS not_null,
+	CAST(0 AS bit) AS primary_key,
+	CAST(0 AS bit) AS unique_key,
+	CAST(0 AS bit) AS is_array,
+	CAST(0 AS bit) AS full_text,
+	SCHEMA_NAME(rt.schema_id) AS foreignkey_schema,
+	rt.name AS foreignkey_table,
+	rc.name AS foreignkey_column
+FROM
+	sys.foreign_keys fk
+JOIN sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id
+JOIN sys.tables t ON t.object_id = fkc.parent_object_id
+JOIN sys.columns c ON c.object_id = fkc.parent_object_id
+	AND c.column_id = fkc.parent_column_id
+JOIN sys.tables rt ON rt.object_id = fkc.referenced_object_id
+JOIN sys.columns rc ON rc.object_id = fkc.referenced_object_id
+	AND rc.column_id = fkc.referenced_column_id
+WHERE
+	fk.is_disabled = 0
+	AND SCHEMA_NAME(t.schema_id) NOT IN ('_graphjin', 'INFORMATION_SCHEMA', 'sys');
+`
+
+const mssqlFunctionsStmt = `
+SELECT
+	r.ROUTINE_SCHEMA AS func_schema,
+	r.ROUTINE_NAME AS func_name,
+	p.SPECIFIC_NAME AS func_id,
+	COALESCE(r.DATA_TYPE, '') AS ret_type,
+	p.DATA_TYPE AS func_type,
+	SUBSTRING(p.PARAMETER_NAME, 2, 128) AS param_name,
+	p.ORDINAL_POSITION AS param_id
+FROM
+	INFORMATION_SCHEMA.ROUTINES r
+JOIN
+	INFORMATION_SCHEMA.PARAMETERS p
+	ON (r.SPECIFIC_SCHEMA = p.SPECIFIC_SCHEMA AND r.SPECIFIC_NAME = p.SPECIFIC_NAME)
+WHERE
+	r.ROUTINE_TYPE = 'FUNCTION'
+	AND p.IS_RESULT = 'NO'
+	AND p.SPECIFIC_SCHEMA NOT IN ('_graphjin', 'INFORMATION_SCHEMA', 'sys')
+ORDER BY
+	r.ROUTINE_NAME, p.ORDINAL_POSITION;
+`
+
+const mssqlIndexInfoStmt = `
+SELECT
+	tc.CONSTRAINT_SCHEMA AS [schema],
+	tc.CONSTRAINT_NAME AS [constraint],
+	tc.TABLE_NAME AS [table],
+	tc.CONSTRAINT_TYPE AS [type],
+	kcu.COLUMN_NAME AS [column]
+FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS AS tc
+INNER JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS kcu ON (
+	kcu.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND
+	kcu.TABLE_NAME        = tc.TABLE_NAME AND
+	kcu.CONSTRAINT_NAME   = tc.CONSTRAINT_NAME
+)
+WHERE
+tc.CONSTRAINT_SCHEMA NOT IN ('_graphjin', 'INFORMATION_SCHEMA', 'sys') AND
+tc.CONSTRAINT_TYPE IN ('UNIQUE', 'PRIMARY KEY');
+`
+
+const sqliteInfo = `
+WITH v(s) AS (SELECT sqlite_version()),
+	v1(major, r) AS (SELECT CAST(substr(s, 1, instr(s, '.') - 1) AS integer), substr(s, instr(s, '.') + 1) FROM v),
+	v2(major, minor, patch) AS (SELECT major, CAST(substr(r, 1, instr(r, '.') - 1) AS integer), CAST(substr(r, instr(r, '.') + 1) AS integer) FROM v1)
+SELECT
+	major * 1000000 + minor * 1000 + patch AS db_version,
+	'main' AS db_schema,
+	'main' AS db_name
+FROM v2;
+`
+
+const sqliteColumnsStmt = `
+SELECT
+	'main' AS "schema",
+	m.name AS "table",
+	c.name AS "column",
+	lower(c.type) AS "type",
+	c."notnull" AS not_null,
+	(c.pk > 0) AS primary_key,
+	0 AS unique_key,
+	0 AS is_array,
+	0 AS full_text,
+	'' AS foreignkey_schema,
+	'' AS foreignkey_table,
+	'' AS foreignkey_column
+FROM sqlite_master m
+JOIN pragma_table_info(m.name) c
+WHERE
+	m.type IN ('table', 'view') AND
+	m.name NOT LIKE 'sqlite_%'
+UNION ALL
+SELECT
+	'main' AS "schema",
+	m.name AS "table",
+	f."from" AS "column",
+	'' AS "type",
+	0 AS not_null,
+	0 AS primary_key,
+	0 AS unique_key,
+	0 AS is_array,
+	0 AS full_text,
+	'main' AS foreignkey_schema,
+	f."table" AS foreignkey_table,
+	COALESCE(f."to", (
+		SELECT p.name FROM pragma_table_info(f."table") p WHERE p.pk = 1
+	)) AS foreignkey_column
+FROM sqlite_master m
+JOIN pragma_foreign_key_list(m.name) f
+WHERE
+	m.type = 'table' AND
+	m.name NOT LIKE 'sqlite_%'
+UNION ALL
+SELECT
+	'main' AS "schema",
+	m.name AS "table",
+	ii.name AS "column",
+	'' AS "type",
+	0 AS not_null,
+	0 AS primary_key,
+	1 AS unique_key,
+	0 AS is_array,
+	0 AS full_text,
+	'' AS foreignkey_schema,
+	'' AS foreignkey_table,
+	'' AS foreignkey_column
+FROM sqlite_master m
+JOIN pragma_index_list(m.name) il
+JOIN pragma_index_info(il.name) ii
+WHERE
+	m.type = 'table' AND
+	m.name NOT LIKE 'sqlite_%' AND
+	il."unique" = 1 AND
+	(SELECT count(*) FROM pragma_index_info(il.name)) = 1;
+`
+
+const sqliteIndexInfoStmt = `
+SELECT
+	'main' AS "schema",
+	il.name AS "constraint",
+	m.name AS "table",
+	CASE WHEN il.origin = 'pk' THEN 'PRIMARY KEY' ELSE 'UNIQUE' END AS "type",
+	ii.name AS "column"
+FROM sqlite_master m
+JOIN pragma_index_list(m.name) il
+JOIN pragma_index_info(il.name) ii
+WHERE
+	m.type = 'table' AND
+	m.name NOT LIKE 'sqlite_%' AND
+	il."unique" = 1;
 `
@@ -16,12 +16,13 @@ type DBInfo struct {
 	Schema  string
 	Name    string
 
-	Tables    []DBTable      `hash:"set"`
-	Functions []DBFunction   `hash:"set"`
-	VTables   []VirtualTable `hash:"set"`
-	colMap    map[string]int `hash:"-"`
-	tableMap  map[string]int `hash:"-"`
-	hash      uint64         `hash:"-"`
+	Tables       []DBTable       `hash:"set"`
+	Functions    []DBFunction    `hash:"set"`
+	SetFunctions []DBSetFunction `hash:"set"`
+	VTables      []VirtualTable  `hash:"set"`
+	colMap       map[string]int  `hash:"-"`
+	tableMap     map[string]int  `hash:"-"`
+	hash         uint64          `hash:"-"`
 }
 
 type DBIndices map[string][]DBColumnIndex
@@ -74,6 +75,7 @@ func GetDBInfo(
 	var dbSchema, dbName string
 	var cols []DBColumn
 	var funcs []DBFunction
+	var sfuncs []DBSetFunction
 	var tableIndices map[string]DBIndexTable
 	var err error
 
@@ -84,6 +86,10 @@ func GetDBInfo(
 		switch dbType {
 		case "mysql":
 			row = db.QueryRow(mysqlInfo)
+		case "mssql":
+			row = db.QueryRow(mssqlInfo)
+		case "sqlite":
+			row = db.QueryRow(sqliteInfo)
 		default:
 			row = db.QueryRow(postgresInfo)
 		}
@@ -100,11 +106,15 @@ func GetDBInfo(
 			return err
 		}
 
-		if funcs, err = DiscoverFunctions(db, blockList); err != nil {
+		if funcs, err = DiscoverFunctions(db, dbType, blockList); err != nil {
 			return err
 		}
 
-		if tableIndices, err = DiscoverIndices(db); err != nil {
+		if sfuncs, err = DiscoverSetFunctions(db, dbType, blockList); err != nil {
+			return err
+		}
+
+		if tableIndices, err = DiscoverIndices(db, dbType); err != nil {
 			return err
 		}
 		return nil
@@ -124,6 +134,7 @@ func GetDBInfo(
 		tableIndices,
 		blockList,
 	)
+	di.SetFunctions = sfuncs
 
 	di.hash, err = hashstructure.Hash(di, hashstructure.FormatV2, nil)
 	if err != nil {
@@ -255,6 +266,10 @@ func DiscoverColumns(db *sql.DB, dbtype string, blockList []string) ([]DBColumn,
 	switch dbtype {
 	case "mysql":
 		sqlStmt = mysqlColumnsStmt
+	case "mssql":
+		sqlStmt = mssqlColumnsStmt
+	case "sqlite":
+		sqlStmt = sqliteColumnsStmt
 	default:
 		sqlStmt = postgresColumnsStmt
 	}
@@ -326,7 +341,11 @@ func DiscoverColumns(db *sql.DB, dbtype string, blockList []string) ([]DBColumn,
 }
 
 type DBFunction struct {
+	Schema string
 	Name   string
+	// Type is the return type, it's the name of the table
+	// for postgres functions returning a row of a table
+	Type   string
 	Params []DBFuncParam
 }
 
@@ -336,8 +355,34 @@ type DBFuncParam struct {
 	Type string
 }
 
-func DiscoverIndices(db *sql.DB) (map[string]DBIndexTable, error) {
-	rows, err := db.Query(mysqlIndexInfoStmt)
+// DBSetFunction is a function that returns a set of rows, either the rows
+// of a table (SETOF <table>) or its own columns (RETURNS TABLE(...))
+type DBSetFunction struct {
+	Schema string
+	Name   string
+	// Table is the table returned, it's in the same schema as the function
+	Table  string
+	Params []DBFuncParam
+	// Columns are the columns returned when there is no table
+	Columns []DBColumn
+	// Mutation is set for functions that change data, these are
+	// only called from mutations
+	Mutation bool
+}
+
+func DiscoverIndices(db *sql.DB, dbtype string) (map[string]DBIndexTable, error) {
+	var sqlStmt string
+
+	switch dbtype {
+	case "mssql":
+		sqlStmt = mssqlIndexInfoStmt
+	case "sqlite":
+		sqlStmt = sqliteIndexInfoStmt
+	default:
+		sqlStmt = mysqlIndexInfoStmt
+	}
+
+	rows, err := db.Query(sqlStmt)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching index info: %s", err)
 	}
@@ -367,8 +412,22 @@ func DiscoverIndices(db *sql.DB) (map[string]DBIndexTable, error) {
 	return dbIndexTables, nil
 }
 
-func DiscoverFunctions(db *sql.DB, blockList []string) ([]DBFunction, error) {
-	rows, err := db.Query(functionsStmt)
+func DiscoverFunctions(db *sql.DB, dbtype string, blockList []string) ([]DBFunction, error) {
+	var sqlStmt string
+
+	switch dbtype {
+	case "mssql":
+		sqlStmt = mssqlFunctionsStmt
+	case "mysql":
+		sqlStmt = functionsStmt
+	case "sqlite":
+		// sqlite has no stored functions
+		return nil, nil
+	default:
+		sqlStmt = postgresFunctionsStmt
+	}
+
+	rows, err := db.Query(sqlStmt)
 	if err != nil {
 		return nil, fmt.Errorf("Error fetching functions: %s", err)
 	}
@@ -379,14 +438,22 @@ func DiscoverFunctions(db *sql.DB, blockList []string) ([]DBFunction, error) {
 
 	parameterIndex := 1
 	for rows.Next() {
-		var fn, fid string
+		var fs, fn, fid, rt string
 		fp := DBFuncParam{}
 
-		err = rows.Scan(&fn, &fid, &fp.Type, &fp.Name, &fp.ID)
+		err = rows.Scan(&fs, &fn, &fid, &rt, &fp.Type, &fp.Name, &fp.ID)
 		if err != nil {
 			return nil, err
 		}
 
+		// postgres functions without parameters
+		if fp.ID == 0 {
+			if !isInList(fn, blockList) {
+				funcs = append(funcs, DBFunction{Schema: fs, Name: fn, Type: rt})
+			}
+			continue
+		}
+
 		if !fp.Name.Valid {
 			fp.Name.String = fmt.Sprintf("%d", parameterIndex)
 			fp.Name.Valid = true
@@ -398,7 +465,12 @@ func DiscoverFunctions(db *sql.DB, blockList []string) ([]DBFunction, error) {
 			if isInList(fn, blockList) {
 				continue
 			}
-			funcs = append(funcs, DBFunction{Name: fn, Params: []DBFuncParam{fp}})
+			funcs = append(funcs, DBFunction{
+				Schema: fs,
+				Name:   fn,
+				Type:   rt,
+				Params: []DBFuncParam{fp},
+			})
 			fm[fid] = len(funcs) - 1
 		}
 		parameterIndex++
@@ -407,6 +479,81 @@ func DiscoverFunctions(db *sql.DB, blockList []string) ([]DBFunction, error) {
 	return funcs, nil
 }
 
+// DiscoverSetFunctions finds the functions that return a set of rows,
+// these are only supported with postgres. Volatile functions and the
+// ones installed by extensions are left out since they are exposed as
+// query fields
+func DiscoverSetFunctions(db *sql.DB, dbtype string, blockList []string) ([]DBSetFunction, error) {
+	switch dbtype {
+	case "mysql", "mssql", "sqlite":
+		return nil, nil
+	}
+
+	rows, err := db.Query(postgresSetFunctionsStmt)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching set returning functions: %s", err)
+	}
+	defer rows.Close()
+
+	var funcs []DBSetFunction
+	fm := make(map[string]int)
+
+	for rows.Next() {
+		var f DBSetFunction
+		var fid, mode string
+		var fp DBFuncParam
+
+		err = rows.Scan(&f.Schema, &f.Name, &fid, &f.Table, &fp.Name, &fp.Type, &mode, &fp.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := fm[fid]
+		if !ok {
+			if isInList(f.Name, blockList) {
+				continue
+			}
+			funcs = append(funcs, f)
+			i = len(funcs) - 1
+			fm[fid] = i
+		}
+
+		// functions without params have a single row with no param
+		if fp.ID == 0 {
+			continue
+		}
+
+		// unnamed output columns are named by postgres as column<n>
+		if mode != "i" && mode != "v" && fp.Name.String == "" {
+			fp.Name.String = fmt.Sprintf("column%d", len(funcs[i].Columns)+1)
+		}
+
+		// i: in, o: out, b: inout, v: variadic, t: table column
+		switch mode {
+		case "i", "v":
+			funcs[i].Params = append(funcs[i].Params, fp)
+		case "b":
+			funcs[i].Params = append(funcs[i].Params, fp)
+			funcs[i].Columns = append(funcs[i].Columns, funcColumn(f, fp))
+		case "o", "t":
+			funcs[i].Columns = append(funcs[i].Columns, funcColumn(f, fp))
+		}
+	}
+
+	return funcs, nil
+}
+
+func funcColumn(f DBSetFunction, fp DBFuncParam) DBColumn {
+	return DBColumn{
+		ID:     int32(fp.ID),
+		Schema: f.Schema,
+		Table:  f.Name,
+		Name:   fp.Name.String,
+		Type:   fp.Type,
+		Array:  strings.HasSuffix(fp.Type, "[]"),
+	}
+}
+
 func (di *DBInfo) Hash() uint64 {
 	return di.hash
 }
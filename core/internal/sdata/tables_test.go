@@ -1,6 +1,11 @@
 package sdata
 
-import "testing"
+import (
+	"database/sql"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
 
 func TestIsInList(t *testing.T) {
 	list := []string{
@@ -23,3 +28,55 @@ func TestIsInList(t *testing.T) {
 		}
 	}
 }
+
+func TestGetDBInfoSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// a single connection since every new connection gets its own
+	// in-memory database
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id integer PRIMARY KEY, email text NOT NULL UNIQUE);
+		CREATE TABLE posts (id integer PRIMARY KEY, title text, user_id integer REFERENCES users);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	di, err := GetDBInfo(db, "sqlite", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if di.Version < 3000000 || di.Schema != "main" {
+		t.Fatalf("unexpected db info: %d %s", di.Version, di.Schema)
+	}
+
+	c, err := di.GetColumn("main", "users", "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.NotNull || !c.UniqueKey || c.Type != "text" {
+		t.Errorf("unexpected column: %+v", c)
+	}
+
+	c, err = di.GetColumn("main", "posts", "user_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.FKeyTable != "users" || c.FKeyCol != "id" {
+		t.Errorf("expected a foreign key to users.id: %+v", c)
+	}
+
+	c, err = di.GetColumn("main", "posts", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.PrimaryKey {
+		t.Errorf("expected a primary key: %+v", c)
+	}
+}
@@ -1,5 +1,7 @@
 package sdata
 
+import "database/sql"
+
 func GetTestDBInfo() *DBInfo {
 	columns := [][]DBColumn{
 		[]DBColumn{
@@ -61,7 +63,8 @@ func GetTestDBInfo() *DBInfo {
 			DBColumn{Schema: "public", Table: "comments", Name: "product_id", Type: "bigint", NotNull: false, PrimaryKey: false, UniqueKey: false, FKeySchema: "public", FKeyTable: "products", FKeyCol: "id"},
 			DBColumn{Schema: "public", Table: "comments", Name: "commenter_id", Type: "bigint", NotNull: false, PrimaryKey: false, UniqueKey: false, FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
 			DBColumn{Schema: "public", Table: "comments", Name: "reply_to_id", Type: "bigint", NotNull: false, PrimaryKey: false, UniqueKey: false, FKeySchema: "public", FKeyTable: "comments", FKeyCol: "id"},
-			DBColumn{Schema: "public", Table: "comments", Name: "body", Type: "character varying", NotNull: false, PrimaryKey: false, UniqueKey: false}},
+			DBColumn{Schema: "public", Table: "comments", Name: "body", Type: "character varying", NotNull: false, PrimaryKey: false, UniqueKey: false},
+			DBColumn{Schema: "public", Table: "comments", Name: "deleted_at", Type: "timestamp without time zone", NotNull: false, PrimaryKey: false, UniqueKey: false}},
 	}
 
 	var cols []DBColumn
@@ -77,7 +80,53 @@ func GetTestDBInfo() *DBInfo {
 		FKeyColumn: "id"},
 	}
 
-	di := NewDBInfo("", 110000, "public", "db", cols, nil, nil, nil)
+	indices := []DBColumnIndex{
+		{Schema: "public", Table: "users", Constraint: "users_email_key", Type: "UNIQUE", Column: "email"},
+		{Schema: "public", Table: "products", Constraint: "products_name_user_id_key", Type: "UNIQUE", Column: "name", Composite: true},
+		{Schema: "public", Table: "products", Constraint: "products_name_user_id_key", Type: "UNIQUE", Column: "user_id", Composite: true},
+	}
+
+	tableIndices := make(map[string]DBIndexTable)
+
+	for _, ci := range indices {
+		if _, ok := tableIndices[ci.Table]; !ok {
+			tableIndices[ci.Table] = DBIndexTable{
+				Columns: make(DBIndices),
+				Indices: make(DBIndices),
+			}
+		}
+		tableIndices[ci.Table].Indices[ci.Constraint] = append(tableIndices[ci.Table].Indices[ci.Constraint], ci)
+		tableIndices[ci.Table].Columns[ci.Column] = append(tableIndices[ci.Table].Columns[ci.Column], ci)
+	}
+
+	sfuncs := []DBSetFunction{
+		{
+			Schema: "public", Name: "search_products", Table: "products",
+			Params: []DBFuncParam{
+				{ID: 1, Name: sql.NullString{String: "query", Valid: true}, Type: "text"},
+			},
+		},
+		{
+			Schema: "public", Name: "product_stats",
+			Params: []DBFuncParam{
+				{ID: 1, Name: sql.NullString{String: "min_price", Valid: true}, Type: "numeric"},
+			},
+			Columns: []DBColumn{
+				{ID: 2, Schema: "public", Table: "product_stats", Name: "name", Type: "text"},
+				{ID: 3, Schema: "public", Table: "product_stats", Name: "total", Type: "bigint"},
+			},
+		},
+		{
+			Schema: "public", Name: "return_purchase", Table: "purchases",
+			Params: []DBFuncParam{
+				{ID: 1, Name: sql.NullString{String: "purchase_id", Valid: true}, Type: "bigint"},
+			},
+			Mutation: true,
+		},
+	}
+
+	di := NewDBInfo("", 110000, "public", "db", cols, nil, tableIndices, nil)
+	di.SetFunctions = sfuncs
 	di.VTables = vt
 	return di
 }
@@ -158,6 +158,29 @@ func (gj *graphjin) initGraphQLEgine() error {
 		Desc: schema.NewDescription("A cursor is an encoded string use for pagination"),
 	}
 
+	in.Types["PageInfo"] = &schema.Object{
+		Name: "PageInfo",
+		Desc: schema.NewDescription("Information about pagination in a connection"),
+		Fields: schema.FieldList{
+			{
+				Name: "hasNextPage",
+				Type: &schema.NonNull{OfType: &schema.TypeName{Name: "Boolean"}},
+			},
+			{
+				Name: "hasPreviousPage",
+				Type: &schema.NonNull{OfType: &schema.TypeName{Name: "Boolean"}},
+			},
+			{
+				Name: "startCursor",
+				Type: &schema.TypeName{Name: "Cursor"},
+			},
+			{
+				Name: "endCursor",
+				Type: &schema.TypeName{Name: "Cursor"},
+			},
+		},
+	}
+
 	if err := in.addTables(); err != nil {
 		return err
 	}
@@ -209,9 +232,105 @@ func (in *intro) addTables() error {
 		}
 	}
 
+	in.addSetFuncs()
 	return nil
 }
 
+// addSetFuncs adds the functions returning rows as query fields, the
+// rows have the type of the table returned. The functions that change
+// data are added as mutation fields instead
+func (in *intro) addSetFuncs() {
+	for name, f := range in.GetSetFunctions() {
+		tname := f.Table
+		if tname == "" {
+			tname = f.Name
+		}
+
+		if in.gj.conf.EnableCamelcase {
+			name = util.ToCamel(name)
+			tname = util.ToCamel(tname)
+		}
+
+		// the table is blocked or has no columns
+		if _, ok := in.Types[tname+"Output"]; !ok {
+			continue
+		}
+
+		var args schema.InputValueList
+
+		if len(f.Params) != 0 {
+			at := &schema.InputObject{
+				Name: name + "Args", Fields: schema.InputValueList{},
+			}
+			for _, p := range f.Params {
+				pname := p.Name.String
+				if in.gj.conf.EnableCamelcase {
+					pname = util.ToCamel(pname)
+				}
+				pt, _ := getGQLTypeFunc(p)
+				at.Fields = append(at.Fields, &schema.InputValue{Name: pname, Type: pt})
+			}
+			in.Types[at.Name] = at
+
+			args = append(args, &schema.InputValue{
+				Desc: schema.NewDescription("Arguments passed to the function"),
+				Name: "args",
+				Type: &schema.TypeName{Name: at.Name},
+			})
+		}
+
+		args = append(args, schema.InputValueList{
+			&schema.InputValue{
+				Desc: schema.NewDescription("Sort or order results. Use key 'asc' for ascending and 'desc' for descending"),
+				Name: "order_by",
+				Type: &schema.TypeName{Name: tname + "OrderBy"},
+			},
+			&schema.InputValue{
+				Desc: schema.NewDescription("Filter results based on column values or values of columns in related tables"),
+				Name: "where",
+				Type: &schema.TypeName{Name: tname + "Expression"},
+			},
+			&schema.InputValue{
+				Desc: schema.NewDescription("Limit the number of returned rows"),
+				Name: "limit",
+				Type: &schema.TypeName{Name: "Int"},
+			},
+			&schema.InputValue{
+				Desc: schema.NewDescription("Offset the number of returned rows (Not efficient for pagination, please use a cursor for that)"),
+				Name: "offset",
+				Type: &schema.TypeName{Name: "Int"},
+			},
+		}...)
+
+		ft := &schema.List{OfType: &schema.NonNull{OfType: &schema.TypeName{Name: tname + "Output"}}}
+		desc := schema.NewDescription(fmt.Sprintf("Rows returned by the function %s", f.Name))
+
+		if f.Mutation {
+			in.mutation.Fields = append(in.mutation.Fields, &schema.Field{
+				Desc: desc,
+				Name: name,
+				Type: ft,
+				Args: args,
+			})
+			continue
+		}
+
+		in.query.Fields = append(in.query.Fields, &schema.Field{
+			Desc: desc,
+			Name: name,
+			Type: ft,
+			Args: args,
+		})
+
+		in.subscription.Fields = append(in.subscription.Fields, &schema.Field{
+			Desc: desc,
+			Name: name,
+			Type: ft,
+			Args: args,
+		})
+	}
+}
+
 // func (in *intro) addToTable(name, desc string, ti sdata.DBTable) {
 // 	k := name + "Output"
 // 	var ot *schema.Object = in.Types[k].(*schema.Object)
@@ -263,6 +382,10 @@ func (in *intro) addTable(name string, ti sdata.DBTable, singular bool) error {
 		Type: &schema.NonNull{OfType: &schema.List{OfType: &schema.NonNull{OfType: &schema.TypeName{Name: name + "Output"}}}},
 	})
 
+	if !singular {
+		in.addConnection(name)
+	}
+
 	// expressionType
 	exptName := name + "Expression"
 	expt := &schema.InputObject{
@@ -284,6 +407,8 @@ func (in *intro) addTable(name string, ti sdata.DBTable, singular bool) error {
 	}
 	in.Types[expt.Name] = expt
 
+	in.addOnConflict(name, exptName)
+
 	for _, col := range ti.Columns {
 		in.addColumn(name, ti, col, it, obt, expt, ot, singular)
 	}
@@ -291,6 +416,84 @@ func (in *intro) addTable(name string, ti sdata.DBTable, singular bool) error {
 	return nil
 }
 
+// addConnection adds the relay connection and edge types of a table
+func (in *intro) addConnection(name string) {
+	et := &schema.Object{
+		Name: name + "Edge",
+		Fields: schema.FieldList{
+			{
+				Name: "cursor",
+				Type: &schema.TypeName{Name: "Cursor"},
+			},
+			{
+				Name: "node",
+				Type: &schema.NonNull{OfType: &schema.TypeName{Name: name + "Output"}},
+			},
+		},
+	}
+	in.Types[et.Name] = et
+
+	ct := &schema.Object{
+		Name: name + "Connection",
+		Fields: schema.FieldList{
+			{
+				Name: "totalCount",
+				Type: &schema.NonNull{OfType: &schema.TypeName{Name: "Int"}},
+			},
+			{
+				Name: "pageInfo",
+				Type: &schema.NonNull{OfType: &schema.TypeName{Name: "PageInfo"}},
+			},
+			{
+				Name: "edges",
+				Type: &schema.NonNull{OfType: &schema.List{OfType: &schema.NonNull{OfType: &schema.TypeName{Name: et.Name}}}},
+			},
+		},
+	}
+	in.Types[ct.Name] = ct
+}
+
+// addOnConflict adds the type of the on_conflict argument of upserts
+func (in *intro) addOnConflict(name, exptName string) {
+	oct := &schema.InputObject{
+		Name: name + "OnConflict",
+		Fields: schema.InputValueList{
+			&schema.InputValue{
+				Desc: schema.NewDescription("Name of the unique constraint to check for a conflicting row"),
+				Name: "constraint",
+				Type: &schema.TypeName{Name: "String"},
+			},
+			&schema.InputValue{
+				Desc: schema.NewDescription("Columns to update on a conflict, defaults to all the columns in the data"),
+				Name: "update_columns",
+				Type: &schema.List{OfType: &schema.NonNull{OfType: &schema.TypeName{Name: "String"}}},
+			},
+			&schema.InputValue{
+				Desc: schema.NewDescription("Only update the conflicting row if it matches this filter"),
+				Name: "where",
+				Type: &schema.TypeName{Name: exptName},
+			},
+		},
+	}
+	in.Types[oct.Name] = oct
+}
+
+// connectionName returns the name of the field used to
+// query a table as a relay connection
+func (in *intro) connectionName(name string) string {
+	name += "_connection"
+	if in.gj.conf.EnableCamelcase {
+		name = util.ToCamel(name)
+	}
+	return name
+}
+
+// hasConnections returns false for databases that
+// don't support cursor pagination
+func (in *intro) hasConnections() bool {
+	return in.DBType() != "mssql" && in.DBType() != "sqlite"
+}
+
 func (in *intro) addRels(name string, ti sdata.DBTable) error {
 	relTables1, err := in.GetFirstDegree(ti.Schema, ti.Name)
 	if err != nil {
@@ -313,6 +516,12 @@ func (in *intro) addRels(name string, ti sdata.DBTable) error {
 			Name: k,
 			Type: &schema.TypeName{Name: k1},
 		})
+		if _, ok := in.Types[t.Name+"Connection"]; ok && in.hasConnections() {
+			ot.Fields = append(ot.Fields, &schema.Field{
+				Name: in.connectionName(k),
+				Type: &schema.NonNull{OfType: &schema.TypeName{Name: t.Name + "Connection"}},
+			})
+		}
 	}
 
 	relTables2, err := in.GetSecondDegree(ti.Schema, ti.Name)
@@ -546,6 +755,11 @@ func (in *intro) addArgs(
 	ti sdata.DBTable, col sdata.DBColumn,
 	it, obt, expt *schema.InputObject, ot *schema.Object, singular bool) {
 
+	// functions returning rows are added by addSetFuncs
+	if ti.Type == "function" {
+		return
+	}
+
 	otName := &schema.TypeName{Name: ot.Name}
 	itName := &schema.TypeName{Name: it.Name}
 
@@ -607,6 +821,14 @@ func (in *intro) addArgs(
 		}
 	}
 
+	if tc, ok := in.gj.conf.tmap[(ti.Schema + ti.Name)]; ok && tc.SoftDelete != "" {
+		args = append(args, &schema.InputValue{
+			Desc: schema.NewDescription("Include the soft deleted rows"),
+			Name: "with_deleted",
+			Type: &schema.TypeName{Name: "Boolean"},
+		})
+	}
+
 	if ti.PrimaryCol.Name != "" && singular {
 		colType, _ := getGQLType(ti.PrimaryCol, true)
 		args = append(args, &schema.InputValue{
@@ -646,6 +868,15 @@ func (in *intro) addArgs(
 		})
 	}
 
+	if !singular && in.hasConnections() {
+		in.query.Fields = append(in.query.Fields, &schema.Field{
+			Desc: schema.NewDescription("Relay style connection with edges, page info and a total count"),
+			Name: in.connectionName(name),
+			Type: &schema.NonNull{OfType: &schema.TypeName{Name: name + "Connection"}},
+			Args: args,
+		})
+	}
+
 	mutationArgs := append(args, schema.InputValueList{
 		&schema.InputValue{
 			Desc: schema.NewDescription(fmt.Sprintf("Insert row into table %s", name)),
@@ -662,6 +893,11 @@ func (in *intro) addArgs(
 			Name: "upsert",
 			Type: itName,
 		},
+		&schema.InputValue{
+			Desc: schema.NewDescription("Conflict target and columns to update for an upsert"),
+			Name: "on_conflict",
+			Type: &schema.TypeName{Name: name + "OnConflict"},
+		},
 		&schema.InputValue{
 			Desc: schema.NewDescription(fmt.Sprintf("Delete row from table %s", name)),
 			Name: "delete",
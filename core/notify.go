@@ -0,0 +1,307 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	_log "log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dosco/graphjin/core/internal/qcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+)
+
+const (
+	defaultNotifyChannel = "graphjin_changes"
+
+	// subscriptions are still polled at this interval when notifications
+	// are working as a safety net for missed notifications
+	notifyPollDuration = 60 * time.Second
+)
+
+var errNotifyUnsupported = errors.New("database driver does not support notifications")
+
+// subNotifier listens for postgres notifications on table changes and
+// wakes up the subscriptions that query the changed tables. The payload
+// of a notification is expected to be the changed table as 'schema.table'.
+type subNotifier struct {
+	db        *sql.DB
+	log       *_log.Logger
+	channel   string
+	install   bool
+	listening int32
+	disabled  int32
+	subs      sync.Map
+	tables    sync.Map
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func (g *GraphJin) initSubsNotify() error {
+	gj := g.Load().(*graphjin)
+
+	if !gj.conf.SubsNotify {
+		return nil
+	}
+
+	if gj.dbtype != "postgres" {
+		return errors.New("subscriptions: notifications are only supported with postgres")
+	}
+
+	// subscriptions fallback to polling
+	if _, ok := gj.db.Driver().(*stdlib.Driver); !ok {
+		gj.log.Printf(errSubs, "notify", errNotifyUnsupported)
+		return nil
+	}
+
+	n := &subNotifier{
+		db:      gj.db,
+		log:     gj.log,
+		channel: gj.conf.SubsNotifyChannel,
+		install: gj.conf.SubsNotifyTriggers,
+	}
+
+	if n.channel == "" {
+		n.channel = defaultNotifyChannel
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+
+	gj.notify = n
+	go n.listen()
+
+	return nil
+}
+
+// close stops listening for notifications, the subscriptions
+// fallback to polling
+func (n *subNotifier) close() {
+	n.cancel()
+}
+
+func (n *subNotifier) listen() {
+	for {
+		err := n.listenOnce()
+		atomic.StoreInt32(&n.listening, 0)
+
+		if n.ctx.Err() != nil {
+			return
+		}
+		if err == errNotifyUnsupported {
+			atomic.StoreInt32(&n.disabled, 1)
+			n.log.Printf(errSubs, "notify", err)
+			return
+		}
+		if err != nil {
+			n.log.Printf(errSubs, "notify", err)
+		}
+
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (n *subNotifier) listenOnce() error {
+	c := n.ctx
+
+	conn, err := n.db.Conn(c)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(c, `LISTEN "`+n.channel+`"`); err != nil {
+		return err
+	}
+
+	// the connection is still listening when done so driver.ErrBadConn is
+	// returned to have the pool discard it instead of reusing it
+	var lerr error
+
+	err = conn.Raw(func(dc interface{}) error {
+		pc, ok := dc.(interface{ Conn() *pgx.Conn })
+		if !ok {
+			lerr = errNotifyUnsupported
+			return driver.ErrBadConn
+		}
+		atomic.StoreInt32(&n.listening, 1)
+
+		for {
+			msg, err := pc.Conn().WaitForNotification(c)
+			if err != nil {
+				lerr = err
+				return driver.ErrBadConn
+			}
+			n.notify(msg.Payload)
+		}
+	})
+
+	if lerr != nil {
+		return lerr
+	}
+	return err
+}
+
+// notify wakes up all subscriptions that query the changed table, the
+// wake up is skipped if the subscription already has one pending
+func (n *subNotifier) notify(table string) {
+	n.subs.Range(func(k, v interface{}) bool {
+		for _, t := range v.([]string) {
+			if t != table {
+				continue
+			}
+			select {
+			case k.(*sub).notify <- struct{}{}:
+			default:
+			}
+			break
+		}
+		return true
+	})
+}
+
+func (n *subNotifier) isListening() bool {
+	return atomic.LoadInt32(&n.listening) == 1
+}
+
+// register adds the subscription to the list of ones to notify, false is
+// returned if the notification triggers could not be installed in which
+// case the subscription must fallback to polling.
+func (n *subNotifier) register(s *sub) bool {
+	if atomic.LoadInt32(&n.disabled) == 1 || n.ctx.Err() != nil {
+		return false
+	}
+
+	tables := queryTables(s.qc.st.qc)
+	if len(tables) == 0 {
+		return false
+	}
+
+	if n.install {
+		for _, t := range tables {
+			if err := n.installTrigger(t); err != nil {
+				n.log.Printf(errSubs, "notify", err)
+				return false
+			}
+		}
+	}
+
+	n.subs.Store(s, tables)
+	return true
+}
+
+func (n *subNotifier) unregister(s *sub) {
+	n.subs.Delete(s)
+}
+
+func (n *subNotifier) installTrigger(table string) error {
+	if _, ok := n.tables.Load(table); ok {
+		return nil
+	}
+
+	v := strings.SplitN(table, ".", 2)
+	t := `"` + v[0] + `"."` + v[1] + `"`
+
+	c := n.ctx
+
+	tx, err := n.db.BeginTx(c, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(c, notifyFuncStmt); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(c, `DROP TRIGGER IF EXISTS _graphjin_notify ON `+t)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(c, `CREATE TRIGGER _graphjin_notify AFTER INSERT OR UPDATE OR DELETE ON `+t+
+		` FOR EACH STATEMENT EXECUTE PROCEDURE _graphjin.notify_change('`+n.channel+`')`)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	n.tables.Store(table, struct{}{})
+	return nil
+}
+
+// queryTables returns the tables a query reads from
+func queryTables(qc *qcode.QCode) []string {
+	var tables []string
+	seen := make(map[string]struct{})
+
+	add := func(schema, name, _type string) {
+		if name == "" || _type != "" {
+			return
+		}
+		k := schema + "." + name
+		if _, ok := seen[k]; !ok {
+			seen[k] = struct{}{}
+			tables = append(tables, k)
+		}
+	}
+
+	addJoins := func(joins []qcode.Join) {
+		for _, j := range joins {
+			add(j.Rel.Left.Ti.Schema, j.Rel.Left.Ti.Name, j.Rel.Left.Ti.Type)
+			add(j.Rel.Right.Ti.Schema, j.Rel.Right.Ti.Name, j.Rel.Right.Ti.Type)
+		}
+	}
+
+	var st []*qcode.Exp
+
+	for _, sel := range qc.Selects {
+		add(sel.Ti.Schema, sel.Ti.Name, sel.Ti.Type)
+		addJoins(sel.Joins)
+
+		// the where clause includes the role filters and both
+		// can read from other tables using nested joins
+		if sel.Where.Exp != nil {
+			st = append(st, sel.Where.Exp)
+		}
+		if sel.Having.Exp != nil {
+			st = append(st, sel.Having.Exp)
+		}
+	}
+
+	for len(st) != 0 {
+		ex := st[len(st)-1]
+		st = st[:len(st)-1]
+
+		addJoins(ex.Joins)
+
+		for _, j := range ex.Joins {
+			if j.Filter != nil {
+				st = append(st, j.Filter)
+			}
+		}
+		st = append(st, ex.Children...)
+	}
+	return tables
+}
+
+const notifyFuncStmt = `
+CREATE SCHEMA IF NOT EXISTS _graphjin;
+
+CREATE OR REPLACE FUNCTION _graphjin.notify_change() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify(TG_ARGV[0], TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+`
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/dosco/graphjin/core/internal/qcode"
+	"github.com/dosco/graphjin/core/internal/sdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryTables(t *testing.T) {
+	dbs, err := sdata.NewDBSchema(sdata.GetTestDBInfo(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qcc, err := qcode.NewCompiler(dbs, qcode.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = qcc.AddRole("user", "public", "products", qcode.TRConfig{
+		Query: qcode.QueryConfig{
+			Filters: []string{`{ customer: { email: { eq: "x" } } }`},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qc, err := qcc.Compile([]byte(`query {
+		products(where: { comments: { body: { eq: "x" } } }) {
+			id
+		}
+	}`), nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tables only read by the where clause and the role filter
+	// are included
+	assert.ElementsMatch(t, []string{
+		"public.products",
+		"public.comments",
+		"public.purchases",
+		"public.customers",
+	}, queryTables(qc))
+}
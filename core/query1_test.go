@@ -602,6 +602,74 @@ func Example_queryWithFunctionsBlocked() {
 	// Output: functions blocked: price (anon)
 }
 
+func Example_queryWithInheritedRole() {
+	gql := `query {
+		products {
+			id
+			price
+		}
+	}`
+
+	conf := newConfig(&core.Config{DBType: dbType, DisableAllowList: true})
+	conf.Roles = []core.Role{{Name: "anon", Inherits: []string{"guest"}}}
+
+	err := conf.AddRoleTable("guest", "products", core.Query{
+		Columns: []string{"id", "name"},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := gj.GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Println(string(res.Data))
+	}
+	// Output: column blocked: price (anon)
+}
+
+func Example_queryWithSessionVariables() {
+	gql := `query {
+		products(limit: 2) {
+			id
+			name
+		}
+	}`
+
+	conf := newConfig(&core.Config{DBType: dbType, DisableAllowList: true})
+	conf.SessionVars = map[string]string{"org_id": "claims.org_id"}
+
+	err := conf.AddRoleTable("anon", "products", core.Query{
+		Columns: []string{"id", "name"},
+		Masks:   map[string]string{"name": "sql:current_setting('jwt.claims.org_id')"},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		panic(err)
+	}
+
+	claims := map[string]interface{}{"org_id": 5}
+	ctx := context.WithValue(context.Background(), core.UserClaimsKey, claims)
+
+	res, err := gj.GraphQL(ctx, gql, nil, nil)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Println(string(res.Data))
+	}
+	// Output: {"products": [{"id": 1, "name": "5"}, {"id": 2, "name": "5"}]}
+}
+
 func Example_queryWithFunctionsWithWhere() {
 	gql := `query {
 		products(where: { id: { lesser_or_equals: 100 } }) {
@@ -988,6 +1056,82 @@ func Example_queryWithCursorPagination() {
 	// Output: [{"name": "Product 100"}, {"name": "Product 99"}, {"name": "Product 98"}]
 }
 
+func Example_queryWithConnection() {
+	gql := `query {
+		products_connection(
+			where: { id: { lesser_or_equals: 100 } }
+			first: 3
+			after: $cursor
+			order_by: { price: desc }) {
+			totalCount
+			pageInfo {
+				hasNextPage
+				hasPreviousPage
+				endCursor
+			}
+			edges {
+				cursor
+				node {
+					name
+				}
+			}
+		}
+	}`
+
+	vars := json.RawMessage(`{"cursor": null}`)
+
+	conf := newConfig(&core.Config{DBType: dbType, DisableAllowList: true})
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := gj.GraphQL(context.Background(), gql, vars, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	type result struct {
+		Conn struct {
+			TotalCount int `json:"totalCount"`
+			PageInfo   struct {
+				HasNextPage     bool   `json:"hasNextPage"`
+				HasPreviousPage bool   `json:"hasPreviousPage"`
+				EndCursor       string `json:"endCursor"`
+			} `json:"pageInfo"`
+			Edges []struct {
+				Cursor string `json:"cursor"`
+				Node   struct {
+					Name string `json:"name"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"products_connection"`
+	}
+
+	var val result
+	if err := json.Unmarshal(res.Data, &val); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	c := val.Conn
+	if c.PageInfo.EndCursor == "" || c.Edges[len(c.Edges)-1].Cursor != c.PageInfo.EndCursor {
+		fmt.Println("cursor values missing")
+		return
+	}
+
+	fmt.Println(c.TotalCount, c.PageInfo.HasNextPage, c.PageInfo.HasPreviousPage)
+	for _, e := range c.Edges {
+		fmt.Println(e.Node.Name)
+	}
+	// Output:
+	// 100 true false
+	// Product 100
+	// Product 99
+	// Product 98
+}
+
 func Example_queryWithJsonColumn() {
 	gql := `query {
 		users(id: 1) {
@@ -1373,3 +1517,41 @@ func Example_queryWithCamelToSnakeCase() {
 	}
 	// Output: {"hotProducts": [{"products": {"id": 55}, "countryCode": "US", "countProductID": 1}]}
 }
+
+func Example_queryWithParseError() {
+	gql := `query { products(limit: 1) { id, name( } }`
+
+	conf := newConfig(&core.Config{DBType: dbType, DisableAllowList: true})
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		panic(err)
+	}
+
+	res, _ := gj.GraphQL(context.Background(), gql, nil, nil)
+	b, _ := json.Marshal(res.Errors)
+	fmt.Println(string(b))
+	// Output: [{"message":"OpQuery: expecting a label got: } (})","locations":[{"line":1,"column":40}],"extensions":{"code":"GRAPHQL_PARSE_FAILED"}}]
+}
+
+func Example_queryWithValidationError() {
+	gql := `query {
+		products(limit: 1) {
+			id
+			owner {
+				id
+				unknown_column
+			}
+		}
+	}`
+
+	conf := newConfig(&core.Config{DBType: dbType, DisableAllowList: true})
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		panic(err)
+	}
+
+	res, _ := gj.GraphQL(context.Background(), gql, nil, nil)
+	b, _ := json.Marshal(res.Errors)
+	fmt.Println(string(b))
+	// Output: [{"message":"column: 'users.unknown_column' not found","path":["products","owner"],"extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}}]
+}
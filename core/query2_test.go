@@ -130,6 +130,76 @@ func TestAllowList(t *testing.T) {
 	assert.Equal(t, exp, got, "should equal")
 }
 
+func TestAllowListBulkInsert(t *testing.T) {
+	gql := `mutation createUsers {
+		users(insert: $data) {
+			email
+		}
+	}`
+
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fs := afero.NewBasePathFs(afero.NewOsFs(), dir)
+
+	insert := func(gj *core.GraphJin, ids ...int) {
+		var data []map[string]interface{}
+		var exp []string
+
+		for _, id := range ids {
+			email := fmt.Sprintf("user%d@test.com", id)
+			data = append(data, map[string]interface{}{
+				"email":     email,
+				"full_name": fmt.Sprintf("User %d", id),
+			})
+			exp = append(exp, email)
+		}
+
+		vars, err := json.Marshal(map[string]interface{}{"data": data})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := context.WithValue(context.Background(), core.UserIDKey, 3)
+		res, err := gj.GraphQL(ctx, gql, vars, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got struct {
+			Users []struct{ Email string }
+		}
+		if err := json.Unmarshal(res.Data, &got); err != nil {
+			t.Fatal(err)
+		}
+
+		var emails []string
+		for _, u := range got.Users {
+			emails = append(emails, u.Email)
+		}
+		assert.ElementsMatch(t, exp, emails)
+	}
+
+	conf1 := newConfig(&core.Config{DBType: dbType})
+	gj1, err := core.NewGraphJin(conf1, db, core.OptionSetFS(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(gj1, 1020)
+
+	// the statement is compiled once for the first request
+	// and reused for the ones with a different number of rows
+	conf2 := newConfig(&core.Config{DBType: dbType, Production: true})
+	gj2, err := core.NewGraphJin(conf2, db, core.OptionSetFS(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(gj2, 1021, 1022)
+	insert(gj2, 1023, 1024, 1025)
+}
+
 func TestConfigReuse(t *testing.T) {
 	gql := `query {
 		products(id: 2) {
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sessionPrefix is the prefix of the database settings the session
+// variables are set as (eg. 'jwt.claims.org_id')
+const sessionPrefix = "jwt.claims."
+
+// Sources of the session variable values
+const (
+	sessionClaim  = "claims"
+	sessionHeader = "header"
+	sessionRole   = "role"
+	sessionUserID = "user_id"
+)
+
+// sessionVar is a database setting set from a value of the request
+type sessionVar struct {
+	name string
+	src  string
+	key  string
+}
+
+func (gj *graphjin) initSessionVars() error {
+	var vars []sessionVar
+
+	// the user id is set for the session on mysql and mssql
+	switch {
+	case !gj.conf.SetUserID:
+	case gj.dbtype == "postgres":
+		vars = append(vars, sessionVar{name: "user.id", src: sessionUserID})
+	case gj.dbtype != "mysql" && gj.dbtype != "mssql":
+		return fmt.Errorf("set_user_id: not supported with %s", gj.dbtype)
+	}
+
+	names := make([]string, 0, len(gj.conf.SessionVars))
+	for k := range gj.conf.SessionVars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		sv, err := newSessionVar(k, gj.conf.SessionVars[k])
+		if err != nil {
+			return fmt.Errorf("session_variables: %s: %w", k, err)
+		}
+		if sv.src == sessionHeader {
+			if _, ok := gj.conf.HeaderVars[sv.key]; !ok {
+				return fmt.Errorf("session_variables: %s: header variable not found: %s",
+					k, sv.key)
+			}
+		}
+		vars = append(vars, sv)
+	}
+
+	if len(vars) != 0 && gj.dbtype != "postgres" {
+		return errors.New("session_variables: only supported with postgres")
+	}
+
+	gj.sessVars = vars
+	return nil
+}
+
+func newSessionVar(name, src string) (sessionVar, error) {
+	sv := sessionVar{name: sessionPrefix + name}
+
+	for _, r := range name {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') &&
+			!(r >= '0' && r <= '9') {
+			return sv, fmt.Errorf("invalid character in name: %q", r)
+		}
+	}
+
+	if n := strings.IndexByte(src, '.'); n != -1 {
+		sv.src, sv.key = src[:n], src[n+1:]
+	} else {
+		sv.src = src
+	}
+
+	switch sv.src {
+	case sessionClaim, sessionHeader:
+		if sv.key == "" {
+			return sv, fmt.Errorf("%s name missing: %s", sv.src, src)
+		}
+	case sessionRole, sessionUserID:
+		if sv.key != "" {
+			return sv, fmt.Errorf("invalid source: %s", src)
+		}
+	default:
+		return sv, fmt.Errorf("invalid source: %s", src)
+	}
+	return sv, nil
+}
+
+// sessionValues returns the values of the session variables for the
+// request, missing values are set as an empty string.
+func (gj *graphjin) sessionValues(c context.Context, role string, rc *ReqConfig) []interface{} {
+	if len(gj.sessVars) == 0 {
+		return nil
+	}
+
+	claims, _ := c.Value(UserClaimsKey).(map[string]interface{})
+	values := make([]interface{}, len(gj.sessVars))
+
+	for i, sv := range gj.sessVars {
+		var v interface{}
+
+		switch sv.src {
+		case sessionClaim:
+			v = claims[sv.key]
+		case sessionHeader:
+			if rc != nil {
+				v = rc.Vars[sv.key]
+			}
+		case sessionRole:
+			v = role
+		case sessionUserID:
+			v = c.Value(UserIDKey)
+		}
+		values[i] = sessionValue(v)
+	}
+	return values
+}
+
+func sessionValue(v interface{}) string {
+	switch v1 := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v1
+	case int:
+		return strconv.Itoa(v1)
+	case func() string:
+		return v1()
+	}
+
+	if b, err := json.Marshal(v); err == nil {
+		return string(b)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// setSessionVars sets the session variables local to the transaction of
+// the connection so they are not seen by the next user of it.
+func (gj *graphjin) setSessionVars(c context.Context, conn dbConn, values []interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	args := make([]interface{}, 0, len(values)*2)
+
+	sb.WriteString(`SELECT `)
+	for i, sv := range gj.sessVars {
+		if i != 0 {
+			sb.WriteString(`, `)
+		}
+		n := len(args)
+		sb.WriteString(`set_config($` + strconv.Itoa(n+1) + `, $` + strconv.Itoa(n+2) + `, true)`)
+		args = append(args, sv.name, values[i])
+	}
+
+	_, err := conn.ExecContext(c, sb.String(), args...)
+	return err
+}
+
+// setSessionUserID sets the user id for the session of the connection, it's
+// used on the databases other than postgres where the session variables are
+// not supported. On mysql it's set as '@user_id' and on mssql as the 'user_id'
+// key of the session context, the id is always sent as a parameter.
+func (gj *graphjin) setSessionUserID(c context.Context, conn dbConn) error {
+	var err error
+
+	switch v := c.Value(UserIDKey).(type) {
+	case string, int:
+		switch gj.dbtype {
+		case "mysql":
+			_, err = conn.ExecContext(c, `SET @user_id = ?`, v)
+		case "mssql":
+			_, err = conn.ExecContext(c, `EXEC sp_set_session_context 'user_id', @p1`, v)
+		}
+	}
+	return err
+}
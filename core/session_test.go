@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+// recordConn records the statements executed on it
+type recordConn struct {
+	queries []string
+	args    [][]interface{}
+}
+
+func (c *recordConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.queries = append(c.queries, query)
+	c.args = append(c.args, args)
+	return nil, nil
+}
+
+func (c *recordConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestSetSessionUserID(t *testing.T) {
+	tests := []struct {
+		dbtype string
+		id     interface{}
+		query  string
+	}{
+		{"mysql", `1'; DROP TABLE users; --`, `SET @user_id = ?`},
+		{"mysql", 5, `SET @user_id = ?`},
+		{"mssql", "abc", `EXEC sp_set_session_context 'user_id', @p1`},
+		{"mysql", 5.5, ``},
+	}
+
+	for _, v := range tests {
+		gj := &graphjin{dbtype: v.dbtype}
+		c := context.WithValue(context.Background(), UserIDKey, v.id)
+
+		var conn recordConn
+		if err := gj.setSessionUserID(c, &conn); err != nil {
+			t.Fatal(err)
+		}
+
+		if v.query == "" {
+			if len(conn.queries) != 0 {
+				t.Errorf("%v: expected no query got %v", v.id, conn.queries)
+			}
+			continue
+		}
+
+		if len(conn.queries) != 1 || conn.queries[0] != v.query {
+			t.Errorf("%v: expected '%s' got %v", v.id, v.query, conn.queries)
+			continue
+		}
+
+		// the user id is only sent as a parameter
+		if !reflect.DeepEqual(conn.args[0], []interface{}{v.id}) {
+			t.Errorf("%v: unexpected args %v", v.id, conn.args[0])
+		}
+	}
+}
+
+func TestInitSessionVarsUserID(t *testing.T) {
+	for dbtype, ok := range map[string]bool{
+		"postgres": true,
+		"mysql":    true,
+		"mssql":    true,
+		"sqlite":   false,
+	} {
+		gj := &graphjin{dbtype: dbtype, conf: &Config{SetUserID: true}}
+
+		if err := gj.initSessionVars(); (err == nil) != ok {
+			t.Errorf("%s: unexpected error: %v", dbtype, err)
+		}
+	}
+}
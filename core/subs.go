@@ -17,6 +17,7 @@ import (
 
 	"github.com/avast/retry-go"
 	"github.com/dosco/graphjin/core/internal/qcode"
+	"github.com/dosco/graphjin/internal/jsn"
 	"github.com/rs/xid"
 )
 
@@ -26,14 +27,20 @@ const (
 )
 
 type sub struct {
+	key  string
 	name string
 	role string
 	qc   *queryComp
 	js   json.RawMessage
 
-	add  chan *Member
-	del  chan *Member
-	updt chan mmsg
+	// values of the session variables, members with different
+	// values cannot share a poll query
+	sv []interface{}
+
+	add    chan *Member
+	del    chan *Member
+	updt   chan mmsg
+	notify chan struct{}
 
 	mval
 	sync.Once
@@ -51,12 +58,17 @@ type minfo struct {
 	values []interface{}
 	// index of cursor value in the arguments array
 	cindx int
+	// send json patches instead of the full result
+	diff bool
+	// last result sent, only kept when diff is set
+	js json.RawMessage
 }
 
 type mmsg struct {
 	id     xid.ID
 	dh     [sha256.Size]byte
 	cursor string
+	js     json.RawMessage
 }
 
 type Member struct {
@@ -69,6 +81,7 @@ type Member struct {
 	mm     mmsg
 	// index of cursor value in the arguments array
 	cindx int
+	diff  bool
 }
 
 // GraphQLEx is the extended version of the Subscribe function allowing for request specific config.
@@ -111,12 +124,27 @@ func (g *GraphJin) Subscribe(
 		}
 	}
 
-	v, _ := gj.subs.LoadOrStore((name + role), &sub{
-		name: name,
-		role: role,
-		add:  make(chan *Member),
-		del:  make(chan *Member),
-		updt: make(chan mmsg, 10),
+	sv := gj.sessionValues(c, role, rc)
+	key := name + role
+
+	if len(sv) != 0 {
+		b, err := json.Marshal(sv)
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.Sum256(b)
+		key += base64.StdEncoding.EncodeToString(h[:])
+	}
+
+	v, _ := gj.subs.LoadOrStore(key, &sub{
+		key:    key,
+		name:   name,
+		role:   role,
+		sv:     sv,
+		add:    make(chan *Member),
+		del:    make(chan *Member),
+		updt:   make(chan mmsg, 10),
+		notify: make(chan struct{}, 1),
 	})
 	s := v.(*sub)
 
@@ -125,7 +153,7 @@ func (g *GraphJin) Subscribe(
 	})
 
 	if err != nil {
-		gj.subs.Delete((name + role))
+		gj.subs.Delete(key)
 		return nil, err
 	}
 
@@ -149,6 +177,7 @@ func (g *GraphJin) Subscribe(
 		vl:     args.values,
 		params: params,
 		cindx:  args.cindx,
+		diff:   s.qc.st.qc.Diff || (rc != nil && rc.Diff),
 	}
 
 	m.mm, err = gj.subFirstQuery(s, m, params)
@@ -189,7 +218,7 @@ func (gj *graphjin) newSub(c context.Context, s *sub, query string, vars json.Ra
 }
 
 func (gj *graphjin) subController(s *sub) {
-	defer gj.subs.Delete((s.name + s.role))
+	defer gj.subs.Delete(s.key)
 	var ps time.Duration
 
 	if gj.conf.SubsPollDuration < 5 {
@@ -198,7 +227,20 @@ func (gj *graphjin) subController(s *sub) {
 		ps = gj.conf.SubsPollDuration * time.Second
 	}
 
+	var notified bool
+	if gj.notify != nil {
+		if notified = gj.notify.register(s); notified {
+			defer gj.notify.unregister(s)
+		}
+	}
+
 	for {
+		// with notifications working polling is only a fallback
+		poll := ps
+		if notified && gj.notify.isListening() {
+			poll = notifyPollDuration
+		}
+
 		select {
 		case m := <-s.add:
 			if err := s.addMember(m); err != nil {
@@ -218,18 +260,22 @@ func (gj *graphjin) subController(s *sub) {
 				return
 			}
 
-		case <-time.After(ps):
+		case <-s.notify:
+			s.fanOutJobs(gj)
+
+		case <-time.After(poll):
 			s.fanOutJobs(gj)
 		}
 	}
 }
 
 func (s *sub) addMember(m *Member) error {
-	mi := minfo{cindx: m.cindx}
+	mi := minfo{cindx: m.cindx, diff: m.diff}
 	if mi.cindx != -1 {
 		mi.values = m.vl
 	}
 	mi.dh = m.mm.dh
+	mi.js = m.mm.js
 
 	// if cindex is not -1 then this query contains
 	// a cursor that must be updated with the new
@@ -281,6 +327,10 @@ func (s *sub) updateMember(msg mmsg) error {
 
 	s.mi[i].dh = msg.dh
 
+	if s.mi[i].diff {
+		s.mi[i].js = msg.js
+	}
+
 	// if cindex is not -1 then this query contains
 	// a cursor that must be updated with the new
 	// cursor value so subscriptions can paginate.
@@ -331,6 +381,7 @@ func (gj *graphjin) subCheckUpdates(s *sub, mv mval, start int) {
 	hasParams := len(s.qc.st.md.Params()) != 0
 
 	var rows *sql.Rows
+	var done func()
 	var err error
 
 	c := context.Background()
@@ -341,12 +392,21 @@ func (gj *graphjin) subCheckUpdates(s *sub, mv mval, start int) {
 	// of the function
 	err = retry.Do(
 		func() error {
+			var conn subConn
+
+			if done != nil {
+				done()
+			}
+			if conn, done, err = gj.subConn(c, s); err != nil {
+				return err
+			}
+
 			if hasParams {
 				//nolint: sqlclosecheck
-				rows, err = gj.db.QueryContext(c, s.qc.st.sql, renderJSONArray(mv.params[start:end]))
+				rows, err = conn.QueryContext(c, s.qc.st.sql, renderJSONArray(mv.params[start:end]))
 			} else {
 				//nolint: sqlclosecheck
-				rows, err = gj.db.QueryContext(c, s.qc.st.sql)
+				rows, err = conn.QueryContext(c, s.qc.st.sql)
 			}
 			return err
 		},
@@ -356,6 +416,10 @@ func (gj *graphjin) subCheckUpdates(s *sub, mv mval, start int) {
 		retry.LastErrorOnly(true),
 	)
 
+	if done != nil {
+		defer done()
+	}
+
 	if err != nil {
 		gj.log.Printf(errSubs, "query", err)
 		return
@@ -366,7 +430,8 @@ func (gj *graphjin) subCheckUpdates(s *sub, mv mval, start int) {
 
 	i := 0
 	for rows.Next() {
-		if err := rows.Scan(&js); err != nil {
+		// scanned as bytes since sqlite returns the json as text
+		if err := rows.Scan((*[]byte)(&js)); err != nil {
 			gj.log.Printf(errSubs, "scan", err)
 			return
 		}
@@ -385,6 +450,34 @@ func (gj *graphjin) subCheckUpdates(s *sub, mv mval, start int) {
 	}
 }
 
+// subConn is implemented by both *sql.DB and *sql.Tx
+type subConn interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// subConn returns what the queries of the subscription run on, with session
+// variables it's a transaction that has them set. The returned function must
+// be called once the query results are read.
+func (gj *graphjin) subConn(c context.Context, s *sub) (subConn, func(), error) {
+	if len(s.sv) == 0 {
+		return gj.db, func() {}, nil
+	}
+
+	tx, err := gj.db.BeginTx(c, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := gj.setSessionVars(c, tx, s.sv); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return nil, nil, err
+	}
+
+	// the queries only read so there is nothing to commit
+	return tx, func() { tx.Rollback() }, nil //nolint:errcheck
+}
+
 func (gj *graphjin) subFirstQuery(s *sub, m *Member, params json.RawMessage) (mmsg, error) {
 	c := context.Background()
 
@@ -402,15 +495,21 @@ func (gj *graphjin) subFirstQuery(s *sub, m *Member, params json.RawMessage) (mm
 	} else {
 		err = retry.Do(
 			func() error {
+				conn, done, err := gj.subConn(c, s)
+				if err != nil {
+					return err
+				}
+				defer done()
+
 				switch {
 				case params != nil:
-					err = gj.db.
+					err = conn.
 						QueryRowContext(c, s.qc.st.sql, renderJSONArray([]json.RawMessage{params})).
-						Scan(&js)
+						Scan((*[]byte)(&js))
 				default:
-					err = gj.db.
+					err = conn.
 						QueryRowContext(c, s.qc.st.sql).
-						Scan(&js)
+						Scan((*[]byte)(&js))
 				}
 				return err
 			},
@@ -426,8 +525,7 @@ func (gj *graphjin) subFirstQuery(s *sub, m *Member, params json.RawMessage) (mm
 	}
 
 	mm, err = gj.subNotifyMemberEx(s,
-		[32]byte{},
-		m.cindx,
+		minfo{cindx: m.cindx, diff: m.diff},
 		m.id,
 		m.Result, js, false)
 
@@ -436,8 +534,7 @@ func (gj *graphjin) subFirstQuery(s *sub, m *Member, params json.RawMessage) (mm
 
 func (gj *graphjin) subNotifyMember(s *sub, mv mval, j int, js json.RawMessage) {
 	_, err := gj.subNotifyMemberEx(s,
-		mv.mi[j].dh,
-		mv.mi[j].cindx,
+		mv.mi[j],
 		mv.ids[j],
 		mv.res[j], js, true)
 
@@ -447,11 +544,11 @@ func (gj *graphjin) subNotifyMember(s *sub, mv mval, j int, js json.RawMessage)
 }
 
 func (gj *graphjin) subNotifyMemberEx(s *sub,
-	dh [32]byte, cindx int, id xid.ID, rc chan *Result, js json.RawMessage, update bool) (mmsg, error) {
+	mi minfo, id xid.ID, rc chan *Result, js json.RawMessage, update bool) (mmsg, error) {
 	mm := mmsg{id: id}
 
 	mm.dh = sha256.Sum256(js)
-	if dh == mm.dh {
+	if mi.dh == mm.dh {
 		return mm, nil
 	}
 
@@ -462,22 +559,35 @@ func (gj *graphjin) subNotifyMemberEx(s *sub,
 
 	// we're expecting a cursor but the cursor was null
 	// so we skip this one.
-	if cindx != -1 && cur.value == "" {
+	if mi.cindx != -1 && cur.value == "" {
 		return mm, nil
 	}
 
 	mm.cursor = cur.value
 
-	if update {
-		s.updt <- mm
-	}
-
 	res := &Result{
 		op:   qcode.QTQuery,
 		name: s.name,
 		sql:  s.qc.st.sql,
 		role: s.qc.st.role.Name,
-		Data: cur.data,
+	}
+
+	// in diff mode the first result is sent in full and
+	// after that only a patch against the last result sent
+	if mi.diff {
+		mm.js = cur.data
+
+		if mi.js != nil {
+			var w bytes.Buffer
+			if err := jsn.Diff(&w, mi.js, cur.data); err != nil {
+				return mm, fmt.Errorf(errSubs, "diff", err)
+			}
+			res.Patch = w.Bytes()
+		}
+	}
+
+	if res.Patch == nil {
+		res.Data = cur.data
 	}
 
 	// if parameters exists then each response is unique
@@ -486,6 +596,13 @@ func (gj *graphjin) subNotifyMemberEx(s *sub,
 	select {
 	case rc <- res:
 	case <-time.After(250 * time.Millisecond):
+		// the last result sent stays the base of the next patch
+		// since this one was dropped
+		return mmsg{id: id}, nil
+	}
+
+	if update {
+		s.updt <- mm
 	}
 
 	return mm, nil
@@ -508,6 +625,41 @@ func renderSubWrap(st stmt, ct string) string {
 		}
 		w.WriteString(`)) AS _gj_jt`)
 
+	case "mssql":
+		w.WriteString(`WITH _gj_sub AS (SELECT * FROM OPENJSON(@p1) WITH (`)
+		for i, p := range st.md.Params() {
+			if i != 0 {
+				w.WriteString(`, `)
+			}
+			w.WriteString(`[` + p.Name + `] `)
+			w.WriteString(mssqlType(p.Type))
+			w.WriteString(` '$[`)
+			w.WriteString(strconv.FormatInt(int64(i), 10))
+			w.WriteString(`]'`)
+		}
+		w.WriteString(`)`)
+		w.WriteString(`) SELECT _gj_sub_data.__root FROM _gj_sub OUTER APPLY (`)
+		w.WriteString(st.sql)
+		w.WriteString(`) AS _gj_sub_data`)
+		return w.String()
+
+	case "sqlite":
+		// the sqlite root select has no from clause so it can be
+		// run as a correlated subquery once per subscriber
+		w.WriteString(`WITH _gj_sub AS (SELECT `)
+		for i, p := range st.md.Params() {
+			if i != 0 {
+				w.WriteString(`, `)
+			}
+			w.WriteString(`json_extract(value, '$[`)
+			w.WriteString(strconv.FormatInt(int64(i), 10))
+			w.WriteString(`]') AS "` + p.Name + `"`)
+		}
+		w.WriteString(` FROM json_each(?1)) SELECT (`)
+		w.WriteString(st.sql)
+		w.WriteString(`) AS __root FROM _gj_sub`)
+		return w.String()
+
 	default:
 		w.WriteString(`WITH _gj_sub AS (SELECT `)
 		for i, p := range st.md.Params() {
@@ -530,6 +682,19 @@ func renderSubWrap(st stmt, ct string) string {
 	return w.String()
 }
 
+// mssqlType maps the param types used by the compiler to
+// types that can be used in an sql server openjson schema
+func mssqlType(t string) string {
+	switch t {
+	case "text", "varchar", "nvarchar", "char", "nchar":
+		return "nvarchar(max)"
+	case "boolean":
+		return "bit"
+	default:
+		return t
+	}
+}
+
 func renderJSONArray(v []json.RawMessage) json.RawMessage {
 	w := bytes.Buffer{}
 	w.WriteRune('[')
@@ -3,6 +3,7 @@ package core_test
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"math/rand"
@@ -66,6 +67,115 @@ func Example_subscription() {
 	// {"users": {"id": 3, "email": "user3@test.com", "phone": "650-447-0008"}}
 }
 
+func Example_subscriptionWithNotify() {
+	gql := `subscription test {
+		users(id: $id) {
+			id
+			email
+			phone
+		}
+	}`
+
+	vars := json.RawMessage(`{ "id": 4 }`)
+
+	// a long poll duration to ensure the updates
+	// come from the change notifications
+	conf := newConfig(&core.Config{
+		DBType:             dbType,
+		DisableAllowList:   true,
+		SubsPollDuration:   600,
+		SubsNotify:         true,
+		SubsNotifyTriggers: true,
+	})
+
+	// notifications need the pgx driver
+	ndb, err := sql.Open("pgx", dbURL)
+	if err != nil {
+		panic(err)
+	}
+	defer ndb.Close()
+
+	gj, err := core.NewGraphJin(conf, ndb)
+	if err != nil {
+		panic(err)
+	}
+	defer gj.Close()
+
+	m, err := gj.Subscribe(context.Background(), gql, vars, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	msg := <-m.Result
+	fmt.Println(string(msg.Data))
+
+	// give the listener time to connect
+	time.Sleep(2 * time.Second)
+
+	for i := 0; i < 2; i++ {
+		q := fmt.Sprintf(`UPDATE users SET phone = '650-447-100%d' WHERE id = 4`, i)
+		if _, err := db.Exec(q); err != nil {
+			panic(err)
+		}
+
+		select {
+		case msg := <-m.Result:
+			fmt.Println(string(msg.Data))
+		case <-time.After(5 * time.Second):
+			fmt.Println("timeout")
+		}
+	}
+
+	// Output:
+	// {"users": {"id": 4, "email": "user4@test.com", "phone": null}}
+	// {"users": {"id": 4, "email": "user4@test.com", "phone": "650-447-1000"}}
+	// {"users": {"id": 4, "email": "user4@test.com", "phone": "650-447-1001"}}
+}
+
+func Example_subscriptionWithDiff() {
+	gql := `subscription test @diff {
+		users(id: $id) {
+			id
+			email
+			phone
+		}
+	}`
+
+	vars := json.RawMessage(`{ "id": 5 }`)
+
+	conf := newConfig(&core.Config{DBType: dbType, DisableAllowList: true, SubsPollDuration: 1})
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		panic(err)
+	}
+
+	m, err := gj.Subscribe(context.Background(), gql, vars, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for i := 0; i < 3; i++ {
+		msg := <-m.Result
+		if msg.Patch != nil {
+			fmt.Println(string(msg.Patch))
+		} else {
+			fmt.Println(string(msg.Data))
+		}
+
+		// update user phone in database to trigger subscription
+		q := fmt.Sprintf(`UPDATE users SET phone = '650-447-200%d' WHERE id = 5`, i)
+		if _, err := db.Exec(q); err != nil {
+			panic(err)
+		}
+	}
+
+	// Output:
+	// {"users": {"id": 5, "email": "user5@test.com", "phone": null}}
+	// [{"op":"replace","path":"/users/phone","value":"650-447-2000"}]
+	// [{"op":"replace","path":"/users/phone","value":"650-447-2001"}]
+}
+
 func Example_subscriptionWithCursor() {
 	// query to fetch existing chat messages
 	// gql1 := `query {
@@ -155,3 +155,53 @@ func Example_updateTableAndRelatedTable() {
 	}
 	// Output: {"users": {"products": [{"id": 90}], "full_name": "Updated user 90"}}
 }
+
+func Example_updateInvalidatesCachedResults() {
+	gql1 := `query {
+		products(id: $id) {
+			id
+			name
+		}
+	}`
+
+	gql2 := `mutation {
+		products(id: $id, update: $data) {
+			id
+		}
+	}`
+
+	conf := newConfig(&core.Config{DBType: dbType, DisableAllowList: true, CacheResults: true})
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 3)
+	vars := json.RawMessage(`{ "id": 80 }`)
+
+	for i := 0; i < 2; i++ {
+		// run the query twice, the second result is from the cache
+		res, err := gj.GraphQL(ctx, gql1, vars, nil)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			fmt.Println(string(res.Data))
+		}
+	}
+
+	vars2 := json.RawMessage(`{ "id": 80, "data": { "name": "Updated Product 80" } }`)
+	if _, err := gj.GraphQL(ctx, gql2, vars2, nil); err != nil {
+		fmt.Println(err)
+	}
+
+	res, err := gj.GraphQL(ctx, gql1, vars, nil)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Println(string(res.Data))
+	}
+	// Output:
+	// {"products": {"id": 80, "name": "Product 80"}}
+	// {"products": {"id": 80, "name": "Product 80"}}
+	// {"products": {"id": 80, "name": "Updated Product 80"}}
+}
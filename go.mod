@@ -20,6 +20,7 @@ require (
 	github.com/brianvoe/gofakeit/v6 v6.14.3
 	github.com/chirino/graphql v0.0.0-20210707003802-dfaf250c773e
 	github.com/containerd/containerd v1.5.9 // indirect
+	github.com/denisenkom/go-mssqldb v0.11.0
 	github.com/dop251/goja v0.0.0-20220110113543-261677941f3c
 	github.com/felixge/fgprof v0.9.3
 	github.com/fsnotify/fsnotify v1.5.1
@@ -49,6 +50,7 @@ require (
 	github.com/mitchellh/copystructure v1.2.0 // indirect
 	github.com/mitchellh/hashstructure/v2 v2.0.2
 	github.com/mitchellh/mapstructure v1.4.3
+	github.com/open-policy-agent/opa v0.34.2
 	github.com/opentracing/opentracing-go v1.2.0 // indirect
 	github.com/openzipkin/zipkin-go v0.3.0
 	github.com/orlangure/gnomock v0.19.0
@@ -72,6 +74,7 @@ require (
 	golang.org/x/time v0.0.0-20211116232009-f0f3c7e86c11
 	golang.org/x/tools v0.1.8
 	gopkg.in/yaml.v3 v3.0.1
+	modernc.org/sqlite v1.14.8
 )
 
 replace github.com/go-playground/validator/v10 v10.10.0 => ./core/internal/validator
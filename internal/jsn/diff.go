@@ -0,0 +1,296 @@
+package jsn
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Diff function writes a JSON Patch (RFC 6902) of the operations needed to turn
+// the JSON in a into the JSON in b. Arrays are compared by index unless all their
+// values are objects with a unique "id" in which case rows are matched by it
+// so a row added or removed does not change the ones after it.
+func Diff(w *bytes.Buffer, a, b []byte) error {
+	var ops []patchOp
+
+	if err := diffValue(&ops, "", a, b); err != nil {
+		return err
+	}
+
+	w.WriteByte('[')
+	for i, op := range ops {
+		if i != 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(`{"op":"`)
+		w.WriteString(op.op)
+		w.WriteString(`",`)
+		if op.from != "" {
+			w.WriteString(`"from":`)
+			if p, err := json.Marshal(op.from); err != nil {
+				return err
+			} else {
+				w.Write(p)
+			}
+			w.WriteByte(',')
+		}
+		w.WriteString(`"path":`)
+		if p, err := json.Marshal(op.path); err != nil {
+			return err
+		} else {
+			w.Write(p)
+		}
+		if op.value != nil {
+			w.WriteString(`,"value":`)
+			w.Write(op.value)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(']')
+
+	return nil
+}
+
+type patchOp struct {
+	op    string
+	path  string
+	value []byte
+	from  string
+}
+
+func diffValue(ops *[]patchOp, path string, a, b []byte) error {
+	a = bytes.TrimSpace(a)
+	b = bytes.TrimSpace(b)
+
+	if bytes.Equal(a, b) {
+		return nil
+	}
+
+	switch {
+	case isObject(a) && isObject(b):
+		return diffObject(ops, path, a, b)
+
+	case isArray(a) && isArray(b):
+		return diffArray(ops, path, a, b)
+	}
+
+	*ops = append(*ops, patchOp{op: "replace", path: path, value: b})
+	return nil
+}
+
+func diffObject(ops *[]patchOp, path string, a, b []byte) error {
+	ak, av, err := objectFields(a)
+	if err != nil {
+		return err
+	}
+
+	bk, bv, err := objectFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range ak {
+		if _, ok := bv[k]; !ok {
+			*ops = append(*ops, patchOp{op: "remove", path: path + "/" + escapeKey(k)})
+		}
+	}
+
+	for _, k := range bk {
+		p := path + "/" + escapeKey(k)
+
+		if v, ok := av[k]; ok {
+			if err := diffValue(ops, p, v, bv[k]); err != nil {
+				return err
+			}
+		} else {
+			*ops = append(*ops, patchOp{op: "add", path: p, value: bv[k]})
+		}
+	}
+
+	return nil
+}
+
+func diffArray(ops *[]patchOp, path string, a, b []byte) error {
+	var av, bv []json.RawMessage
+
+	if err := json.Unmarshal(a, &av); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, &bv); err != nil {
+		return err
+	}
+
+	n := len(av)
+	if len(bv) < n {
+		n = len(bv)
+	}
+
+	aids, ok1 := rowIDs(av)
+	bids, ok2 := rowIDs(bv)
+
+	if ok1 && ok2 {
+		for i := 0; i < n; i++ {
+			if aids[i] != bids[i] {
+				return diffRows(ops, path, av, bv, aids, bids)
+			}
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		p := path + "/" + strconv.Itoa(i)
+		if err := diffValue(ops, p, av[i], bv[i]); err != nil {
+			return err
+		}
+	}
+
+	// remove from the end so the indexes of the
+	// remaining values do not shift
+	for i := len(av) - 1; i >= n; i-- {
+		*ops = append(*ops, patchOp{op: "remove", path: path + "/" + strconv.Itoa(i)})
+	}
+
+	for i := n; i < len(bv); i++ {
+		*ops = append(*ops, patchOp{op: "add", path: path + "/-", value: bv[i]})
+	}
+
+	return nil
+}
+
+// diffRows diffs arrays of rows matching them by their id, rows missing
+// in b are removed and the rest are moved or added in the order of b.
+func diffRows(ops *[]patchOp, path string, av, bv []json.RawMessage, aids, bids []string) error {
+	bm := make(map[string]struct{}, len(bids))
+	for _, id := range bids {
+		bm[id] = struct{}{}
+	}
+
+	// remove from the end so the indexes of the
+	// remaining values do not shift
+	for i := len(av) - 1; i >= 0; i-- {
+		if _, ok := bm[aids[i]]; !ok {
+			*ops = append(*ops, patchOp{op: "remove", path: path + "/" + strconv.Itoa(i)})
+		}
+	}
+
+	// rows of a in the order they are in after the patch
+	// operations so far, -1 is a row added from b
+	var cur []int
+	for i := range av {
+		if _, ok := bm[aids[i]]; ok {
+			cur = append(cur, i)
+		}
+	}
+
+	for i, id := range bids {
+		p := path + "/" + strconv.Itoa(i)
+
+		j := i
+		for j < len(cur) && aids[cur[j]] != id {
+			j++
+		}
+
+		if j == len(cur) {
+			*ops = append(*ops, patchOp{op: "add", path: p, value: bv[i]})
+			cur = append(cur[:i], append([]int{-1}, cur[i:]...)...)
+			continue
+		}
+
+		k := cur[j]
+		if j != i {
+			*ops = append(*ops, patchOp{op: "move", path: p, from: path + "/" + strconv.Itoa(j)})
+			copy(cur[i+1:j+1], cur[i:j])
+			cur[i] = k
+		}
+
+		if err := diffValue(ops, p, av[k], bv[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rowIDs returns the ids of the values of an array if all of them are
+// objects with a unique scalar "id"
+func rowIDs(v []json.RawMessage) ([]string, bool) {
+	ids := make([]string, len(v))
+	seen := make(map[string]struct{}, len(v))
+
+	for i, r := range v {
+		r = bytes.TrimSpace(r)
+		if !isObject(r) {
+			return nil, false
+		}
+
+		_, m, err := objectFields(r)
+		if err != nil {
+			return nil, false
+		}
+
+		id := bytes.TrimSpace(m["id"])
+		if len(id) == 0 || isObject(id) || isArray(id) {
+			return nil, false
+		}
+
+		k := string(id)
+		if _, ok := seen[k]; ok {
+			return nil, false
+		}
+		seen[k] = struct{}{}
+		ids[i] = k
+	}
+
+	return ids, true
+}
+
+// objectFields returns the keys of an object in order and a map of their values
+func objectFields(b []byte) ([]string, map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+
+	// read open brace
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+
+	var keys []string
+	m := make(map[string]json.RawMessage)
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		k := t.(string)
+
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, nil, err
+		}
+
+		if _, ok := m[k]; !ok {
+			keys = append(keys, k)
+		}
+		m[k] = v
+	}
+
+	return keys, m, nil
+}
+
+func isObject(b []byte) bool {
+	return len(b) != 0 && b[0] == '{'
+}
+
+func isArray(b []byte) bool {
+	return len(b) != 0 && b[0] == '['
+}
+
+// escapeKey escapes a key for use in a JSON Pointer (RFC 6901)
+func escapeKey(k string) string {
+	if strings.ContainsAny(k, "~/") {
+		k = strings.ReplaceAll(k, "~", "~0")
+		k = strings.ReplaceAll(k, "/", "~1")
+	}
+	return k
+}
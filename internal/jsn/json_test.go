@@ -567,6 +567,52 @@ func TestClear(t *testing.T) {
 	}
 }
 
+func TestDiff(t *testing.T) {
+	var buf bytes.Buffer
+
+	a := `{"users": [{"id": 1, "name": "A", "tags": ["x"]}, {"id": 2, "name": "B"}, {"id": 3, "name": "C"}], "count": 3, "old": true}`
+	b := `{"users": [{"id": 1, "name": "A1", "tags": ["x", "y"]}, {"id": 2, "name": "B"}], "count": 2, "a/b": null}`
+
+	expected := `[{"op":"remove","path":"/old"},{"op":"replace","path":"/users/0/name","value":"A1"},{"op":"add","path":"/users/0/tags/-","value":"y"},{"op":"remove","path":"/users/2"},{"op":"replace","path":"/count","value":2},{"op":"add","path":"/a~1b","value":null}]`
+
+	if err := jsn.Diff(&buf, []byte(a), []byte(b)); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != expected {
+		t.Log(buf.String())
+		t.Error("Does not match expected json")
+	}
+
+	buf.Reset()
+
+	if err := jsn.Diff(&buf, []byte(a), []byte(a)); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != `[]` {
+		t.Errorf("expected an empty patch got: %s", buf.String())
+	}
+}
+
+func TestDiffRows(t *testing.T) {
+	var buf bytes.Buffer
+
+	a := `{"users": [{"id": 1, "name": "A"}, {"id": 2, "name": "B"}, {"id": 3, "name": "C"}]}`
+	b := `{"users": [{"id": 4, "name": "D"}, {"id": 1, "name": "A"}, {"id": 3, "name": "C"}, {"id": 2, "name": "B1"}]}`
+
+	expected := `[{"op":"add","path":"/users/0","value":{"id": 4, "name": "D"}},{"op":"move","from":"/users/3","path":"/users/2"},{"op":"replace","path":"/users/3/name","value":"B1"}]`
+
+	if err := jsn.Diff(&buf, []byte(a), []byte(b)); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != expected {
+		t.Log(buf.String())
+		t.Error("Does not match expected json")
+	}
+}
+
 func BenchmarkGet(b *testing.B) {
 	b.ReportAllocs()
 
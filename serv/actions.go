@@ -22,6 +22,12 @@ func newAction(s *Service, a *Action) (http.Handler, error) {
 	}
 
 	httpFn := func(w http.ResponseWriter, r *http.Request) {
+		s1 := s.Load().(*service)
+		if err := s1.authorizeAction(r, a); err != nil {
+			renderErr(w, err)
+			return
+		}
+
 		if err := fn(w, r); err != nil {
 			renderErr(w, err)
 		}
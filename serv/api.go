@@ -46,6 +46,7 @@ import (
 
 	"github.com/dosco/graphjin/core"
 	"github.com/dosco/graphjin/internal/util"
+	"github.com/dosco/graphjin/serv/internal/authorization"
 	"github.com/spf13/afero"
 	"go.uber.org/zap"
 )
@@ -79,6 +80,7 @@ type service struct {
 	prod         bool
 	deployActive bool
 	adminCount   int32
+	opa          authorization.OpaClient
 }
 
 type Option func(*service) error
@@ -170,6 +172,11 @@ func newGraphJinService(conf *Config, db *sql.DB, options ...Option) (*service,
 		return nil, err
 	}
 
+	// the policies are read from the deployed bundle when there is one
+	if s.opa, err = authorization.NewClient(s.fs); err != nil {
+		return nil, err
+	}
+
 	s.state = servStarted
 	return s, nil
 }
@@ -205,12 +212,13 @@ func (s *service) hotStart() error {
 	s.conf = bfs.conf
 	s.chash = bfs.conf.hash
 	s.conf.Serv.SecretsFile = secFile
+	s.fs = bfs.fs
 
 	if err := s.initConfig(); err != nil {
 		return err
 	}
 
-	s.gj, err = core.NewGraphJin(&s.conf.Core, s.db, core.OptionSetFS(bfs.fs))
+	s.gj, err = core.NewGraphJin(&s.conf.Core, s.db, core.OptionSetFS(s.fs))
 	return err
 }
 
@@ -230,6 +238,10 @@ func (s1 *Service) Deploy(conf *Config, options ...Option) error {
 	s.closeFn = os.closeFn
 
 	s1.Store(s)
+
+	if os.gj != nil {
+		os.gj.Close()
+	}
 	return nil
 }
 
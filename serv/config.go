@@ -95,6 +95,19 @@ type Serv struct {
 	// AuthFailBlock when enabled blocks requests with a 401 on auth failure
 	AuthFailBlock bool `mapstructure:"auth_fail_block"`
 
+	// MaxBatchSize is the most operations a batched request can have
+	// (default: 20)
+	MaxBatchSize int `mapstructure:"max_batch_size"`
+
+	// BatchWorkers is the most queries of a batched request that are
+	// run at the same time (default: 4)
+	BatchWorkers int `mapstructure:"batch_workers"`
+
+	// SubsPolicyRecheck is how often the @opa policy of a running subscription
+	// is evaluated again, subscriptions whose access was revoked are ended.
+	// Disabled when not set
+	SubsPolicyRecheck time.Duration `mapstructure:"subs_policy_recheck"`
+
 	// MigrationsPath is the path to the database migration files
 	MigrationsPath string `mapstructure:"migrations_path"`
 
@@ -206,6 +219,10 @@ type Action struct {
 	Name     string
 	SQL      string
 	AuthName string `mapstructure:"auth_name"`
+
+	// Policy is the OPA policy checked before the action is run
+	// in production, an action without one is not allowed
+	Policy string
 }
 
 // ReadInConfig function reads in the config file for the environment specified in the GO_ENV
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
@@ -50,6 +51,10 @@ func newDB(
 	switch conf.Core.DBType {
 	case "mysql":
 		dc, err = initMysql(conf, openDB, useTelemetry, fs)
+	case "mssql":
+		dc, err = initMssql(conf, openDB, useTelemetry, fs)
+	case "sqlite":
+		dc, err = initSqlite(conf, openDB, useTelemetry, fs)
 	default:
 		dc, err = initPostgres(conf, openDB, useTelemetry, fs)
 	}
@@ -232,6 +237,39 @@ func initMysql(conf *Config, openDB, useTelemetry bool, fs afero.Fs) (*dbConf, e
 	return &dbConf{"mysql", connString}, nil
 }
 
+func initMssql(conf *Config, openDB, useTelemetry bool, fs afero.Fs) (*dbConf, error) {
+	c := conf
+	u := &url.URL{
+		Scheme: "sqlserver",
+		User:   url.UserPassword(c.DB.User, c.DB.Password),
+		Host:   fmt.Sprintf("%s:%d", c.DB.Host, c.DB.Port),
+	}
+
+	q := url.Values{}
+	q.Add("app name", c.AppName)
+
+	if openDB {
+		q.Add("database", c.DB.DBName)
+	}
+	u.RawQuery = q.Encode()
+
+	return &dbConf{"sqlserver", u.String()}, nil
+}
+
+// initSqlite uses the database name as the path to the database file,
+// ':memory:' can be used for an in-memory database
+func initSqlite(conf *Config, openDB, useTelemetry bool, fs afero.Fs) (*dbConf, error) {
+	name := conf.DB.DBName
+	if name == "" {
+		return nil, errors.New("sqlite: database name (file path) is required")
+	}
+
+	q := url.Values{}
+	q.Add("_pragma", "foreign_keys(1)")
+
+	return &dbConf{"sqlite", "file:" + name + "?" + q.Encode()}, nil
+}
+
 func loadX509KeyPair(fs afero.Fs, certFile, keyFile string) (tls.Certificate, error) {
 	certPEMBlock, err := afero.ReadFile(fs, certFile)
 	if err != nil {
@@ -1,15 +1,16 @@
 package serv
 
 import (
+	"bytes"
 	"encoding/json"
 	"github.com/pkg/errors"
 
 	"fmt"
-	"github.com/dosco/graphjin/serv/internal/authorization"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/dosco/graphjin/core"
@@ -25,6 +26,9 @@ import (
 
 const (
 	maxReadBytes = 100000 // 100Kb
+
+	defaultMaxBatchSize = 20
+	defaultBatchWorkers = 4
 )
 
 var (
@@ -48,7 +52,7 @@ type gqlReq struct {
 }
 
 type errorResp struct {
-	Errors []string `json:"errors"`
+	Errors []core.Error `json:"errors"`
 }
 
 func apiV1Handler(s1 *Service) http.Handler {
@@ -121,6 +125,7 @@ func (s1 *Service) apiV1() http.Handler {
 		}
 
 		req := gqlReq{}
+		var batch []gqlReq
 
 		switch r.Method {
 		case "POST":
@@ -128,7 +133,11 @@ func (s1 *Service) apiV1() http.Handler {
 			b, err = ioutil.ReadAll(io.LimitReader(r.Body, maxReadBytes))
 			if err == nil {
 				defer r.Body.Close()
-				err = json.Unmarshal(b, &req)
+				if isBatch(b) {
+					err = json.Unmarshal(b, &batch)
+				} else {
+					err = json.Unmarshal(b, &req)
+				}
 			}
 
 		case "GET":
@@ -147,73 +156,19 @@ func (s1 *Service) apiV1() http.Handler {
 			return
 		}
 
-		rc := core.ReqConfig{Vars: make(map[string]interface{})}
-
-		for k, v := range s.conf.Core.HeaderVars {
-			rc.Vars[k] = func() string {
-				if v1, ok := r.Header[v]; ok {
-					return v1[0]
-				}
-				return ""
-			}
-		}
-
 		if s.conf.EnableTracing {
 			s.log.Infof("apiV1 time 2: %f", time.Since(start).Seconds())
 		}
 
-		if s.gj.IsProd() && !s.conf.DisableAllowList {
-			policy, err := s.gj.GetOpaPolicy(req.Query)
-			if err != nil {
-				renderErr(w, err)
-				return
-			}
-
-			opaClient, err := authorization.GetClient()
-			if err != nil {
-				s.log.Error(errors.Wrap(err, "failed to get OPA client"))
-				renderErr(w, errUnauthorized)
-				return
-			}
-
-			ip := ReadUserIP(r)
-			hasAccess, err := opaClient.HasAccess(policy, r.Header.Get("Authorization"), ip, req.Vars)
-			if err != nil {
-				s.log.Error(errors.Wrap(err, "failed evaluate OPA access"))
-				renderErr(w, errUnauthorized)
-				return
-			}
-
-			if !hasAccess {
-				renderErr(w, errUnauthorized)
-				return
-			}
-		}
-
-		if s.conf.EnableTracing {
-			s.log.Infof("apiV1 time 3: %f", time.Since(start).Seconds())
-		}
-
-		switch {
-		case s.gj.IsProd():
-			rc.APQKey = req.OpName
-		case req.apqEnabled():
-			rc.APQKey = (req.OpName + req.Ext.Persisted.Sha256Hash)
-		}
-
-		if req.OpName == "subscription" {
-			renderErr(w, errors.New("use websockets for subscriptions"))
+		if batch != nil {
+			s.execBatch(w, r, batch, start)
 			return
 		}
 
-		if s.conf.EnableTracing {
-			s.log.Infof("apiV1 time 4: %f", time.Since(start).Seconds())
-		}
-
-		res, err := s.gj.GraphQL(ct, req.Query, req.Vars, &rc)
-
-		if s.conf.EnableTracing {
-			s.log.Infof("apiV1 time 5: %f", time.Since(start).Seconds())
+		res, err := s.execGQL(r, req, start)
+		if res == nil {
+			renderErr(w, err)
+			return
 		}
 
 		if err == nil && r.Method == "GET" && res.Operation() == core.OpQuery {
@@ -273,6 +228,135 @@ func (s1 *Service) apiV1() http.Handler {
 	return http.HandlerFunc(h)
 }
 
+// execGQL runs a single graphql operation, a nil result is returned
+// when the request was rejected before the operation could be run
+func (s *service) execGQL(r *http.Request, req gqlReq, start time.Time) (*core.Result, error) {
+	ct := r.Context()
+	rc := core.ReqConfig{Vars: make(map[string]interface{})}
+
+	for k, v := range s.conf.Core.HeaderVars {
+		rc.Vars[k] = func() string {
+			if v1, ok := r.Header[v]; ok {
+				return v1[0]
+			}
+			return ""
+		}
+	}
+
+	if err := s.authorizeQuery(r, req.Query, req.Vars); err != nil {
+		return nil, err
+	}
+
+	if s.conf.EnableTracing {
+		s.log.Infof("apiV1 time 3: %f", time.Since(start).Seconds())
+	}
+
+	switch {
+	case s.gj.IsProd():
+		rc.APQKey = req.OpName
+	case req.apqEnabled():
+		rc.APQKey = (req.OpName + req.Ext.Persisted.Sha256Hash)
+	}
+
+	if req.OpName == "subscription" {
+		return nil, errors.New("use websockets for subscriptions")
+	}
+
+	if s.conf.EnableTracing {
+		s.log.Infof("apiV1 time 4: %f", time.Since(start).Seconds())
+	}
+
+	res, err := s.gj.GraphQL(ct, req.Query, req.Vars, &rc)
+
+	if s.conf.EnableTracing {
+		s.log.Infof("apiV1 time 5: %f", time.Since(start).Seconds())
+	}
+
+	return res, err
+}
+
+// execBatch runs a batch of operations sent as a json array, queries are
+// run in parallel by a limited number of workers while every other operation
+// is run alone once the ones sent before it are done. The results are returned
+// as an array in the same order.
+func (s *service) execBatch(w http.ResponseWriter, r *http.Request, batch []gqlReq, start time.Time) {
+	max := s.conf.MaxBatchSize
+	if max <= 0 {
+		max = defaultMaxBatchSize
+	}
+
+	if len(batch) > max {
+		renderErr(w, fmt.Errorf("batch of %d operations is over the limit of %d", len(batch), max))
+		return
+	}
+
+	workers := s.conf.BatchWorkers
+	if workers <= 0 {
+		workers = defaultBatchWorkers
+	}
+
+	results := make([]interface{}, len(batch))
+
+	runBatch(batch, workers, func(i int) {
+		res, err := s.execGQL(r, batch[i], start)
+		if res == nil {
+			results[i] = errorResp{[]core.Error{newError(err)}}
+			return
+		}
+		results[i] = res
+
+		if s.logLevel >= logLevelInfo {
+			s.reqLog(res, time.Since(start).Milliseconds(), err)
+		}
+	})
+
+	if s.conf.ServerTiming {
+		b := []byte("DB;dur=")
+		b = strconv.AppendInt(b, time.Since(start).Milliseconds(), 10)
+		w.Header().Set("Server-Timing", string(b))
+	}
+
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		renderErr(w, err)
+		return
+	}
+
+	if s.conf.telemetryEnabled() {
+		ochttp.SetRoute(r.Context(), apiRoute)
+	}
+}
+
+// runBatch calls exec for every operation of the batch, queries are run
+// by up to the given number of workers at a time and the other operations
+// are run alone after all the ones before them are done.
+func runBatch(batch []gqlReq, workers int, exec func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+
+	for i := range batch {
+		// mutations and operations sent without a query (eg. persisted
+		// queries) cannot be told apart so both are run alone
+		if op, _ := core.Operation(batch[i].Query); op != core.OpQuery {
+			wg.Wait()
+			exec(i)
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			exec(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// isBatch returns true if the request body is a json array
+func isBatch(b []byte) bool {
+	b = bytes.TrimLeft(b, " \t\r\n")
+	return len(b) != 0 && b[0] == '['
+}
+
 func (s *service) reqLog(res *core.Result, resTimeMs int64, err error) {
 	fields := []zapcore.Field{
 		zap.String("op", res.OperationName()),
@@ -309,8 +393,16 @@ func renderErr(w http.ResponseWriter, err error) {
 		w.WriteHeader(http.StatusUnauthorized)
 	}
 
-	err1 := json.NewEncoder(w).Encode(errorResp{[]string{err.Error()}})
+	err1 := json.NewEncoder(w).Encode(errorResp{[]core.Error{newError(err)}})
 	if err1 != nil {
 		panic(fmt.Errorf("%s: %w", err, err1))
 	}
 }
+
+func newError(err error) core.Error {
+	e := core.NewError(err)
+	if err == errUnauthorized {
+		e.Extensions.Code = core.ErrCodeUnauthenticated
+	}
+	return e
+}
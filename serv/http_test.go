@@ -0,0 +1,84 @@
+package serv
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBatchWorkers(t *testing.T) {
+	batch := make([]gqlReq, 10)
+	for i := range batch {
+		batch[i].Query = `query { products { id } }`
+	}
+
+	var running, max, count int32
+
+	runBatch(batch, 3, func(i int) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&count, 1)
+	})
+
+	if count != 10 {
+		t.Errorf("expected 10 operations to run got %d", count)
+	}
+	if max > 3 {
+		t.Errorf("expected at most 3 operations at a time got %d", max)
+	}
+}
+
+func TestRunBatchOrder(t *testing.T) {
+	batch := []gqlReq{
+		{Query: `query { products { id } }`},
+		{Query: `{ users { id } }`},
+		{Query: `mutation { products(insert: $data) { id } }`},
+		{Query: `query { products { id } }`},
+		{OpName: "getProducts"},
+		{Query: `query { users { id } }`},
+	}
+
+	var mu sync.Mutex
+	var events []string
+
+	runBatch(batch, 4, func(i int) {
+		mu.Lock()
+		events = append(events, "start")
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		events = append(events, "end")
+		mu.Unlock()
+	})
+
+	// a mutation or an operation without a query starts after all
+	// the ones before it end and ends before the next one starts
+	exp := "start start end end start end start end start end start end"
+	if got := strings.Join(events, " "); got != exp {
+		t.Errorf("expected '%s' got '%s'", exp, got)
+	}
+}
+
+func TestExecBatchLimit(t *testing.T) {
+	s := &service{conf: &Config{Serv: Serv{MaxBatchSize: 2}}}
+	batch := make([]gqlReq, 3)
+
+	w := httptest.NewRecorder()
+	s.execBatch(w, httptest.NewRequest("POST", "/api/v1/graphql", nil), batch, time.Now())
+
+	if body := w.Body.String(); !strings.Contains(body, "over the limit of 2") {
+		t.Errorf("expected the batch to be rejected: %s", body)
+	}
+}
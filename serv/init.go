@@ -9,6 +9,12 @@ import (
 
 	// mysql drivers
 	_ "github.com/go-sql-driver/mysql"
+
+	// mssql drivers
+	_ "github.com/denisenkom/go-mssqldb"
+
+	// sqlite drivers
+	_ "modernc.org/sqlite"
 	"github.com/spf13/afero"
 )
 
@@ -7,6 +7,7 @@ import (
 
 	jwt "github.com/golang-jwt/jwt"
 
+	"github.com/dosco/graphjin/core"
 	"github.com/dosco/graphjin/serv/internal/auth/provider"
 )
 
@@ -62,8 +63,11 @@ func JwtHandler(ac *Auth, next http.Handler) (handlerFunc, error) {
 				return nil, fmt.Errorf("invalid iss claim")
 			}
 
-			ctx, err = jwtProvider.SetContextValues(ctx, claims)
-			return ctx, err
+			if ctx, err = jwtProvider.SetContextValues(ctx, claims); err != nil {
+				return nil, err
+			}
+			ctx = context.WithValue(ctx, core.UserClaimsKey, map[string]interface{}(claims))
+			return ctx, nil
 		}
 		return nil, fmt.Errorf("invalid claims")
 	}, nil
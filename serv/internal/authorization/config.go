@@ -9,6 +9,11 @@ import (
 type Config struct {
 	Mocked bool   `default:"false"`
 	Port   string `default:"8181"`
+
+	// Embedded evaluates the .rego files in the Policies folder
+	// of the config path instead of calling the OPA server
+	Embedded bool   `default:"false"`
+	Policies string `default:"policies"`
 }
 
 func NewEnvConfig() (*Config, error) {
@@ -1,23 +1,32 @@
 package authorization
 
-var (
-	client OpaClient
-	config *Config
+import (
+	"errors"
+	"path"
+
+	"github.com/spf13/afero"
 )
 
-func GetClient() (OpaClient, error) {
-	if client == nil {
-		var err error
-		config, err = NewEnvConfig()
-		if err != nil {
-			return nil, err
-		}
-		if config.Mocked {
-			client = NewOPAClientMock()
-		} else {
-			client = NewOPAClient(config)
-		}
+// NewClient returns the OPA client set in the environment. The embedded
+// client loads the policies from the config path fs so a new client has
+// to be created when the config is reloaded or hot-deployed.
+func NewClient(fs afero.Fs) (OpaClient, error) {
+	config, err := NewEnvConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	return client, nil
+	switch {
+	case config.Mocked:
+		return NewOPAClientMock(), nil
+
+	case config.Embedded:
+		if fs == nil {
+			return nil, errors.New("a config path is required for embedded OPA policies")
+		}
+		return NewOPAClientRego(fs, path.Join("/", config.Policies))
+
+	default:
+		return NewOPAClient(config), nil
+	}
 }
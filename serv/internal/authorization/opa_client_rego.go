@@ -0,0 +1,106 @@
+package authorization
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// OpaClientRego evaluates the policies in-process. The .rego files are
+// compiled once when the client is created and the query for each policy
+// is prepared the first time it's used.
+type OpaClientRego struct {
+	compiler *ast.Compiler
+	queries  sync.Map
+}
+
+// NewOPAClientRego compiles all the .rego files found under the dir
+func NewOPAClientRego(fs afero.Fs, dir string) (*OpaClientRego, error) {
+	modules := make(map[string]string)
+
+	err := afero.Walk(fs, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".rego" {
+			return nil
+		}
+		b, err := afero.ReadFile(fs, path)
+		if err != nil {
+			return err
+		}
+		modules[path] = string(b)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read OPA policies")
+	}
+
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("no OPA policies found in '%s'", dir)
+	}
+
+	compiler, err := ast.CompileModules(modules)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compile OPA policies")
+	}
+
+	return &OpaClientRego{compiler: compiler}, nil
+}
+
+// policyQuery returns the query for a policy path like the one used
+// with the OPA server, eg. 'graphjin/allow' is 'data.graphjin.allow'
+func policyQuery(policy string) string {
+	return "data." + strings.ReplaceAll(strings.Trim(policy, "/"), "/", ".")
+}
+
+func (s *OpaClientRego) prepare(policy string) (rego.PreparedEvalQuery, error) {
+	if v, ok := s.queries.Load(policy); ok {
+		return v.(rego.PreparedEvalQuery), nil
+	}
+
+	q, err := rego.New(
+		rego.Compiler(s.compiler),
+		rego.Query(policyQuery(policy)),
+	).PrepareForEval(context.Background())
+	if err != nil {
+		return q, err
+	}
+
+	s.queries.Store(policy, q)
+	return q, nil
+}
+
+func (s *OpaClientRego) HasAccess(policy string, jwt string, ipAddress string, args interface{}) (bool, error) {
+	q, err := s.prepare(policy)
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to prepare OPA policy")
+	}
+
+	req := HasAccessRequest{
+		Token:     parseToken(jwt),
+		Args:      args,
+		IPAddress: ipAddress,
+	}
+
+	rs, err := q.Eval(context.Background(), rego.EvalInput(req))
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to evaluate OPA policy")
+	}
+
+	// an undefined policy result denies access
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return false, nil
+	}
+
+	res, ok := rs[0].Expressions[0].Value.(bool)
+	return ok && res, nil
+}
@@ -0,0 +1,61 @@
+package authorization
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+const testPolicy = `package graphjin
+
+default allow = false
+
+allow {
+	input.ipAddress == "10.0.0.1"
+	input.args.id == 5
+	input.token == "abc"
+}
+`
+
+func TestOpaClientRego(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "/policies/graphjin.rego", []byte(testPolicy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewOPAClientRego(fs, "/policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := map[string]interface{}{"id": 5}
+
+	ok, err := c.HasAccess("graphjin/allow", "Bearer abc", "10.0.0.1", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("expected access to be allowed")
+	}
+
+	ok, err = c.HasAccess("graphjin/allow", "Bearer abc", "10.0.0.2", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected access to be denied")
+	}
+
+	// undefined policies deny access
+	ok, err = c.HasAccess("graphjin/missing", "Bearer abc", "10.0.0.1", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected access to be denied for an undefined policy")
+	}
+
+	if _, err := NewOPAClientRego(fs, "/other"); err == nil {
+		t.Error("expected an error when no policies are found")
+	}
+}
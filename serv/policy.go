@@ -0,0 +1,77 @@
+package serv
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// policyEnabled is true when the @opa policies are enforced, this is
+// only in production with the allow list enabled
+func (s *service) policyEnabled() bool {
+	return s.gj.IsProd() && !s.conf.DisableAllowList
+}
+
+// policyAuth is the auth token and ip address a policy is evaluated with
+type policyAuth struct {
+	token string
+	ip    string
+}
+
+func newPolicyAuth(r *http.Request) policyAuth {
+	return policyAuth{token: r.Header.Get("Authorization"), ip: ReadUserIP(r)}
+}
+
+// authorizeQuery checks the @opa policy of the query is met by the
+// request. It's used by all the ways a query can be run (http, batched
+// requests and websockets) and in production a query without a policy
+// is not allowed.
+func (s *service) authorizeQuery(r *http.Request, query string, vars interface{}) error {
+	return s.authorizeQueryAs(newPolicyAuth(r), query, vars)
+}
+
+// authorizeQueryAs works like authorizeQuery but with the auth given
+// instead of the one of a request
+func (s *service) authorizeQueryAs(a policyAuth, query string, vars interface{}) error {
+	if !s.policyEnabled() {
+		return nil
+	}
+
+	policy, err := s.gj.GetOpaPolicy(query)
+	if err != nil {
+		return err
+	}
+	return s.checkPolicyAs(a, policy, vars)
+}
+
+// authorizeAction checks the policy of an action is met by the request,
+// like with queries an action without a policy is not allowed in production
+func (s *service) authorizeAction(r *http.Request, a *Action) error {
+	if !s.policyEnabled() {
+		return nil
+	}
+
+	if a.Policy == "" {
+		return errUnauthorized
+	}
+	return s.checkPolicy(r, a.Policy, nil)
+}
+
+// checkPolicy evaluates the policy with the auth token, ip address and
+// variables of the request
+func (s *service) checkPolicy(r *http.Request, policy string, vars interface{}) error {
+	return s.checkPolicyAs(newPolicyAuth(r), policy, vars)
+}
+
+func (s *service) checkPolicyAs(a policyAuth, policy string, vars interface{}) error {
+	hasAccess, err := s.opa.HasAccess(policy, a.token, a.ip, vars)
+	if err != nil {
+		s.log.Error(errors.Wrap(err, "failed evaluate OPA access"))
+		return errUnauthorized
+	}
+
+	if !hasAccess {
+		return errUnauthorized
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package serv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dosco/graphjin/core"
+	"github.com/dosco/graphjin/serv/internal/authorization"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/afero"
+	"go.uber.org/zap"
+)
+
+// access is allowed until the time in the variables, this lets a test
+// revoke the access of a running subscription
+const testPolicies = `package graphjin
+
+default allow = false
+
+allow {
+	time.now_ns() < input.args.until
+}
+
+default allow_actions = false
+
+allow_actions {
+	input.token == "abc"
+}
+`
+
+const (
+	policyQuery = `query getProducts @opa(policy: "graphjin/allow") {
+		products(id: 1) {
+			id
+		}
+	}`
+
+	policySub = `subscription getProducts @opa(policy: "graphjin/allow") {
+		products(id: 1) {
+			id
+		}
+	}`
+)
+
+// newPolicyService returns a production service with the allow list
+// set to the test query and subscription. The @opa policies are run
+// in-process by the rego client.
+func newPolicyService(t *testing.T) *service {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// a single connection since every new connection gets its own
+	// in-memory database
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE products (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO products (id, name) VALUES (1, 'Product 1');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "/policies/graphjin.rego", []byte(testPolicies), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	vars := untilVars(time.Hour)
+
+	// the allow list is saved in development mode
+	dev, err := core.NewGraphJin(&core.Config{DBType: "sqlite", DBSchemaPollDuration: -1},
+		db, core.OptionSetFS(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dev.GraphQL(context.Background(), policyQuery, vars, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := dev.Subscribe(context.Background(), policySub, vars, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Unsubscribe()
+
+	conf := &Config{}
+	conf.DBType = "sqlite"
+	conf.Core.Production = true
+	conf.DBSchemaPollDuration = -1
+	conf.SubsPolicyRecheck = 50 * time.Millisecond
+
+	gj, err := core.NewGraphJin(&conf.Core, db, core.OptionSetFS(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opa, err := authorization.NewOPAClientRego(fs, "/policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &service{
+		log:  zap.NewNop().Sugar(),
+		zlog: zap.NewNop(),
+		conf: conf,
+		db:   db,
+		gj:   gj,
+		opa:  opa,
+	}
+}
+
+// untilVars returns the variables allowing access for the given duration,
+// access is denied when it's negative
+func untilVars(d time.Duration) json.RawMessage {
+	return json.RawMessage(fmt.Sprintf(`{ "until": %d }`, time.Now().Add(d).UnixNano()))
+}
+
+func TestPolicyBatch(t *testing.T) {
+	s := newPolicyService(t)
+
+	batch := []gqlReq{
+		{OpName: "getProducts", Query: policyQuery, Vars: untilVars(time.Hour)},
+		{OpName: "getProducts", Query: policyQuery, Vars: untilVars(-time.Hour)},
+		{OpName: "getProducts", Query: policyQuery, Vars: untilVars(time.Hour)},
+	}
+
+	r := httptest.NewRequest("POST", "/api/v1/graphql", nil)
+	w := httptest.NewRecorder()
+	s.execBatch(w, r, batch, time.Now())
+
+	var res []struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []core.Error    `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 3 {
+		t.Fatalf("expected 3 results got %d", len(res))
+	}
+
+	for _, i := range []int{0, 2} {
+		if len(res[i].Errors) != 0 || string(res[i].Data) != `{"products":{"id":1}}` {
+			t.Errorf("expected result %d to be allowed: %+v", i, res[i])
+		}
+	}
+
+	if len(res[1].Errors) == 0 || res[1].Errors[0].Message != errUnauthorized.Error() ||
+		len(res[1].Data) != 0 {
+		t.Errorf("expected result 1 to be denied: %+v", res[1])
+	}
+}
+
+func TestPolicyAction(t *testing.T) {
+	s := newPolicyService(t)
+
+	s1 := &Service{}
+	s1.Store(s)
+
+	actions := []struct {
+		policy string
+		token  string
+		status int
+	}{
+		{"graphjin/allow_actions", "Bearer abc", http.StatusOK},
+		{"graphjin/allow_actions", "Bearer xyz", http.StatusUnauthorized},
+		// like queries actions without a policy are not allowed
+		{"", "Bearer abc", http.StatusUnauthorized},
+	}
+
+	for _, v := range actions {
+		h, err := newAction(s1, &Action{
+			Name:   "rename",
+			SQL:    `UPDATE products SET name = 'Renamed' WHERE id = 1`,
+			Policy: v.policy,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest("POST", "/api/v1/actions/rename", nil)
+		r.Header.Set("Authorization", v.token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != v.status {
+			t.Errorf("policy '%s' token '%s': expected status %d got %d",
+				v.policy, v.token, v.status, w.Code)
+		}
+	}
+}
+
+func TestPolicySubscription(t *testing.T) {
+	s := newPolicyService(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(s.apiV1Ws))
+	defer srv.Close()
+
+	subscribe := func(vars json.RawMessage) *websocket.Conn {
+		url := "ws" + strings.TrimPrefix(srv.URL, "http")
+		c, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.WriteJSON(wsReq{ID: "1", Type: "connection_init"}); err != nil {
+			t.Fatal(err)
+		}
+		var ack wsReq
+		if err := c.ReadJSON(&ack); err != nil || ack.Type != "connection_ack" {
+			t.Fatalf("expected a connection ack: %v %+v", err, ack)
+		}
+
+		p, err := json.Marshal(gqlReq{Query: policySub, Vars: vars})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.WriteJSON(wsReq{ID: "2", Type: "start", Payload: p}); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// expectDenied reads the messages till the unauthorized error
+	expectDenied := func(c *websocket.Conn) {
+		for {
+			var res wsRes
+			if err := c.ReadJSON(&res); err != nil {
+				t.Fatalf("expected an unauthorized error: %v", err)
+			}
+			if res.Type != "error" {
+				continue
+			}
+			if len(res.Payload.Errors) == 0 ||
+				res.Payload.Errors[0].Message != errUnauthorized.Error() {
+				t.Fatalf("expected an unauthorized error: %+v", res)
+			}
+			return
+		}
+	}
+
+	t.Run("denied on start", func(t *testing.T) {
+		c := subscribe(untilVars(-time.Hour))
+		defer c.Close()
+		expectDenied(c)
+	})
+
+	t.Run("ended by the recheck", func(t *testing.T) {
+		c := subscribe(untilVars(300 * time.Millisecond))
+		defer c.Close()
+		expectDenied(c)
+
+		// the subscription is ended with a close message
+		_, _, err := c.ReadMessage()
+		if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+			t.Errorf("expected a policy violation close: %v", err)
+		}
+	})
+}
@@ -28,6 +28,7 @@ type wsRes struct {
 
 type Payload struct {
 	Data   json.RawMessage `json:"data,omitempty"`
+	Patch  json.RawMessage `json:"patch,omitempty"`
 	Errors []core.Error    `json:"errors,omitempty"`
 }
 
@@ -70,7 +71,8 @@ func (s *service) apiV1Ws(w http.ResponseWriter, r *http.Request) {
 
 	var v wsReq
 
-	done := make(chan bool)
+	// buffered as the writer might have already returned
+	done := make(chan bool, 1)
 	for {
 		var b []byte
 
@@ -91,7 +93,7 @@ func (s *service) apiV1Ws(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 
-		if ready, err = s.subSwitch(ct, c, v, done); err != nil {
+		if ready, err = s.subSwitch(ct, r, c, v, done); err != nil {
 			if err1 := sendError(ct, c, err, v.ID); err1 != nil {
 				err = err1
 			}
@@ -108,7 +110,7 @@ func (s *service) apiV1Ws(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *service) subSwitch(
-	ct context.Context, c *websocket.Conn, v wsReq, done chan bool) (bool, error) {
+	ct context.Context, r *http.Request, c *websocket.Conn, v wsReq, done chan bool) (bool, error) {
 
 	switch v.Type {
 	case "connection_init":
@@ -157,6 +159,8 @@ func (s *service) subSwitch(
 			return false, err
 		}
 
+		pa := newPolicyAuth(r)
+
 		if s.conf.Serv.Auth.SubsCredsInVars {
 			type authHeaders struct {
 				UserIDProvider string      `json:"X-User-ID-Provider"`
@@ -175,17 +179,26 @@ func (s *service) subSwitch(
 				if x.UserID != nil {
 					ct = context.WithValue(ct, core.UserIDKey, x.UserID)
 				}
+				// the subscription is not run as the user of the token
+				// so the policy only gets the credentials in the variables
+				if x.UserIDProvider != "" || x.UserRole != "" || x.UserID != nil {
+					pa.token = ""
+				}
 			} else {
 				return false, err
 			}
 		}
 
+		if err := s.authorizeQueryAs(pa, p.Query, p.Vars); err != nil {
+			return false, err
+		}
+
 		m, err := s.gj.Subscribe(ct, p.Query, p.Vars, nil)
 		if err != nil {
 			return false, err
 		}
 
-		go s.waitForData(ct, done, c, m, v)
+		go s.waitForData(ct, pa, done, c, m, v, p)
 		return true, nil
 
 	default:
@@ -196,13 +209,21 @@ func (s *service) subSwitch(
 }
 
 func (s *service) waitForData(
-	ct context.Context, done chan bool, c *websocket.Conn,
-	m *core.Member, req wsReq) {
+	ct context.Context, pa policyAuth, done chan bool, c *websocket.Conn,
+	m *core.Member, req wsReq, p gqlReq) {
 	var buf bytes.Buffer
 
 	var ptype string
 	var err error
 
+	// re-evaluate the policy to end subscriptions whose access was revoked
+	var recheck <-chan time.Time
+	if s.policyEnabled() && s.conf.SubsPolicyRecheck > 0 {
+		t := time.NewTicker(s.conf.SubsPolicyRecheck)
+		defer t.Stop()
+		recheck = t.C
+	}
+
 	if req.Type == "subscribe" {
 		ptype = "next"
 	} else {
@@ -216,6 +237,7 @@ func (s *service) waitForData(
 		case v := <-m.Result:
 			m := wsRes{ID: req.ID, Type: ptype}
 			m.Payload.Data = v.Data
+			m.Payload.Patch = v.Patch
 			m.Payload.Errors = v.Errors
 
 			if err = enc.Encode(m); err != nil {
@@ -225,6 +247,22 @@ func (s *service) waitForData(
 			buf.Reset()
 
 			err = c.WriteMessage(websocket.TextMessage, msg)
+		case <-recheck:
+			if err1 := s.authorizeQueryAs(pa, p.Query, p.Vars); err1 != nil {
+				if err1 := sendError(ct, c, err1, req.ID); err1 != nil {
+					s.zlog.Error("Websockets", []zapcore.Field{zap.Error(err1)}...)
+				}
+				m.Unsubscribe()
+
+				// the client answers the close message which ends the
+				// read loop, it closes the connection
+				msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "unauthorized")
+				if err1 := c.WriteMessage(websocket.CloseMessage, msg); err1 != nil {
+					s.zlog.Error("Websockets", []zapcore.Field{zap.Error(err1)}...)
+				}
+				return
+			}
+
 		case v := <-done:
 			if v {
 				return
@@ -243,7 +281,7 @@ func (s *service) waitForData(
 
 func sendError(ct context.Context, c *websocket.Conn, err error, id string) error {
 	m := wsRes{ID: id, Type: "error"}
-	m.Payload.Errors = []core.Error{{Message: err.Error()}}
+	m.Payload.Errors = []core.Error{newError(err)}
 
 	msg, err := json.Marshal(m)
 	if err != nil {